@@ -0,0 +1,209 @@
+// Command dashlytics runs offline maintenance tasks on a Dashlytics dataset.
+//
+//	dashlytics snapshot [-data file.csv] [-snapshot out.snapshot]
+//	dashlytics sql [-data file.csv] [-explain] [-format table|csv|json] "SELECT ..."
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"Dashlytics/internal/config"
+	"Dashlytics/internal/repository"
+	"Dashlytics/internal/sqlquery"
+)
+
+var commands = map[string]func(args []string) error{
+	"snapshot": runSnapshot,
+	"sql":      runSQL,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dashlytics <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  snapshot   parse the CSV and write a binary snapshot for fast server restarts")
+	fmt.Fprintln(os.Stderr, "  sql        run a SQL query against the transactions table")
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		log.Fatalf("dashlytics %s: %v", os.Args[1], err)
+	}
+}
+
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	source := config.SourceFlags(fs)
+	fs.Parse(args)
+
+	src, err := source()
+	if err != nil {
+		return err
+	}
+	out := src.SnapshotPath
+	if out == "" {
+		return fmt.Errorf("no snapshot path")
+	}
+	// stat the CSV before parsing it, so a change while loading makes the
+	// snapshot stale rather than wrongly fresh
+	origin, err := src.Origin()
+	if err != nil {
+		return err
+	}
+	// always rebuild from the CSV, an existing snapshot is what we're replacing
+	src.SnapshotPath = ""
+	ds, report, err := src.Load()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Loaded %s\n", report)
+
+	if err := repository.WriteSnapshot(out, ds, origin); err != nil {
+		return err
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%.1f MB)\n", out, float64(info.Size())/(1<<20))
+	return nil
+}
+
+func runSQL(args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ExitOnError)
+	source := config.SourceFlags(fs)
+	explain := fs.Bool("explain", false, "print the query plan instead of running the query")
+	format := fs.String("format", "table", "output format: table, csv or json")
+	maxRows := fs.Int("max-rows", 0, "stop after this many result rows (0 for all)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dashlytics sql [flags] \"SELECT ... FROM transactions ...\"")
+		fmt.Fprintln(os.Stderr, "The query is read from standard input when no argument is given.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	text := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(text) == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+	// report syntax errors before spending time on loading the data
+	if _, err := sqlquery.Parse(text); err != nil {
+		return sqlError(err)
+	}
+
+	src, err := source()
+	if err != nil {
+		return err
+	}
+	ds, report, err := src.Load()
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %s", report)
+
+	res, err := sqlquery.Run(ds, text, sqlquery.Options{MaxRows: *maxRows, Explain: *explain})
+	if err != nil {
+		return sqlError(err)
+	}
+	if res.Plan != nil {
+		for _, line := range res.Plan {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	case "csv":
+		err = writeCSV(os.Stdout, res)
+	default:
+		err = writeTable(os.Stdout, res)
+	}
+	if err == nil && res.Truncated {
+		log.Printf("(output cut to %d rows)", *maxRows)
+	}
+	return err
+}
+
+// sqlError adds the offending line and a caret to positioned errors.
+func sqlError(err error) error {
+	var perr *sqlquery.Error
+	if errors.As(err, &perr) && perr.Context() != "" {
+		return fmt.Errorf("%w\n%s", err, perr.Context())
+	}
+	return err
+}
+
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func writeTable(w io.Writer, res *sqlquery.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	names := make([]string, len(res.Columns))
+	for i, c := range res.Columns {
+		names[i] = c.Name
+	}
+	fmt.Fprintln(tw, strings.Join(names, "\t"))
+	for _, row := range res.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "(%d rows)\n", len(res.Rows))
+	return err
+}
+
+func writeCSV(w io.Writer, res *sqlquery.Result) error {
+	cw := csv.NewWriter(w)
+	names := make([]string, len(res.Columns))
+	for i, c := range res.Columns {
+		names[i] = c.Name
+	}
+	cw.Write(names)
+	for _, row := range res.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(v)
+		}
+		cw.Write(cells)
+	}
+	cw.Flush()
+	return cw.Error()
+}
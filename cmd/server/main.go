@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	_ "time/tzdata" // the tz parameter takes IANA zones, and the alpine image has no zoneinfo
 
 	"Dashlytics/internal/adapter"
+	"Dashlytics/internal/config"
+	"Dashlytics/internal/reload"
 	"Dashlytics/internal/repository"
 
 	_ "Dashlytics/docs"
@@ -21,16 +27,36 @@ import (
 // @contact.name Harith
 // @host localhost:8080
 // @BasePath /api/v1
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
 func main() {
-	csvFilePath := "data/GO_test_5m.csv"
-	transactions, err := repository.LoadCSV(csvFilePath)
+	source := config.SourceFlags(flag.CommandLine)
+	watch := flag.Duration("watch", 0, "poll the data file at this interval and reload it when it changes (0 disables)")
+	adminToken := flag.String("admin-token", os.Getenv("DASHLYTICS_ADMIN_TOKEN"), "bearer token for the admin endpoints; without one they only answer localhost")
+	flag.Parse()
+
+	src, err := source()
+	if err != nil {
+		log.Fatalf("Error loading column mapping: %v", err)
+	}
+	//preprocess and cache indexed data (large dataset)
+	ds, report, err := src.Load()
+	if report.SnapshotErr != nil {
+		log.Printf("Ignoring snapshot: %v", report.SnapshotErr)
+	}
 	if err != nil {
 		log.Fatalf("Error loading CSV file: %v", err)
 	}
-	fmt.Printf("Loaded %d transactions\n", len(transactions))
+	fmt.Printf("Loaded %s\n", report)
+	holder := &repository.Holder{}
+	holder.Store(ds)
+	server := adapter.NewServer(holder)
 
-	//preprocess and cache indexed data (large dataset)
-	repository.InitDataStore(transactions)
+	reloader := reload.New(src, holder)
+	if *watch > 0 {
+		go reloader.Watch(context.Background(), *watch)
+	}
 
 	r := chi.NewRouter()
 	//CORS middleware
@@ -46,10 +72,36 @@ func main() {
 
 	//register routes
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/country-revenue", adapter.GetCountryRevenue)
-		r.Get("/top-products", adapter.GetTopProducts)
-		r.Get("/monthly-sales", adapter.GetMonthlySales)
-		r.Get("/top-regions", adapter.GetTopRegions)
+		r.Get("/country-revenue", server.GetCountryRevenue)
+		r.Get("/top-products", server.GetTopProducts)
+		r.Get("/monthly-sales", server.GetMonthlySales)
+		r.Get("/top-regions", server.GetTopRegions)
+		r.Get("/timeseries", server.GetTimeSeries)
+		r.Get("/forecast", server.GetForecast)
+		r.Get("/anomalies", server.GetAnomalies)
+		r.Get("/cohorts", server.GetCohorts)
+		r.Get("/rfm", server.GetRFM)
+		r.Get("/rfm/{segment}/users", server.GetRFMSegmentUsers)
+		r.Get("/associations", server.GetAssociations)
+		r.Get("/products/{productID}/related", server.GetRelatedProducts)
+		r.Get("/users/{userID}", server.GetUserProfile)
+		r.Get("/clv", server.GetTopCustomers)
+		r.Get("/transactions", server.GetTransactions)
+		r.Get("/transactions/{id}", server.GetTransaction)
+		r.Get("/categories", server.GetCategories)
+		r.Get("/categories/months", server.GetCategoryMonths)
+		r.Get("/categories/{category}/products", server.GetCategoryProducts)
+		r.Get("/geography", server.GetGeography)
+		r.Get("/geography/{country}", server.GetGeographyCountry)
+		r.Post("/query", server.PostQuery)
+		r.Post("/sql", server.PostSQL)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(adapter.AdminAuth(*adminToken))
+			r.Post("/reload", adapter.TriggerReload(reloader))
+			r.Get("/reload", adapter.GetReloadStatus(reloader))
+			r.Get("/memory", server.GetMemoryReport)
+		})
 	})
 
 	//start server
@@ -17,58 +17,242 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/country-revenue": {
+        "/admin/memory": {
             "get": {
-                "description": "Returns a list of countries with total revenue and transaction count per product",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the estimated bytes held by the rows and by each index, along with what the indexes would take holding copies of the rows",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "revenue"
+                    "admin"
                 ],
-                "summary": "Get country-level revenue data",
+                "summary": "Get the memory used by the dataset",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/adapter.CountryRevenue"
-                            }
+                            "$ref": "#/definitions/repository.MemoryReport"
+                        }
+                    },
+                    "501": {
+                        "description": "repository does not report memory",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/monthly-sales": {
+        "/admin/reload": {
             "get": {
-                "description": "Returns quantity of items sold grouped by month, supports sort and order query params",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the state, duration and row counts of the most recent reload",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the status of the last reload",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reload.Status"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Starts rebuilding the dataset in the background; requests keep using the current data until the new one is ready. At most one reload can be requested every 10 seconds.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload the dataset",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/reload.Status"
+                        }
+                    },
+                    "401": {
+                        "description": "missing or invalid admin token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "not from localhost and no admin token configured",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/reload.Status"
+                        }
+                    },
+                    "429": {
+                        "description": "reload requested too soon",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/anomalies": {
+            "get": {
+                "description": "Scores revenue or quantity per period with robust z-scores, either against the median and MAD of a trailing window or as residuals of a seasonal decomposition, and returns the periods beyond the threshold. Filter by country or product_id to check that series. Only periods from date_from to date_to are checked; earlier ones still set the expectation.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "sales"
                 ],
-                "summary": "Get total quantity sold per month",
+                "summary": "Flag anomalous periods",
                 "parameters": [
                     {
                         "enum": [
-                            "month",
-                            "sales"
+                            "revenue",
+                            "quantity"
                         ],
                         "type": "string",
-                        "description": "Sort by 'month' or 'sales'",
-                        "name": "sort",
+                        "default": "revenue",
+                        "description": "Series to check",
+                        "name": "metric",
                         "in": "query"
                     },
                     {
                         "enum": [
-                            "asc",
-                            "desc"
+                            "rolling_mad",
+                            "seasonal"
                         ],
                         "type": "string",
-                        "description": "Sort order: 'asc' or 'desc'",
-                        "name": "order",
+                        "default": "rolling_mad",
+                        "description": "Detection method",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "default": 3.5,
+                        "description": "Absolute score beyond which a period is anomalous",
+                        "name": "threshold",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Periods before each one for rolling_mad; 28 days, 13 weeks, 12 months, 8 quarters or 5 years by default",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Periods per season for seasonal; 7 for days, 52 for weeks, 12 for months and 4 for quarters by default",
+                        "name": "season",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return every scored period, not only the anomalies",
+                        "name": "all",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month",
+                            "quarter",
+                            "year"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Period length",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "America/New_York",
+                        "description": "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone.",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
                         "in": "query"
                     }
                 ],
@@ -76,71 +260,3027 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/adapter.MonthlySales"
-                            }
+                            "$ref": "#/definitions/adapter.Anomalies"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter or too little history",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/top-products": {
+        "/associations": {
             "get": {
-                "description": "Returns top products with quantity sold and stock",
+                "description": "Mines the frequent itemsets of the baskets, one per user and day, with FP-Growth, and the association rules with a single consequent. Itemsets are sorted by baskets, rules by the sort parameter.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "products"
                 ],
-                "summary": "Get top 20 most frequently purchased products",
+                "summary": "Get products bought together",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "default": 0.001,
+                        "description": "Share of baskets an itemset must appear in; at least 2 baskets always",
+                        "name": "min_support",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "default": 0.1,
+                        "description": "Minimum confidence of a rule",
+                        "name": "min_confidence",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 4,
+                        "minimum": 2,
+                        "type": "integer",
+                        "default": 3,
+                        "description": "Largest itemset",
+                        "name": "max_length",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "lift",
+                            "confidence",
+                            "support"
+                        ],
+                        "type": "string",
+                        "default": "lift",
+                        "description": "Rule order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10000,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Itemsets and rules to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/adapter.TopProduct"
-                            }
+                            "$ref": "#/definitions/adapter.Associations"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter or too many itemsets",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/top-regions": {
+        "/categories": {
             "get": {
-                "description": "Returns regions with highest sales and revenue",
+                "description": "Returns the revenue, units, average selling price, number of products sold and share of the total of each category.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "regions"
+                    "categories"
+                ],
+                "summary": "Get sales per category",
+                "parameters": [
+                    {
+                        "enum": [
+                            "revenue",
+                            "units",
+                            "products",
+                            "name"
+                        ],
+                        "type": "string",
+                        "default": "revenue",
+                        "description": "Category order, descending except for name",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get top 30 regions by total revenue and items sold",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/adapter.RegionStats"
-                            }
+                            "$ref": "#/definitions/adapter.CategoryBreakdown"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "adapter.CountryRevenue": {
-            "type": "object",
+        },
+        "/categories/months": {
+            "get": {
+                "description": "Returns a category by month matrix of revenue and units, e.g. for a stacked area chart. Every month from the first sale to the last is present, zero when a category had no sales. Categories come by total revenue, highest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Get monthly sales per category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.CategoryMonths"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/{category}/products": {
+            "get": {
+                "description": "Drills down from a category to its products, highest revenue first, with their share of the category's revenue. A category filter that leaves the category out matches no products.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Get the products of a category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Category",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "maximum": 10000,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Products to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.CategoryProducts"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown category",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/clv": {
+            "get": {
+                "description": "Fits a BG/NBD model to the users of the matching transactions and pages through them by descending lifetime value: revenue up to the reference date plus the revenue expected over the horizon. An order is a day with purchases.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Rank users by lifetime value",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Day the lifetime value is estimated on (YYYY-MM-DD); date_to or the last day of the data by default",
+                        "name": "reference_date",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 3650,
+                        "type": "integer",
+                        "default": 365,
+                        "description": "Days the projection looks ahead",
+                        "name": "horizon_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10000,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Users per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.TopCustomers"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter or too few users to fit the model",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/cohorts": {
+            "get": {
+                "description": "Groups users by the period of their first purchase and returns, per cohort, the share of users active and the revenue in each later period. Rows end at the last period of the data, so newer cohorts have shorter rows. date_from keeps only users whose first matching purchase is on or after it, date_to leaves out later purchases.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get cohort retention",
+                "parameters": [
+                    {
+                        "enum": [
+                            "week",
+                            "month",
+                            "quarter"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Cohort and period length",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.CohortRetention"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/country-revenue": {
+            "get": {
+                "description": "Returns a list of countries with total revenue and transaction count per product",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revenue"
+                ],
+                "summary": "Get country-level revenue data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/adapter.CountryRevenue"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/forecast": {
+            "get": {
+                "description": "Projects revenue and quantity sold for the next periods with Holt-Winters smoothing or a seasonal naive baseline, with prediction intervals and the error of a backtest on the last periods. Filter by country, region or product_id to forecast that series instead of the global one. date_from and date_to bound the history the model is fitted to: use date_to to leave out an incomplete last period.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Forecast sales",
+                "parameters": [
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month",
+                            "quarter"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Period length",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "America/New_York",
+                        "description": "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone.",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 366,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 12,
+                        "description": "Periods to project",
+                        "name": "horizon",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "holt_winters",
+                            "seasonal_naive"
+                        ],
+                        "type": "string",
+                        "default": "holt_winters",
+                        "description": "Forecasting method",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Periods per season; 7 for days, 52 for weeks, 12 for months and 4 for quarters by default",
+                        "name": "season",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "default": 0.95,
+                        "description": "Coverage of the prediction intervals",
+                        "name": "level",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.Forecast"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter or too little history",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/geography": {
+            "get": {
+                "description": "Returns the total of all countries with a node per country, holding revenue, units and transactions rolled up from their regions, and each node's share of its parent. Countries are collapsed unless expand is true: fetch /geography/{country} to expand one of them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revenue"
+                ],
+                "summary": "Get the country and region tree",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include every country's regions",
+                        "name": "expand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.GeoNode"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/geography/{country}": {
+            "get": {
+                "description": "Returns one country of the /geography tree with its regions. The country's shares are of the total of all countries, its regions' shares of the country.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revenue"
+                ],
+                "summary": "Expand one country",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Country",
+                        "name": "country",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.GeoNode"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown country",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/monthly-sales": {
+            "get": {
+                "description": "Returns quantity of items sold grouped by month, supports sort and order query params",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get total quantity sold per month",
+                "parameters": [
+                    {
+                        "enum": [
+                            "month",
+                            "sales"
+                        ],
+                        "type": "string",
+                        "description": "Sort by 'month' or 'sales'",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort order: 'asc' or 'desc'",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/adapter.MonthlySales"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{productID}/related": {
+            "get": {
+                "description": "Lists the products that share baskets, one per user and day, with the given product, by descending lift.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Get products bought with a product",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product ID",
+                        "name": "productID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 2,
+                        "description": "Baskets the products must share",
+                        "name": "min_count",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Products to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.RelatedProducts"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown product",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/query": {
+            "post": {
+                "description": "Groups the transactions by any fields or date buckets, computes the requested metrics per group and returns the sorted groups as a table",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "query"
+                ],
+                "summary": "Run a group-by/aggregate query",
+                "parameters": [
+                    {
+                        "description": "Dimensions, metrics, filters, sort and limit",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/adapter.QueryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/query.Result"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid query",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/rfm": {
+            "get": {
+                "description": "Scores users by recency, frequency and monetary value in quantiles and returns the size and revenue share of each named segment. Segments are placed by the recency score against the average of the frequency and monetary scores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get RFM segments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Day recency is measured to (YYYY-MM-DD); date_to or the last day of the data by default",
+                        "name": "reference_date",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10,
+                        "minimum": 2,
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Score levels",
+                        "name": "quantiles",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.RFMSegmentation"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/rfm/{segment}/users": {
+            "get": {
+                "description": "Pages through the users of one segment, highest monetary value first, with their RFM values and scores. Takes the parameters of /rfm, which define the segments.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "List the users of an RFM segment",
+                "parameters": [
+                    {
+                        "enum": [
+                            "champions",
+                            "loyal_customers",
+                            "potential_loyalists",
+                            "new_customers",
+                            "promising",
+                            "need_attention",
+                            "about_to_sleep",
+                            "cant_lose_them",
+                            "at_risk",
+                            "hibernating",
+                            "lost"
+                        ],
+                        "type": "string",
+                        "description": "Segment key",
+                        "name": "segment",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10000,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Users per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day recency is measured to (YYYY-MM-DD); date_to or the last day of the data by default",
+                        "name": "reference_date",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10,
+                        "minimum": 2,
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Score levels",
+                        "name": "quantiles",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.RFMSegmentUsers"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown segment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/sql": {
+            "post": {
+                "description": "Runs a SELECT over the virtual \"transactions\" table (WHERE, GROUP BY, HAVING, ORDER BY, LIMIT, date_trunc, count(DISTINCT ...)). Results are capped at 10000 rows, query text at 64 KiB.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "query"
+                ],
+                "summary": "Run a read-only SQL query",
+                "parameters": [
+                    {
+                        "description": "SQL text, optionally explained",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/adapter.SQLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sqlquery.Result"
+                        }
+                    },
+                    "400": {
+                        "description": "syntax or planning error with its position",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/timeseries": {
+            "get": {
+                "description": "Returns quantity, revenue and transaction count per day, ISO week, month, quarter or year. Periods without sales are included with zeros. date_from and date_to extend or cut the series to the periods holding them. tz only sets the UTC offset of each period's start, as dates are stored as calendar days.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get sales per period",
+                "parameters": [
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month",
+                            "quarter",
+                            "year"
+                        ],
+                        "type": "string",
+                        "default": "month",
+                        "description": "Period length",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "America/New_York",
+                        "description": "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone.",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Add previous-period and same-period-last-year values, deltas and a running total of revenue and quantity",
+                        "name": "compare",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "avg:7,ewma:30",
+                        "description": "Window functions over revenue and quantity, in periods (comma-separated sum:N, avg:N or ewma:N)",
+                        "name": "rolling",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.TimeSeries"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/top-products": {
+            "get": {
+                "description": "Returns top products with quantity sold and stock",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Get top 20 most frequently purchased products",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/adapter.TopProduct"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/top-regions": {
+            "get": {
+                "description": "Returns regions with highest sales and revenue",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "regions"
+                ],
+                "summary": "Get top 30 regions by total revenue and items sold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/adapter.RegionStats"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions": {
+            "get": {
+                "description": "Pages through the matching transactions sorted by any field, ties broken by ID. Pass the next_cursor of a page as cursor, with the same sort and order, to get the next one. Cursors point at a transaction's values rather than its position, so paging stays consistent when the data is reloaded in between.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "List transactions",
+                "parameters": [
+                    {
+                        "enum": [
+                            "id",
+                            "date",
+                            "user_id",
+                            "country",
+                            "region",
+                            "product_id",
+                            "product_name",
+                            "category",
+                            "price",
+                            "quantity",
+                            "total_price",
+                            "stock",
+                            "added_date"
+                        ],
+                        "type": "string",
+                        "default": "date",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "desc",
+                        "description": "Sort order",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 10000,
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Transactions per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these countries",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "only transactions on or after this date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-12-31",
+                        "description": "only transactions on or before this date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at most this",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only transactions with a unit price of at least this",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these product IDs",
+                        "name": "product_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions in these regions",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only transactions of these users",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.TransactionPage"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter or cursor",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}": {
+            "get": {
+                "description": "Returns one transaction by its ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "Get a transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.Transaction"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown transaction",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{userID}": {
+            "get": {
+                "description": "Returns every transaction of a user, oldest first, with derived stats and a lifetime value estimate: the revenue up to the reference date plus the revenue a BG/NBD model, fitted to all users, expects over the horizon. An order is a day with purchases.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get a user's profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day the lifetime value is estimated on (YYYY-MM-DD); the last day of the data by default",
+                        "name": "reference_date",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 3650,
+                        "type": "integer",
+                        "default": 365,
+                        "description": "Days the projection looks ahead",
+                        "name": "horizon_days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/adapter.UserProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "adapter.Anomalies": {
+            "type": "object",
+            "properties": {
+                "granularity": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "metric": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.AnomalyPoint"
+                    }
+                },
+                "points_checked": {
+                    "description": "periods that could be scored",
+                    "type": "integer"
+                },
+                "season": {
+                    "type": "integer"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "tz": {
+                    "type": "string"
+                },
+                "window": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.AnomalyPoint": {
+            "type": "object",
+            "properties": {
+                "anomaly": {
+                    "type": "boolean"
+                },
+                "direction": {
+                    "description": "high or low, for anomalies",
+                    "type": "string"
+                },
+                "expected": {
+                    "type": "number"
+                },
+                "lower": {
+                    "description": "expected range at the threshold",
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "score": {
+                    "description": "robust z-score, positive above expected",
+                    "type": "number"
+                },
+                "start": {
+                    "description": "RFC 3339, at the UTC offset of tz",
+                    "type": "string"
+                },
+                "upper": {
+                    "type": "number"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.AssociationRule": {
+            "type": "object",
+            "properties": {
+                "antecedent": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.BasketProduct"
+                    }
+                },
+                "baskets": {
+                    "description": "holding both",
+                    "type": "integer"
+                },
+                "confidence": {
+                    "type": "number"
+                },
+                "consequent": {
+                    "$ref": "#/definitions/adapter.BasketProduct"
+                },
+                "lift": {
+                    "type": "number"
+                },
+                "support": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.Associations": {
+            "type": "object",
+            "properties": {
+                "baskets": {
+                    "description": "one per user and day",
+                    "type": "integer"
+                },
+                "itemsets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.FrequentItemset"
+                    }
+                },
+                "min_confidence": {
+                    "type": "number"
+                },
+                "min_support": {
+                    "type": "number"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.AssociationRule"
+                    }
+                },
+                "total_itemsets": {
+                    "type": "integer"
+                },
+                "total_rules": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.BacktestAccuracy": {
+            "type": "object",
+            "properties": {
+                "mape": {
+                    "description": "percent; null when every actual value is zero",
+                    "type": "number"
+                },
+                "periods": {
+                    "type": "integer"
+                },
+                "rmse": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.BasketProduct": {
+            "type": "object",
+            "properties": {
+                "product_id": {
+                    "type": "string"
+                },
+                "product_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "adapter.CLVEstimate": {
+            "type": "object",
+            "properties": {
+                "expected_orders": {
+                    "description": "the model's outlook over the horizon, null when it can't be fitted",
+                    "type": "number"
+                },
+                "historical": {
+                    "description": "revenue up to the reference date",
+                    "type": "number"
+                },
+                "horizon_days": {
+                    "type": "integer"
+                },
+                "probability_alive": {
+                    "type": "number"
+                },
+                "projected": {
+                    "description": "expected orders at the user's average order value",
+                    "type": "number"
+                },
+                "reference_date": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "historical plus projected",
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.CLVModel": {
+            "type": "object",
+            "properties": {
+                "a": {
+                    "type": "number"
+                },
+                "alpha": {
+                    "type": "number"
+                },
+                "b": {
+                    "type": "number"
+                },
+                "r": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.CategoryBreakdown": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.CategoryStats"
+                    }
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "units": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CategoryMonths": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.CategorySeries"
+                    }
+                },
+                "months": {
+                    "description": "YYYY-MM, every month from the first sale to the last",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "adapter.CategoryProduct": {
+            "type": "object",
+            "properties": {
+                "avg_selling_price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "product_name": {
+                    "type": "string"
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "revenue_share": {
+                    "description": "of the category",
+                    "type": "number"
+                },
+                "transactions": {
+                    "type": "integer"
+                },
+                "units": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CategoryProducts": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.CategoryProduct"
+                    }
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "total_products": {
+                    "type": "integer"
+                },
+                "units": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CategorySeries": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "revenue": {
+                    "description": "indexed like CategoryMonths.Months",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "total_revenue": {
+                    "type": "number"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "adapter.CategoryStats": {
+            "type": "object",
+            "properties": {
+                "avg_selling_price": {
+                    "description": "revenue per unit",
+                    "type": "number"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "products": {
+                    "description": "distinct products sold",
+                    "type": "integer"
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "revenue_share": {
+                    "type": "number"
+                },
+                "transactions": {
+                    "type": "integer"
+                },
+                "unit_share": {
+                    "type": "number"
+                },
+                "units": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CohortRetention": {
+            "type": "object",
+            "properties": {
+                "cohorts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.CohortRow"
+                    }
+                },
+                "granularity": {
+                    "type": "string"
+                },
+                "periods": {
+                    "description": "length of the oldest cohort's row",
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CohortRow": {
+            "type": "object",
+            "properties": {
+                "active_users": {
+                    "description": "indexed by periods since the first: [0] is the cohort's first period",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "cohort": {
+                    "description": "2024-01, 2024-W05 or 2024-Q1",
+                    "type": "string"
+                },
+                "period_revenue": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "retention": {
+                    "description": "active_users over users",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "revenue": {
+                    "description": "over every period",
+                    "type": "number"
+                },
+                "start": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "users": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CountryRevenue": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "product_name": {
+                    "type": "string"
+                },
+                "total_revenue": {
+                    "type": "number"
+                },
+                "transaction_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.CustomerValue": {
+            "type": "object",
+            "properties": {
+                "clv": {
+                    "type": "number"
+                },
+                "expected_orders": {
+                    "type": "number"
+                },
+                "historical": {
+                    "type": "number"
+                },
+                "last_purchase": {
+                    "type": "string"
+                },
+                "orders": {
+                    "type": "integer"
+                },
+                "probability_alive": {
+                    "type": "number"
+                },
+                "projected": {
+                    "type": "number"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "adapter.Forecast": {
+            "type": "object",
+            "properties": {
+                "granularity": {
+                    "type": "string"
+                },
+                "history_periods": {
+                    "type": "integer"
+                },
+                "level": {
+                    "type": "number"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "$ref": "#/definitions/adapter.ForecastSeries"
+                },
+                "revenue": {
+                    "$ref": "#/definitions/adapter.ForecastSeries"
+                },
+                "season_length": {
+                    "type": "integer"
+                },
+                "tz": {
+                    "type": "string"
+                }
+            }
+        },
+        "adapter.ForecastPoint": {
+            "type": "object",
+            "properties": {
+                "lower": {
+                    "description": "prediction interval",
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "start": {
+                    "description": "RFC 3339, at the UTC offset of tz",
+                    "type": "string"
+                },
+                "upper": {
+                    "type": "number"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.ForecastSeries": {
+            "type": "object",
+            "properties": {
+                "backtest": {
+                    "description": "null when the history is too short",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/adapter.BacktestAccuracy"
+                        }
+                    ]
+                },
+                "parameters": {
+                    "$ref": "#/definitions/adapter.SmoothingParameters"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.ForecastPoint"
+                    }
+                }
+            }
+        },
+        "adapter.FrequentItemset": {
+            "type": "object",
+            "properties": {
+                "baskets": {
+                    "type": "integer"
+                },
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.BasketProduct"
+                    }
+                },
+                "support": {
+                    "description": "share of all baskets",
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.GeoNode": {
+            "type": "object",
+            "properties": {
+                "child_count": {
+                    "description": "ChildCount is the number of nodes below, which Children holds when\nthe node is expanded",
+                    "type": "integer"
+                },
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.GeoNode"
+                    }
+                },
+                "level": {
+                    "description": "total, country or region",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "revenue_share": {
+                    "description": "shares of the parent's revenue and units, 1 at the root",
+                    "type": "number"
+                },
+                "transactions": {
+                    "type": "integer"
+                },
+                "unit_share": {
+                    "type": "number"
+                },
+                "units": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.MonthlySales": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "format: \"YYYY-MM\"",
+                    "type": "string"
+                },
+                "total_quantity_sold": {
+                    "type": "integer"
+                },
+                "total_revenue": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.PeriodChange": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "description": "current minus earlier",
+                    "type": "number"
+                },
+                "percent": {
+                    "description": "null when the earlier value is zero",
+                    "type": "number"
+                },
+                "value": {
+                    "description": "the earlier period's value",
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.PeriodComparison": {
+            "type": "object",
+            "properties": {
+                "cumulative": {
+                    "type": "number"
+                },
+                "last_year": {
+                    "description": "same ISO week, month, quarter or date a year earlier",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/adapter.PeriodChange"
+                        }
+                    ]
+                },
+                "previous": {
+                    "description": "null for the first period",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/adapter.PeriodChange"
+                        }
+                    ]
+                }
+            }
+        },
+        "adapter.QueryRequest": {
+            "type": "object",
+            "properties": {
+                "filters": {
+                    "description": "Filters takes the same names and values as the analytics endpoints' query parameters",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "group_by": {
+                    "description": "GroupBy lists transaction fields, or date fields with a bucket (day, week, month, quarter, year)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "country",
+                        "date:month"
+                    ]
+                },
+                "limit": {
+                    "description": "Limit defaults to 1000 groups and is capped at 10000",
+                    "type": "integer"
+                },
+                "metrics": {
+                    "description": "Metrics computed per group: sum, count, avg, min, max or first of quantity, total_price, price or stock",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/query.Metric"
+                    }
+                },
+                "sort": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/query.Order"
+                    }
+                }
+            }
+        },
+        "adapter.RFMCustomer": {
+            "type": "object",
+            "properties": {
+                "f": {
+                    "type": "integer"
+                },
+                "frequency": {
+                    "type": "integer"
+                },
+                "m": {
+                    "type": "integer"
+                },
+                "monetary": {
+                    "type": "number"
+                },
+                "r": {
+                    "type": "integer"
+                },
+                "recency_days": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "adapter.RFMSegment": {
+            "type": "object",
+            "properties": {
+                "avg_frequency": {
+                    "type": "number"
+                },
+                "avg_monetary": {
+                    "type": "number"
+                },
+                "avg_recency_days": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "revenue_share": {
+                    "type": "number"
+                },
+                "segment": {
+                    "description": "key for /rfm/{segment}/users",
+                    "type": "string"
+                },
+                "user_share": {
+                    "type": "number"
+                },
+                "users": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.RFMSegmentUsers": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "segment": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.RFMCustomer"
+                    }
+                }
+            }
+        },
+        "adapter.RFMSegmentation": {
+            "type": "object",
+            "properties": {
+                "quantiles": {
+                    "type": "integer"
+                },
+                "reference_date": {
+                    "type": "string"
+                },
+                "revenue": {
+                    "type": "number"
+                },
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.RFMSegment"
+                    }
+                },
+                "users": {
+                    "type": "integer"
+                }
+            }
+        },
+        "adapter.RegionStats": {
+            "type": "object",
+            "properties": {
+                "region": {
+                    "type": "string"
+                },
+                "total_item_sold": {
+                    "type": "integer"
+                },
+                "total_revenue": {
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.RelatedProduct": {
+            "type": "object",
+            "properties": {
+                "baskets": {
+                    "description": "holding both",
+                    "type": "integer"
+                },
+                "confidence": {
+                    "description": "share of the product's baskets holding this one",
+                    "type": "number"
+                },
+                "lift": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "product_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "adapter.RelatedProducts": {
+            "type": "object",
+            "properties": {
+                "baskets": {
+                    "description": "holding the product",
+                    "type": "integer"
+                },
+                "product": {
+                    "$ref": "#/definitions/adapter.BasketProduct"
+                },
+                "related": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.RelatedProduct"
+                    }
+                }
+            }
+        },
+        "adapter.RollingValue": {
+            "type": "object",
             "properties": {
-                "country": {
+                "quantity": {
+                    "description": "null until the window is full",
+                    "type": "number"
+                },
+                "revenue": {
+                    "description": "null until the window is full",
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.SQLRequest": {
+            "type": "object",
+            "properties": {
+                "explain": {
+                    "description": "Explain returns the query plan instead of running the query",
+                    "type": "boolean"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "SELECT country, sum(total_price) AS revenue FROM transactions GROUP BY country ORDER BY revenue DESC LIMIT 10"
+                }
+            }
+        },
+        "adapter.SmoothingParameters": {
+            "type": "object",
+            "properties": {
+                "alpha": {
+                    "description": "level",
+                    "type": "number"
+                },
+                "beta": {
+                    "description": "trend",
+                    "type": "number"
+                },
+                "gamma": {
+                    "description": "season",
+                    "type": "number"
+                }
+            }
+        },
+        "adapter.TimeSeries": {
+            "type": "object",
+            "properties": {
+                "granularity": {
                     "type": "string"
                 },
-                "product_name": {
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.TimeSeriesPoint"
+                    }
+                },
+                "tz": {
                     "type": "string"
+                }
+            }
+        },
+        "adapter.TimeSeriesPoint": {
+            "type": "object",
+            "properties": {
+                "period": {
+                    "description": "2024-01-31, 2024-W05, 2024-01, 2024-Q1 or 2024",
+                    "type": "string"
+                },
+                "quantity_comparison": {
+                    "$ref": "#/definitions/adapter.PeriodComparison"
+                },
+                "revenue_comparison": {
+                    "description": "with compare=true",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/adapter.PeriodComparison"
+                        }
+                    ]
+                },
+                "rolling": {
+                    "description": "with rolling=..., keyed by window such as \"avg:7\"",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/adapter.RollingValue"
+                    }
+                },
+                "start": {
+                    "description": "RFC 3339, at the UTC offset of tz",
+                    "type": "string"
+                },
+                "total_quantity_sold": {
+                    "type": "integer"
                 },
                 "total_revenue": {
                     "type": "number"
@@ -150,48 +3290,410 @@ const docTemplate = `{
                 }
             }
         },
-        "adapter.MonthlySales": {
+        "adapter.TopCustomers": {
             "type": "object",
             "properties": {
-                "month": {
-                    "description": "format: \"YYYY-MM\"",
+                "horizon_days": {
+                    "type": "integer"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "model": {
+                    "$ref": "#/definitions/adapter.CLVModel"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "reference_date": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.CustomerValue"
+                    }
+                }
+            }
+        },
+        "adapter.TopProduct": {
+            "type": "object",
+            "properties": {
+                "product_name": {
                     "type": "string"
                 },
+                "stock_quantity": {
+                    "type": "integer"
+                },
                 "total_quantity_sold": {
                     "type": "integer"
+                }
+            }
+        },
+        "adapter.Transaction": {
+            "type": "object",
+            "properties": {
+                "added_date": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
                 },
-                "total_revenue": {
+                "category": {
+                    "type": "string"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "date": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "product_name": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                },
+                "total_price": {
                     "type": "number"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "adapter.RegionStats": {
+        "adapter.TransactionPage": {
             "type": "object",
             "properties": {
-                "region": {
+                "next_cursor": {
+                    "description": "NextCursor fetches the following page, empty on the last one",
                     "type": "string"
                 },
-                "total_item_sold": {
+                "total": {
+                    "description": "matching transactions",
                     "type": "integer"
                 },
-                "total_revenue": {
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.Transaction"
+                    }
+                }
+            }
+        },
+        "adapter.UserProfile": {
+            "type": "object",
+            "properties": {
+                "avg_order_value": {
+                    "type": "number"
+                },
+                "clv": {
+                    "description": "null without purchases up to the reference date",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/adapter.CLVEstimate"
+                        }
+                    ]
+                },
+                "countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "favourite_category": {
+                    "description": "by spend",
+                    "type": "string"
+                },
+                "first_purchase": {
+                    "type": "string"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/adapter.Transaction"
+                    }
+                },
+                "last_purchase": {
+                    "type": "string"
+                },
+                "orders": {
+                    "description": "days with purchases",
+                    "type": "integer"
+                },
+                "total_spend": {
                     "type": "number"
+                },
+                "transactions": {
+                    "type": "integer"
+                },
+                "units": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "adapter.TopProduct": {
+        "query.Column": {
             "type": "object",
             "properties": {
-                "product_name": {
+                "name": {
                     "type": "string"
                 },
-                "stock_quantity": {
+                "type": {
+                    "$ref": "#/definitions/query.ColumnType"
+                }
+            }
+        },
+        "query.ColumnType": {
+            "type": "string",
+            "enum": [
+                "string",
+                "number"
+            ],
+            "x-enum-varnames": [
+                "String",
+                "Number"
+            ]
+        },
+        "query.Metric": {
+            "type": "object",
+            "properties": {
+                "as": {
+                    "description": "As names the result column, \"op_field\" (or \"count\") by default.",
+                    "type": "string"
+                },
+                "field": {
+                    "description": "Field is one of quantity, total_price, price or stock. Count ignores it.",
+                    "type": "string"
+                },
+                "op": {
+                    "description": "Op is sum, count, avg, min, max or first (the value of the group's\nfirst row in table order).",
+                    "type": "string"
+                }
+            }
+        },
+        "query.Order": {
+            "type": "object",
+            "properties": {
+                "by": {
+                    "type": "string"
+                },
+                "desc": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "query.Result": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/query.Column"
+                    }
+                },
+                "groups": {
+                    "description": "Groups is the number of groups before the limit was applied.",
                     "type": "integer"
                 },
-                "total_quantity_sold": {
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {}
+                    }
+                }
+            }
+        },
+        "reload.State": {
+            "type": "string",
+            "enum": [
+                "idle",
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "StateIdle",
+                "StateRunning",
+                "StateSucceeded",
+                "StateFailed"
+            ]
+        },
+        "reload.Status": {
+            "type": "object",
+            "properties": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "from_snapshot": {
+                    "type": "boolean"
+                },
+                "previous_rows": {
+                    "type": "integer"
+                },
+                "reloads": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "skipped_rows": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/reload.State"
+                },
+                "trigger": {
+                    "type": "string"
+                }
+            }
+        },
+        "repository.ColumnMemory": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "distinct": {
+                    "description": "Distinct is the dictionary size of dictionary-encoded columns.",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "repository.IndexMemory": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "Bytes is the size with row offsets, BytesAsCopies what the same index\ntook when it held a copy of every Transaction.",
+                    "type": "integer"
+                },
+                "bytes_as_copies": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "keys": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "repository.MemoryReport": {
+            "type": "object",
+            "properties": {
+                "column_bytes": {
+                    "description": "ColumnBytes is the size of the columnar table, RowBytes what the same\nrows take as a []domain.Transaction (struct only, strings not counted).",
+                    "type": "integer"
+                },
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repository.ColumnMemory"
+                    }
+                },
+                "heap_alloc_bytes": {
+                    "description": "HeapAllocBytes is the Go heap of the whole process, for comparison.",
+                    "type": "integer"
+                },
+                "index_bytes": {
+                    "type": "integer"
+                },
+                "index_bytes_as_copies": {
+                    "type": "integer"
+                },
+                "indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/repository.IndexMemory"
+                    }
+                },
+                "row_bytes": {
+                    "type": "integer"
+                },
+                "rows": {
                     "type": "integer"
                 }
             }
+        },
+        "sqlquery.Column": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "sqlquery.Result": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sqlquery.Column"
+                    }
+                },
+                "plan": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {}
+                    }
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"Dashlytics/internal/reload"
+	"Dashlytics/internal/repository"
+)
+
+// minReloadInterval is how long after a reload started another one can be
+// requested.
+const minReloadInterval = 10 * time.Second
+
+// AdminAuth guards the admin endpoints. With a token, requests must carry it
+// as "Authorization: Bearer <token>"; without one, only requests from the
+// loopback interface are let through.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				host, _, err := net.SplitHostPort(r.RemoteAddr)
+				if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+					http.Error(w, "admin endpoints are only served to localhost without an admin token", http.StatusForbidden)
+					return
+				}
+			} else {
+				given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, "missing or invalid admin token", http.StatusUnauthorized)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReloadHandler godoc
+// @Summary Reload the dataset
+// @Description Starts rebuilding the dataset in the background; requests keep using the current data until the new one is ready. At most one reload can be requested every 10 seconds.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 202 {object} reload.Status
+// @Failure 401 {string} string "missing or invalid admin token"
+// @Failure 403 {string} string "not from localhost and no admin token configured"
+// @Failure 409 {object} reload.Status
+// @Failure 429 {string} string "reload requested too soon"
+// @Router /admin/reload [post]
+func TriggerReload(rl *reload.Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wait := minReloadInterval - time.Since(rl.Status().StartedAt); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds()+1)))
+			http.Error(w, fmt.Sprintf("reload requested too soon, retry in %s", wait.Round(time.Second)), http.StatusTooManyRequests)
+			return
+		}
+		status := http.StatusAccepted
+		if err := rl.Start("api"); errors.Is(err, reload.ErrInProgress) {
+			status = http.StatusConflict
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(rl.Status())
+	}
+}
+
+// ReloadStatusHandler godoc
+// @Summary Get the status of the last reload
+// @Description Returns the state, duration and row counts of the most recent reload
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} reload.Status
+// @Router /admin/reload [get]
+func GetReloadStatus(rl *reload.Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rl.Status())
+	}
+}
+
+// memoryReporter is implemented by repositories that can estimate their own footprint.
+type memoryReporter interface {
+	MemoryReport() repository.MemoryReport
+}
+
+// MemoryHandler godoc
+// @Summary Get the memory used by the dataset
+// @Description Returns the estimated bytes held by the rows and by each index, along with what the indexes would take holding copies of the rows
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} repository.MemoryReport
+// @Failure 501 {string} string "repository does not report memory"
+// @Router /admin/memory [get]
+func (s *Server) GetMemoryReport(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.repos.Current().(memoryReporter)
+	if !ok {
+		http.Error(w, "repository does not report memory", http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporter.MemoryReport())
+}
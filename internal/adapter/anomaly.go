@@ -0,0 +1,186 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Dashlytics/internal/anomaly"
+	"Dashlytics/internal/query"
+	"Dashlytics/internal/timeseries"
+	"Dashlytics/internal/window"
+)
+
+// anomalyWindows are the default rolling windows: four weeks of days, a
+// quarter of weeks, a year of months, two years of quarters and five years.
+var anomalyWindows = map[query.Bucket]int{
+	query.Day:     28,
+	query.Week:    13,
+	query.Month:   12,
+	query.Quarter: 8,
+	query.Year:    5,
+}
+
+// AnomalyPoint is the verdict on one period
+type AnomalyPoint struct {
+	Period    string  `json:"period"`
+	Start     string  `json:"start"` // RFC 3339, at the UTC offset of tz
+	Value     float64 `json:"value"`
+	Expected  float64 `json:"expected"`
+	Lower     float64 `json:"lower"` // expected range at the threshold
+	Upper     float64 `json:"upper"`
+	Score     float64 `json:"score"` // robust z-score, positive above expected
+	Anomaly   bool    `json:"anomaly"`
+	Direction string  `json:"direction,omitempty"` // high or low, for anomalies
+}
+
+// Anomalies lists the anomalous periods of a series
+type Anomalies struct {
+	Granularity string  `json:"granularity"`
+	TimeZone    string  `json:"tz"`
+	Metric      string  `json:"metric"`
+	Method      string  `json:"method"`
+	Threshold   float64 `json:"threshold"`
+	Window      int     `json:"window,omitempty"`
+	Season      int     `json:"season,omitempty"`
+	// periods that could be scored
+	Checked int            `json:"points_checked"`
+	Points  []AnomalyPoint `json:"points"`
+}
+
+// AnomaliesHandler godoc
+// @Summary Flag anomalous periods
+// @Description Scores revenue or quantity per period with robust z-scores, either against the median and MAD of a trailing window or as residuals of a seasonal decomposition, and returns the periods beyond the threshold. Filter by country or product_id to check that series. Only periods from date_from to date_to are checked; earlier ones still set the expectation.
+// @Tags sales
+// @Produce json
+// @Param metric query string false "Series to check" Enums(revenue,quantity) default(revenue)
+// @Param method query string false "Detection method" Enums(rolling_mad,seasonal) default(rolling_mad)
+// @Param threshold query number false "Absolute score beyond which a period is anomalous" default(3.5)
+// @Param window query int false "Periods before each one for rolling_mad; 28 days, 13 weeks, 12 months, 8 quarters or 5 years by default"
+// @Param season query int false "Periods per season for seasonal; 7 for days, 52 for weeks, 12 for months and 4 for quarters by default"
+// @Param all query bool false "Return every scored period, not only the anomalies"
+// @Param granularity query string false "Period length" Enums(day,week,month,quarter,year) default(month)
+// @Param tz query string false "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone." example(America/New_York)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} Anomalies
+// @Failure 400 {string} string "invalid parameter or too little history"
+// @Router /anomalies [get]
+func (s *Server) GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	switch metric {
+	case "":
+		metric = "revenue"
+	case "revenue", "quantity":
+	default:
+		http.Error(w, fmt.Sprintf("invalid metric %q: want revenue or quantity", metric), http.StatusBadRequest)
+		return
+	}
+	opts := anomaly.Options{Method: anomaly.RollingMAD, Threshold: anomaly.DefaultThreshold}
+	if m := r.URL.Query().Get("method"); m != "" {
+		method, err := anomaly.ParseMethod(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Method = method
+	}
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		threshold, err := strconv.ParseFloat(t, 64)
+		if err != nil || threshold <= 0 {
+			http.Error(w, fmt.Sprintf("invalid threshold %q: want a positive number", t), http.StatusBadRequest)
+			return
+		}
+		opts.Threshold = threshold
+	}
+	var ok bool
+	if opts.Window, ok = intParam(w, r, "window", 0, 3, window.MaxSize); !ok {
+		return
+	}
+	if opts.Season, ok = intParam(w, r, "season", 0, 2, timeseries.MaxPoints); !ok {
+		return
+	}
+	all, ok := boolParam(w, r, "all")
+	if !ok {
+		return
+	}
+
+	// fill in the defaults of the granularity before the lookback needs them
+	withDefaults := func(b query.Bucket) anomaly.Options {
+		o := opts
+		if o.Window == 0 {
+			o.Window = anomalyWindows[b]
+		}
+		if o.Season == 0 {
+			o.Season = seasonLengths[b]
+		}
+		return o
+	}
+	lookback := func(b query.Bucket) int32 {
+		return int32(withDefaults(b).Lookback()) * b.MaxDays()
+	}
+	series, first, ok := s.buildSeries(w, r, lookback)
+	if !ok {
+		return
+	}
+	opts = withDefaults(series.Bucket)
+	if opts.Method == anomaly.Seasonal && opts.Season == 0 {
+		http.Error(w, fmt.Sprintf("%s periods have no default season: pass season", series.Bucket), http.StatusBadRequest)
+		return
+	}
+
+	values := series.Revenues()
+	if metric == "quantity" {
+		values = series.Quantities()
+	}
+	points, err := anomaly.Detect(values, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := Anomalies{
+		Granularity: string(series.Bucket),
+		TimeZone:    series.Location.String(),
+		Metric:      metric,
+		Method:      string(opts.Method),
+		Threshold:   opts.Threshold,
+		Points:      []AnomalyPoint{},
+	}
+	if opts.Method == anomaly.Seasonal {
+		result.Season = opts.Season
+	} else {
+		result.Window = opts.Window
+	}
+	for i := first; i < len(points); i++ {
+		p := points[i]
+		if !p.Scored {
+			continue
+		}
+		result.Checked++
+		if !p.Anomaly && !all {
+			continue
+		}
+		point := AnomalyPoint{
+			Period:   series.Label(i),
+			Start:    series.StartTime(i).Format(time.RFC3339),
+			Value:    values[i],
+			Expected: p.Expected,
+			Lower:    p.Lower,
+			Upper:    p.Upper,
+			Score:    p.Score,
+			Anomaly:  p.Anomaly,
+		}
+		if p.Anomaly {
+			point.Direction = "low"
+			if p.Score > 0 {
+				point.Direction = "high"
+			}
+		}
+		result.Points = append(result.Points, point)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,268 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"Dashlytics/internal/basket"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultAssociationLimit = 100
+	maxAssociationLimit     = 10000
+	defaultRelatedLimit     = 20
+	maxRelatedLimit         = 1000
+)
+
+// BasketProduct names a product of a basket
+type BasketProduct struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+}
+
+// FrequentItemset is a set of products often bought together
+type FrequentItemset struct {
+	Products []BasketProduct `json:"products"`
+	Baskets  int             `json:"baskets"`
+	Support  float64         `json:"support"` // share of all baskets
+}
+
+// AssociationRule says that baskets with the antecedent tend to hold the consequent
+type AssociationRule struct {
+	Antecedent []BasketProduct `json:"antecedent"`
+	Consequent BasketProduct   `json:"consequent"`
+	Baskets    int             `json:"baskets"` // holding both
+	Support    float64         `json:"support"`
+	Confidence float64         `json:"confidence"`
+	Lift       float64         `json:"lift"`
+}
+
+// Associations are the frequent itemsets and rules of the baskets
+type Associations struct {
+	Baskets       int               `json:"baskets"` // one per user and day
+	MinSupport    float64           `json:"min_support"`
+	MinConfidence float64           `json:"min_confidence"`
+	TotalItemsets int               `json:"total_itemsets"`
+	Itemsets      []FrequentItemset `json:"itemsets"`
+	TotalRules    int               `json:"total_rules"`
+	Rules         []AssociationRule `json:"rules"`
+}
+
+// RelatedProduct is a product bought together with another one
+type RelatedProduct struct {
+	BasketProduct
+	Baskets    int     `json:"baskets"`    // holding both
+	Confidence float64 `json:"confidence"` // share of the product's baskets holding this one
+	Lift       float64 `json:"lift"`
+}
+
+// RelatedProducts lists the products bought together with one product
+type RelatedProducts struct {
+	Product BasketProduct    `json:"product"`
+	Baskets int              `json:"baskets"` // holding the product
+	Related []RelatedProduct `json:"related"`
+}
+
+// buildBaskets groups the transactions of repo matching the filter of r into baskets.
+func buildBaskets(w http.ResponseWriter, r *http.Request, repo repository.Repository) (*basket.Baskets, bool) {
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return nil, false
+	}
+	var rows []int32 // nil: every row
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+	}
+	return basket.Build(repo.Columns(), rows), true
+}
+
+// productNames maps product codes to the name on their first row.
+func productNames(t *columnar.Table) []string {
+	names := make([]string, t.ProductID.Cardinality())
+	for i, code := range t.ProductID.Codes {
+		if names[code] == "" {
+			names[code] = t.ProductName.At(i)
+		}
+	}
+	return names
+}
+
+// AssociationsHandler godoc
+// @Summary Get products bought together
+// @Description Mines the frequent itemsets of the baskets, one per user and day, with FP-Growth, and the association rules with a single consequent. Itemsets are sorted by baskets, rules by the sort parameter.
+// @Tags products
+// @Produce json
+// @Param min_support query number false "Share of baskets an itemset must appear in; at least 2 baskets always" default(0.001)
+// @Param min_confidence query number false "Minimum confidence of a rule" default(0.1)
+// @Param max_length query int false "Largest itemset" default(3) minimum(2) maximum(4)
+// @Param sort query string false "Rule order" Enums(lift,confidence,support) default(lift)
+// @Param limit query int false "Itemsets and rules to return" default(100) maximum(10000)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} Associations
+// @Failure 400 {string} string "invalid parameter or too many itemsets"
+// @Router /associations [get]
+func (s *Server) GetAssociations(w http.ResponseWriter, r *http.Request) {
+	minSupport, ok := floatParam(w, r, "min_support", 0.001, 0, 1)
+	if !ok {
+		return
+	}
+	minConfidence, ok := floatParam(w, r, "min_confidence", 0.1, 0, 1)
+	if !ok {
+		return
+	}
+	maxLength, ok := intParam(w, r, "max_length", 3, 2, basket.MaxLength)
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, r, "limit", defaultAssociationLimit, 1, maxAssociationLimit)
+	if !ok {
+		return
+	}
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = "lift"
+	case "lift", "confidence", "support":
+	default:
+		http.Error(w, fmt.Sprintf("invalid sort %q: want lift, confidence or support", sortBy), http.StatusBadRequest)
+		return
+	}
+
+	repo := s.repos.Current()
+	baskets, ok := buildBaskets(w, r, repo)
+	if !ok {
+		return
+	}
+	t := repo.Columns()
+	itemsets, err := basket.Mine(baskets, basket.Options{MinSupport: minSupport, MaxLength: maxLength})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rules := basket.Rules(itemsets, baskets.Len(), minConfidence)
+	slices.SortStableFunc(rules, func(x, y basket.Rule) int {
+		a, b := x.Lift, y.Lift
+		switch sortBy {
+		case "confidence":
+			a, b = x.Confidence, y.Confidence
+		case "support":
+			a, b = x.Support, y.Support
+		}
+		switch {
+		case a > b:
+			return -1
+		case a < b:
+			return 1
+		}
+		return y.Count - x.Count
+	})
+
+	names := productNames(t)
+	product := func(code uint32) BasketProduct {
+		return BasketProduct{ProductID: t.ProductID.Values[code], ProductName: names[code]}
+	}
+	products := func(codes []uint32) []BasketProduct {
+		out := make([]BasketProduct, len(codes))
+		for i, c := range codes {
+			out[i] = product(c)
+		}
+		return out
+	}
+
+	result := Associations{
+		Baskets:       baskets.Len(),
+		MinSupport:    minSupport,
+		MinConfidence: minConfidence,
+		TotalRules:    len(rules),
+		Itemsets:      []FrequentItemset{},
+		Rules:         []AssociationRule{},
+	}
+	// single products are only there to derive rules
+	for _, set := range itemsets {
+		if len(set.Items) < 2 {
+			continue
+		}
+		result.TotalItemsets++
+		if len(result.Itemsets) < limit {
+			result.Itemsets = append(result.Itemsets, FrequentItemset{
+				Products: products(set.Items),
+				Baskets:  set.Count,
+				Support:  float64(set.Count) / float64(baskets.Len()),
+			})
+		}
+	}
+	for _, rule := range rules[:min(limit, len(rules))] {
+		result.Rules = append(result.Rules, AssociationRule{
+			Antecedent: products(rule.Antecedent),
+			Consequent: product(rule.Consequent),
+			Baskets:    rule.Count,
+			Support:    rule.Support,
+			Confidence: rule.Confidence,
+			Lift:       rule.Lift,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RelatedProductsHandler godoc
+// @Summary Get products bought with a product
+// @Description Lists the products that share baskets, one per user and day, with the given product, by descending lift.
+// @Tags products
+// @Produce json
+// @Param productID path string true "Product ID"
+// @Param min_count query int false "Baskets the products must share" default(2)
+// @Param limit query int false "Products to return" default(20) maximum(1000)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} RelatedProducts
+// @Failure 400 {string} string "invalid parameter"
+// @Failure 404 {string} string "unknown product"
+// @Router /products/{productID}/related [get]
+func (s *Server) GetRelatedProducts(w http.ResponseWriter, r *http.Request) {
+	minCount, ok := intParam(w, r, "min_count", 2, 1, maxAssociationLimit)
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, r, "limit", defaultRelatedLimit, 1, maxRelatedLimit)
+	if !ok {
+		return
+	}
+	repo := s.repos.Current()
+	t := repo.Columns()
+	id := chi.URLParam(r, "productID")
+	code, known := t.ProductID.Code(id)
+	if !known {
+		http.Error(w, fmt.Sprintf("unknown product %q", id), http.StatusNotFound)
+		return
+	}
+	baskets, ok := buildBaskets(w, r, repo)
+	if !ok {
+		return
+	}
+
+	names := productNames(t)
+	related := basket.RelatedTo(baskets, code, minCount)
+	result := RelatedProducts{
+		Product: BasketProduct{ProductID: id, ProductName: names[code]},
+		Baskets: baskets.Counts()[code],
+		Related: make([]RelatedProduct, 0, min(limit, len(related))),
+	}
+	for _, rel := range related[:min(limit, len(related))] {
+		result.Related = append(result.Related, RelatedProduct{
+			BasketProduct: BasketProduct{ProductID: t.ProductID.Values[rel.Product], ProductName: names[rel.Product]},
+			Baskets:       rel.Count,
+			Confidence:    rel.Confidence,
+			Lift:          rel.Lift,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
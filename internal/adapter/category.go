@@ -0,0 +1,290 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultCategoryProducts = 100
+	maxCategoryProducts     = 10000
+)
+
+// CategoryStats sums up the sales of one category
+type CategoryStats struct {
+	Category        string  `json:"category"`
+	Revenue         float64 `json:"revenue"`
+	RevenueShare    float64 `json:"revenue_share"`
+	Units           int     `json:"units"`
+	UnitShare       float64 `json:"unit_share"`
+	AvgSellingPrice float64 `json:"avg_selling_price"` // revenue per unit
+	Products        int     `json:"products"`          // distinct products sold
+	Transactions    int     `json:"transactions"`
+}
+
+// CategoryBreakdown splits the sales by category
+type CategoryBreakdown struct {
+	Revenue    float64         `json:"revenue"`
+	Units      int             `json:"units"`
+	Categories []CategoryStats `json:"categories"`
+}
+
+// CategoryProduct sums up the sales of one product of a category
+type CategoryProduct struct {
+	ProductID       string  `json:"product_id"`
+	ProductName     string  `json:"product_name"`
+	Revenue         float64 `json:"revenue"`
+	RevenueShare    float64 `json:"revenue_share"` // of the category
+	Units           int     `json:"units"`
+	AvgSellingPrice float64 `json:"avg_selling_price"`
+	Transactions    int     `json:"transactions"`
+}
+
+// CategoryProducts lists the products of one category, highest revenue first
+type CategoryProducts struct {
+	Category      string            `json:"category"`
+	Revenue       float64           `json:"revenue"`
+	Units         int               `json:"units"`
+	TotalProducts int               `json:"total_products"`
+	Products      []CategoryProduct `json:"products"`
+}
+
+// CategorySeries is the monthly sales of one category
+type CategorySeries struct {
+	Category string    `json:"category"`
+	Total    float64   `json:"total_revenue"`
+	Revenue  []float64 `json:"revenue"` // indexed like CategoryMonths.Months
+	Units    []int     `json:"units"`
+}
+
+// CategoryMonths is a category by month matrix
+type CategoryMonths struct {
+	Months     []string         `json:"months"` // YYYY-MM, every month from the first sale to the last
+	Categories []CategorySeries `json:"categories"`
+}
+
+// avgSellingPrice is revenue per unit, zero without units.
+func avgSellingPrice(revenue float64, units int) float64 {
+	if units == 0 {
+		return 0
+	}
+	return revenue / float64(units)
+}
+
+// CategoriesHandler godoc
+// @Summary Get sales per category
+// @Description Returns the revenue, units, average selling price, number of products sold and share of the total of each category.
+// @Tags categories
+// @Produce json
+// @Param sort query string false "Category order, descending except for name" Enums(revenue,units,products,name) default(revenue)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} CategoryBreakdown
+// @Failure 400 {string} string "invalid parameter"
+// @Router /categories [get]
+func (s *Server) GetCategories(w http.ResponseWriter, r *http.Request) {
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = "revenue"
+	case "revenue", "units", "products", "name":
+	default:
+		http.Error(w, fmt.Sprintf("invalid sort %q: want revenue, units, products or name", sortBy), http.StatusBadRequest)
+		return
+	}
+	groups, ok := s.dashboard(w, r, aggregate.CategoryProducts, func(a *aggregate.Set) *query.Result { return a.CategoryProducts })
+	if !ok {
+		return
+	}
+
+	result := CategoryBreakdown{Categories: []CategoryStats{}}
+	index := make(map[string]int)
+	for _, g := range groups.Rows {
+		name := g[0].(string)
+		k, seen := index[name]
+		if !seen {
+			k = len(result.Categories)
+			index[name] = k
+			result.Categories = append(result.Categories, CategoryStats{Category: name})
+		}
+		c := &result.Categories[k]
+		c.Revenue += g[2].(float64)
+		c.Units += int(g[3].(int64))
+		c.Transactions += int(g[4].(int64))
+		c.Products++
+		result.Revenue += g[2].(float64)
+		result.Units += int(g[3].(int64))
+	}
+	for i := range result.Categories {
+		c := &result.Categories[i]
+		c.AvgSellingPrice = avgSellingPrice(c.Revenue, c.Units)
+		if result.Revenue != 0 {
+			c.RevenueShare = c.Revenue / result.Revenue
+		}
+		if result.Units != 0 {
+			c.UnitShare = float64(c.Units) / float64(result.Units)
+		}
+	}
+	sort.SliceStable(result.Categories, func(i, j int) bool {
+		a, b := result.Categories[i], result.Categories[j]
+		switch sortBy {
+		case "units":
+			if a.Units != b.Units {
+				return a.Units > b.Units
+			}
+		case "products":
+			if a.Products != b.Products {
+				return a.Products > b.Products
+			}
+		case "revenue":
+			if a.Revenue != b.Revenue {
+				return a.Revenue > b.Revenue
+			}
+		}
+		return a.Category < b.Category
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CategoryProductsHandler godoc
+// @Summary Get the products of a category
+// @Description Drills down from a category to its products, highest revenue first, with their share of the category's revenue. A category filter that leaves the category out matches no products.
+// @Tags categories
+// @Produce json
+// @Param category path string true "Category"
+// @Param limit query int false "Products to return" default(100) maximum(10000)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} CategoryProducts
+// @Failure 400 {string} string "invalid parameter"
+// @Failure 404 {string} string "unknown category"
+// @Router /categories/{category}/products [get]
+func (s *Server) GetCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	limit, ok := intParam(w, r, "limit", defaultCategoryProducts, 1, maxCategoryProducts)
+	if !ok {
+		return
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+	repo := s.repos.Current()
+	t := repo.Columns()
+	category := chi.URLParam(r, "category")
+	if _, known := t.Category.Code(category); !known {
+		http.Error(w, fmt.Sprintf("unknown category %q", category), http.StatusNotFound)
+		return
+	}
+	// the category index narrows the rows down; a category filter that
+	// leaves the category out matches nothing
+	rows := []int32{}
+	if len(f.Categories) == 0 || slices.Contains(f.Categories, category) {
+		f.Categories = []string{category}
+		rows = f.Select(repo)
+	}
+	groups, err := query.Execute(t, rows, aggregate.CategoryProducts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	names := productNames(t)
+	result := CategoryProducts{Category: category, Products: []CategoryProduct{}}
+	for _, g := range groups.Rows {
+		p := CategoryProduct{
+			ProductID:    g[1].(string),
+			Revenue:      g[2].(float64),
+			Units:        int(g[3].(int64)),
+			Transactions: int(g[4].(int64)),
+		}
+		if code, ok := t.ProductID.Code(p.ProductID); ok {
+			p.ProductName = names[code]
+		}
+		p.AvgSellingPrice = avgSellingPrice(p.Revenue, p.Units)
+		result.Revenue += p.Revenue
+		result.Units += p.Units
+		result.TotalProducts++
+		if len(result.Products) < limit {
+			result.Products = append(result.Products, p)
+		}
+	}
+	for i := range result.Products {
+		if result.Revenue != 0 {
+			result.Products[i].RevenueShare = result.Products[i].Revenue / result.Revenue
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CategoryMonthsHandler godoc
+// @Summary Get monthly sales per category
+// @Description Returns a category by month matrix of revenue and units, e.g. for a stacked area chart. Every month from the first sale to the last is present, zero when a category had no sales. Categories come by total revenue, highest first.
+// @Tags categories
+// @Produce json
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} CategoryMonths
+// @Failure 400 {string} string "invalid filter"
+// @Router /categories/months [get]
+func (s *Server) GetCategoryMonths(w http.ResponseWriter, r *http.Request) {
+	groups, ok := s.dashboard(w, r, aggregate.CategoryMonths, func(a *aggregate.Set) *query.Result { return a.CategoryMonths })
+	if !ok {
+		return
+	}
+
+	// month labels sort chronologically
+	result := CategoryMonths{Months: []string{}, Categories: []CategorySeries{}}
+	first, last := "", ""
+	for _, g := range groups.Rows {
+		m := g[1].(string)
+		if first == "" || m < first {
+			first = m
+		}
+		last = max(last, m)
+	}
+	column := make(map[string]int)
+	if first != "" {
+		lo, _ := time.Parse("2006-01", first)
+		hi, _ := time.Parse("2006-01", last)
+		for m := columnar.MonthIndex(columnar.DayOf(lo)); m <= columnar.MonthIndex(columnar.DayOf(hi)); m++ {
+			column[columnar.FormatMonth(m)] = len(result.Months)
+			result.Months = append(result.Months, columnar.FormatMonth(m))
+		}
+	}
+
+	index := make(map[string]int)
+	for _, g := range groups.Rows {
+		name := g[0].(string)
+		k, seen := index[name]
+		if !seen {
+			k = len(result.Categories)
+			index[name] = k
+			result.Categories = append(result.Categories, CategorySeries{
+				Category: name,
+				Revenue:  make([]float64, len(result.Months)),
+				Units:    make([]int, len(result.Months)),
+			})
+		}
+		c, m := &result.Categories[k], column[g[1].(string)]
+		c.Revenue[m] += g[2].(float64)
+		c.Units[m] += int(g[3].(int64))
+		c.Total += g[2].(float64)
+	}
+	sort.SliceStable(result.Categories, func(i, j int) bool {
+		return result.Categories[i].Total > result.Categories[j].Total
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
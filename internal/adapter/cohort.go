@@ -0,0 +1,96 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"Dashlytics/internal/cohort"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+)
+
+// CohortRow follows the users who first bought in one period
+type CohortRow struct {
+	Cohort  string  `json:"cohort"` // 2024-01, 2024-W05 or 2024-Q1
+	Start   string  `json:"start"`  // YYYY-MM-DD
+	Users   int     `json:"users"`
+	Revenue float64 `json:"revenue"` // over every period
+	// indexed by periods since the first: [0] is the cohort's first period
+	ActiveUsers   []int     `json:"active_users"`
+	Retention     []float64 `json:"retention"` // active_users over users
+	PeriodRevenue []float64 `json:"period_revenue"`
+}
+
+// CohortRetention is a retention matrix, one row per cohort
+type CohortRetention struct {
+	Granularity string      `json:"granularity"`
+	Periods     int         `json:"periods"` // length of the oldest cohort's row
+	Cohorts     []CohortRow `json:"cohorts"`
+}
+
+// CohortsHandler godoc
+// @Summary Get cohort retention
+// @Description Groups users by the period of their first purchase and returns, per cohort, the share of users active and the revenue in each later period. Rows end at the last period of the data, so newer cohorts have shorter rows. date_from keeps only users whose first matching purchase is on or after it, date_to leaves out later purchases.
+// @Tags customers
+// @Produce json
+// @Param granularity query string false "Cohort and period length" Enums(week,month,quarter) default(month)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} CohortRetention
+// @Failure 400 {string} string "invalid parameter"
+// @Router /cohorts [get]
+func (s *Server) GetCohorts(w http.ResponseWriter, r *http.Request) {
+	opts := cohort.Options{Bucket: query.Month}
+	if g := r.URL.Query().Get("granularity"); g != "" {
+		b, err := query.ParseBucket(g)
+		if err == nil && (b == query.Day || b == query.Year) {
+			err = fmt.Errorf("cohorts are by week, month or quarter")
+		}
+		if err != nil {
+			http.Error(w, "invalid granularity: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Bucket = b
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+	// a user's first purchase is looked for before date_from too, so the
+	// date range is applied to the cohorts rather than the rows
+	opts.From, opts.To = f.DateFrom, f.DateTo
+	f.DateFrom, f.DateTo = nil, nil
+
+	repo := s.repos.Current()
+	var rows []int32 // nil: every row
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+	}
+	cohorts, err := cohort.Build(repo.Columns(), rows, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := CohortRetention{Granularity: string(opts.Bucket), Cohorts: make([]CohortRow, len(cohorts))}
+	for i := range cohorts {
+		c := &cohorts[i]
+		row := CohortRow{
+			Cohort:        opts.Bucket.Format(c.Start),
+			Start:         columnar.DayTime(c.Start).Format("2006-01-02"),
+			Users:         c.Users,
+			ActiveUsers:   c.Active,
+			Retention:     make([]float64, len(c.Active)),
+			PeriodRevenue: c.Revenue,
+		}
+		for k := range c.Active {
+			row.Retention[k] = c.Retention(k)
+			row.Revenue += c.Revenue[k]
+		}
+		result.Cohorts[i] = row
+		result.Periods = max(result.Periods, len(c.Active))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
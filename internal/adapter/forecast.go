@@ -0,0 +1,167 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Dashlytics/internal/forecast"
+	"Dashlytics/internal/query"
+	"Dashlytics/internal/timeseries"
+)
+
+// maxHorizon bounds how far ahead a forecast reaches.
+const maxHorizon = 366
+
+// seasonLengths are the default periods per season: a week of days, a year otherwise.
+var seasonLengths = map[query.Bucket]int{
+	query.Day:     7,
+	query.Week:    52,
+	query.Month:   12,
+	query.Quarter: 4,
+}
+
+// ForecastPoint is the projection of one future period
+type ForecastPoint struct {
+	Period string  `json:"period"`
+	Start  string  `json:"start"` // RFC 3339, at the UTC offset of tz
+	Value  float64 `json:"value"`
+	Lower  float64 `json:"lower"` // prediction interval
+	Upper  float64 `json:"upper"`
+}
+
+// BacktestAccuracy is the error of forecasting the last periods from the ones before
+type BacktestAccuracy struct {
+	Periods int      `json:"periods"`
+	MAPE    *float64 `json:"mape"` // percent; null when every actual value is zero
+	RMSE    float64  `json:"rmse"`
+}
+
+// SmoothingParameters are the fitted Holt-Winters parameters
+type SmoothingParameters struct {
+	Alpha float64 `json:"alpha"` // level
+	Beta  float64 `json:"beta"`  // trend
+	Gamma float64 `json:"gamma"` // season
+}
+
+// ForecastSeries is the forecast of one metric
+type ForecastSeries struct {
+	Points     []ForecastPoint      `json:"points"`
+	Backtest   *BacktestAccuracy    `json:"backtest"` // null when the history is too short
+	Parameters *SmoothingParameters `json:"parameters,omitempty"`
+}
+
+// Forecast projects revenue and quantity sold
+type Forecast struct {
+	Granularity    string         `json:"granularity"`
+	TimeZone       string         `json:"tz"`
+	Method         string         `json:"method"`
+	SeasonLength   int            `json:"season_length"`
+	Level          float64        `json:"level"`
+	HistoryPeriods int            `json:"history_periods"`
+	Revenue        ForecastSeries `json:"revenue"`
+	Quantity       ForecastSeries `json:"quantity"`
+}
+
+// ForecastHandler godoc
+// @Summary Forecast sales
+// @Description Projects revenue and quantity sold for the next periods with Holt-Winters smoothing or a seasonal naive baseline, with prediction intervals and the error of a backtest on the last periods. Filter by country, region or product_id to forecast that series instead of the global one. date_from and date_to bound the history the model is fitted to: use date_to to leave out an incomplete last period.
+// @Tags sales
+// @Produce json
+// @Param granularity query string false "Period length" Enums(day,week,month,quarter) default(month)
+// @Param tz query string false "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone." example(America/New_York)
+// @Param horizon query int false "Periods to project" default(12) minimum(1) maximum(366)
+// @Param method query string false "Forecasting method" Enums(holt_winters,seasonal_naive) default(holt_winters)
+// @Param season query int false "Periods per season; 7 for days, 52 for weeks, 12 for months and 4 for quarters by default"
+// @Param level query number false "Coverage of the prediction intervals" default(0.95)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} Forecast
+// @Failure 400 {string} string "invalid parameter or too little history"
+// @Router /forecast [get]
+func (s *Server) GetForecast(w http.ResponseWriter, r *http.Request) {
+	opts := forecast.Options{Method: forecast.HoltWinters, Level: 0.95, NonNegative: true}
+	var ok bool
+	if opts.Horizon, ok = intParam(w, r, "horizon", 12, 1, maxHorizon); !ok {
+		return
+	}
+	if m := r.URL.Query().Get("method"); m != "" {
+		method, err := forecast.ParseMethod(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Method = method
+	}
+	if l := r.URL.Query().Get("level"); l != "" {
+		level, err := strconv.ParseFloat(l, 64)
+		if err != nil || level <= 0 || level >= 1 {
+			http.Error(w, fmt.Sprintf("invalid level %q: want a number between 0 and 1, e.g. 0.95", l), http.StatusBadRequest)
+			return
+		}
+		opts.Level = level
+	}
+	if opts.Season, ok = intParam(w, r, "season", 0, 2, timeseries.MaxPoints); !ok {
+		return
+	}
+
+	series, _, ok := s.buildSeries(w, r, nil)
+	if !ok {
+		return
+	}
+	if opts.Season == 0 {
+		if opts.Season = seasonLengths[series.Bucket]; opts.Season == 0 {
+			http.Error(w, fmt.Sprintf("%s periods have no default season: pass season", series.Bucket), http.StatusBadRequest)
+			return
+		}
+	}
+
+	history := len(series.Points)
+	revenue, err := forecast.Project(series.Revenues(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	quantity, err := forecast.Project(series.Quantities(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series.Extend(opts.Horizon)
+	result := Forecast{
+		Granularity:    string(series.Bucket),
+		TimeZone:       series.Location.String(),
+		Method:         string(opts.Method),
+		SeasonLength:   opts.Season,
+		Level:          opts.Level,
+		HistoryPeriods: history,
+		Revenue:        forecastSeries(series, history, revenue),
+		Quantity:       forecastSeries(series, history, quantity),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// forecastSeries labels the predictions of f with the periods of series
+// from index first on.
+func forecastSeries(series *timeseries.Series, first int, f *forecast.Forecast) ForecastSeries {
+	out := ForecastSeries{Points: make([]ForecastPoint, len(f.Predictions))}
+	for h, p := range f.Predictions {
+		out.Points[h] = ForecastPoint{
+			Period: series.Label(first + h),
+			Start:  series.StartTime(first + h).Format(time.RFC3339),
+			Value:  p.Value,
+			Lower:  p.Lower,
+			Upper:  p.Upper,
+		}
+	}
+	if b := f.Backtest; b != nil {
+		out.Backtest = &BacktestAccuracy{Periods: b.Periods, MAPE: b.MAPE, RMSE: b.RMSE}
+	}
+	if f.Method == forecast.HoltWinters {
+		out.Parameters = &SmoothingParameters{Alpha: f.Alpha, Beta: f.Beta, Gamma: f.Gamma}
+	}
+	return out
+}
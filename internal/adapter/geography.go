@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/query"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GeoNode is a country or region with its sales rolled up, or the total
+// of all countries at the root
+type GeoNode struct {
+	Name         string  `json:"name"`
+	Level        string  `json:"level"` // total, country or region
+	Revenue      float64 `json:"revenue"`
+	Units        int     `json:"units"`
+	Transactions int     `json:"transactions"`
+	// shares of the parent's revenue and units, 1 at the root
+	RevenueShare float64 `json:"revenue_share"`
+	UnitShare    float64 `json:"unit_share"`
+	// ChildCount is the number of nodes below, which Children holds when
+	// the node is expanded
+	ChildCount int       `json:"child_count"`
+	Children   []GeoNode `json:"children,omitempty"`
+}
+
+// add rolls one group's sales up into n.
+func (n *GeoNode) add(revenue float64, units, transactions int) {
+	n.Revenue += revenue
+	n.Units += units
+	n.Transactions += transactions
+}
+
+// setShares fills in the shares of n's children, recursively.
+func (n *GeoNode) setShares() {
+	for i := range n.Children {
+		c := &n.Children[i]
+		if n.Revenue != 0 {
+			c.RevenueShare = c.Revenue / n.Revenue
+		}
+		if n.Units != 0 {
+			c.UnitShare = float64(c.Units) / float64(n.Units)
+		}
+		c.setShares()
+	}
+}
+
+// geographyTree rolls the rows of the CountryRegions query up into a tree,
+// every level sorted by revenue, highest first.
+func geographyTree(groups *query.Result) GeoNode {
+	root := GeoNode{Name: "All", Level: "total", RevenueShare: 1, UnitShare: 1}
+	index := make(map[string]int)
+	for _, g := range groups.Rows {
+		country := g[0].(string)
+		revenue, units, transactions := g[2].(float64), int(g[3].(int64)), int(g[4].(int64))
+		k, seen := index[country]
+		if !seen {
+			k = len(root.Children)
+			index[country] = k
+			root.Children = append(root.Children, GeoNode{Name: country, Level: "country"})
+		}
+		c := &root.Children[k]
+		// groups come by revenue, so regions are in order already
+		c.Children = append(c.Children, GeoNode{Name: g[1].(string), Level: "region"})
+		c.Children[len(c.Children)-1].add(revenue, units, transactions)
+		c.ChildCount++
+		c.add(revenue, units, transactions)
+		root.add(revenue, units, transactions)
+	}
+	sort.SliceStable(root.Children, func(i, j int) bool {
+		return root.Children[i].Revenue > root.Children[j].Revenue
+	})
+	root.ChildCount = len(root.Children)
+	root.setShares()
+	return root
+}
+
+// GeographyHandler godoc
+// @Summary Get the country and region tree
+// @Description Returns the total of all countries with a node per country, holding revenue, units and transactions rolled up from their regions, and each node's share of its parent. Countries are collapsed unless expand is true: fetch /geography/{country} to expand one of them.
+// @Tags revenue
+// @Produce json
+// @Param expand query bool false "Include every country's regions" default(false)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} GeoNode
+// @Failure 400 {string} string "invalid parameter"
+// @Router /geography [get]
+func (s *Server) GetGeography(w http.ResponseWriter, r *http.Request) {
+	expand, ok := boolParam(w, r, "expand")
+	if !ok {
+		return
+	}
+	groups, ok := s.dashboard(w, r, aggregate.CountryRegions, func(a *aggregate.Set) *query.Result { return a.CountryRegions })
+	if !ok {
+		return
+	}
+
+	result := geographyTree(groups)
+	if !expand {
+		for i := range result.Children {
+			result.Children[i].Children = nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GeographyCountryHandler godoc
+// @Summary Expand one country
+// @Description Returns one country of the /geography tree with its regions. The country's shares are of the total of all countries, its regions' shares of the country.
+// @Tags revenue
+// @Produce json
+// @Param country path string true "Country"
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} GeoNode
+// @Failure 400 {string} string "invalid parameter"
+// @Failure 404 {string} string "unknown country"
+// @Router /geography/{country} [get]
+func (s *Server) GetGeographyCountry(w http.ResponseWriter, r *http.Request) {
+	repo := s.repos.Current()
+	country := chi.URLParam(r, "country")
+	if _, known := repo.Columns().Country.Code(country); !known {
+		http.Error(w, fmt.Sprintf("unknown country %q", country), http.StatusNotFound)
+		return
+	}
+	groups, ok := dashboardOf(w, r, repo, aggregate.CountryRegions, func(a *aggregate.Set) *query.Result { return a.CountryRegions })
+	if !ok {
+		return
+	}
+
+	// a country without matching sales is an empty node
+	result := GeoNode{Name: country, Level: "country"}
+	for _, c := range geographyTree(groups).Children {
+		if c.Name == country {
+			result = c
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
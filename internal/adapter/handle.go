@@ -2,13 +2,145 @@ package adapter
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"sort"
+	"slices"
 	"strconv"
 
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/clv"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/filter"
+	"Dashlytics/internal/query"
 	"Dashlytics/internal/repository"
 )
 
+// Server serves the analytics endpoints from the transactions its Provider hands out.
+type Server struct {
+	repos repository.Provider
+	// fits caches the CLV model of the current dataset
+	fits clv.Cache
+}
+
+// NewServer returns a Server reading from repos.
+func NewServer(repos repository.Provider) *Server {
+	return &Server{repos: repos}
+}
+
+// FilterParams documents the filter parameters every analytics endpoint
+// takes; parseFilter reads them. Lists are comma-separated or repeated.
+type FilterParams struct {
+	Country   string  `form:"country"`                        // only transactions in these countries
+	Region    string  `form:"region"`                         // only transactions in these regions
+	Category  string  `form:"category"`                       // only transactions in these categories
+	ProductID string  `form:"product_id"`                     // only transactions of these product IDs
+	UserID    string  `form:"user_id"`                        // only transactions of these users
+	DateFrom  string  `form:"date_from" example:"2024-01-01"` // only transactions on or after this date (YYYY-MM-DD)
+	DateTo    string  `form:"date_to" example:"2024-12-31"`   // only transactions on or before this date (YYYY-MM-DD)
+	MinPrice  float64 `form:"min_price"`                      // only transactions with a unit price of at least this
+	MaxPrice  float64 `form:"max_price"`                      // only transactions with a unit price of at most this
+}
+
+// parseFilter reads the shared filter parameters, answering 400 when they are invalid.
+func parseFilter(w http.ResponseWriter, r *http.Request) (filter.Filter, bool) {
+	f, err := filter.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+	return f, true
+}
+
+// intParam reads an integer parameter between lo and hi, def when absent,
+// answering 400 when it is invalid.
+func intParam(w http.ResponseWriter, r *http.Request, name string, def, lo, hi int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		http.Error(w, fmt.Sprintf("invalid %s %q: want an integer between %d and %d", name, v, lo, hi), http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// floatParam reads a number between lo and hi, def when absent, answering
+// 400 when it is invalid.
+func floatParam(w http.ResponseWriter, r *http.Request, name string, def, lo, hi float64) (float64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < lo || f > hi {
+		http.Error(w, fmt.Sprintf("invalid %s %q: want a number between %g and %g", name, v, lo, hi), http.StatusBadRequest)
+		return 0, false
+	}
+	return f, true
+}
+
+// boolParam reads a true/false parameter, false when absent, answering 400
+// when it is invalid.
+func boolParam(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid %s %q: want true or false", name, v), http.StatusBadRequest)
+		return false, false
+	}
+	return b, true
+}
+
+// referenceDay reads the reference_date parameter, defaulting to the
+// filter's date_to or else the last day of t, answering 400 when it is
+// invalid.
+func referenceDay(w http.ResponseWriter, r *http.Request, f filter.Filter, t *columnar.Table) (int32, bool) {
+	switch ref := r.URL.Query().Get("reference_date"); {
+	case ref != "":
+		d, err := domain.ParseDate(ref)
+		if err != nil {
+			http.Error(w, "invalid reference_date: "+err.Error(), http.StatusBadRequest)
+			return 0, false
+		}
+		return columnar.DayOf(d), true
+	case f.DateTo != nil:
+		return *f.DateTo, true
+	case t.Len() > 0:
+		return slices.Max(t.Date), true
+	}
+	return 0, true
+}
+
+// dashboard answers one of the dashboard queries. Unfiltered requests read
+// the result materialized at load time, filtered ones run q over the
+// matching rows.
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request, q query.Query, materialized func(*aggregate.Set) *query.Result) (*query.Result, bool) {
+	return dashboardOf(w, r, s.repos.Current(), q, materialized)
+}
+
+// dashboardOf is dashboard over repo, for handlers that read repo themselves.
+func dashboardOf(w http.ResponseWriter, r *http.Request, repo repository.Repository, q query.Query, materialized func(*aggregate.Set) *query.Result) (*query.Result, bool) {
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return nil, false
+	}
+	if f.IsEmpty() {
+		return materialized(repo.Aggregates()), true
+	}
+	res, err := query.Execute(repo.Columns(), f.Select(repo), q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return res, true
+}
+
 // CountryRevenue represents revenue data for a country and product
 type CountryRevenue struct {
 	Country          string  `json:"country"`
@@ -22,42 +154,17 @@ type CountryRevenue struct {
 // @Description Returns a list of countries with total revenue and transaction count per product
 // @Tags revenue
 // @Produce json
+// @Param filter query FilterParams false "Transaction filters"
 // @Success 200 {array} adapter.CountryRevenue
+// @Failure 400 {string} string "invalid filter"
 // @Router /country-revenue [get]
-func GetCountryRevenue(w http.ResponseWriter, r *http.Request) {
-	data := repository.GlobalDataStore
-	countryRevenueMap := map[string]map[string]*CountryRevenue{}
-
-	//aggreegate Data
-	for _, t := range data.AllTransactions {
-		if _, ok := countryRevenueMap[t.Country]; !ok {
-			countryRevenueMap[t.Country] = make(map[string]*CountryRevenue)
-		}
-		productMap := countryRevenueMap[t.Country]
-		if _, ok := productMap[t.ProductName]; !ok {
-			productMap[t.ProductName] = &CountryRevenue{
-				Country:          t.Country,
-				ProductName:      t.ProductName,
-				TotalRevenue:     0,
-				TransactionCount: 0,
-			}
-		}
-		productMap[t.ProductName].TotalRevenue += t.TotalPrice
-		productMap[t.ProductName].TransactionCount++
-	}
-
-	//flatten and sort data
-	var result []CountryRevenue
-	for _, productMap := range countryRevenueMap {
-		for _, entry := range productMap {
-			result = append(result, *entry)
-		}
+func (s *Server) GetCountryRevenue(w http.ResponseWriter, r *http.Request) {
+	// sorted by revenue
+	groups, ok := s.dashboard(w, r, aggregate.CountryProducts, func(a *aggregate.Set) *query.Result { return a.CountryProducts })
+	if !ok {
+		return
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].TotalRevenue > result[j].TotalRevenue
-	})
-
 	//Get "limit" from query param
 	limit := 100 // default
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -65,8 +172,16 @@ func GetCountryRevenue(w http.ResponseWriter, r *http.Request) {
 			limit = parsed
 		}
 	}
-	if len(result) > limit {
-		result = result[:limit]
+	groups = groups.Head(limit)
+
+	result := make([]CountryRevenue, len(groups.Rows))
+	for i, g := range groups.Rows {
+		result[i] = CountryRevenue{
+			Country:          g[0].(string),
+			ProductName:      g[1].(string),
+			TotalRevenue:     g[2].(float64),
+			TransactionCount: int(g[3].(int64)),
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -86,34 +201,17 @@ type TopProduct struct {
 // @Description Returns top products with quantity sold and stock
 // @Tags products
 // @Produce json
+// @Param filter query FilterParams false "Transaction filters"
 // @Success 200 {array} TopProduct
+// @Failure 400 {string} string "invalid filter"
 // @Router /top-products [get]
-func GetTopProducts(w http.ResponseWriter, r *http.Request) {
-	data := repository.GlobalDataStore
-	topProductsMap := make(map[string]*TopProduct)
-
-	// Aggregate data
-	for _, t := range data.AllTransactions {
-		if _, ok := topProductsMap[t.ProductName]; !ok {
-			topProductsMap[t.ProductName] = &TopProduct{
-				ProductName:       t.ProductName,
-				TotalQuantitySold: 0,
-				StockQuantity:     t.Stock,
-			}
-		}
-		topProductsMap[t.ProductName].TotalQuantitySold += t.Quantity
-	}
-
-	//flatten and sort data
-	var result []TopProduct
-	for _, entry := range topProductsMap {
-		result = append(result, *entry)
+func (s *Server) GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	// sorted by quantity sold
+	products, ok := s.dashboard(w, r, aggregate.Products, func(a *aggregate.Set) *query.Result { return a.Products })
+	if !ok {
+		return
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].TotalQuantitySold > result[j].TotalQuantitySold
-	})
-
 	// Limit to top 20 products
 	limit := 20
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -121,8 +219,15 @@ func GetTopProducts(w http.ResponseWriter, r *http.Request) {
 			limit = parsed
 		}
 	}
-	if len(result) > limit {
-		result = result[:limit]
+	products = products.Head(limit)
+
+	result := make([]TopProduct, len(products.Rows))
+	for i, p := range products.Rows {
+		result[i] = TopProduct{
+			ProductName:       p[0].(string),
+			TotalQuantitySold: int(p[1].(int64)),
+			StockQuantity:     int(p[2].(int64)),
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -144,26 +249,14 @@ type MonthlySales struct {
 // @Produce json
 // @Param sort query string false "Sort by 'month' or 'sales'" Enums(month,sales)
 // @Param order query string false "Sort order: 'asc' or 'desc'" Enums(asc,desc)
+// @Param filter query FilterParams false "Transaction filters"
 // @Success 200 {array} MonthlySales
+// @Failure 400 {string} string "invalid filter"
 // @Router /monthly-sales [get]
-func GetMonthlySales(w http.ResponseWriter, r *http.Request) {
-	data := repository.GlobalDataStore
-	salesMap := make(map[string]*MonthlySales)
-
-	//Group by month
-	for _, t := range data.AllTransactions {
-		monthKey := t.Date.Format("2006-01") // YYYY-MM format
-		if _, ok := salesMap[monthKey]; !ok {
-			salesMap[monthKey] = &MonthlySales{Month: monthKey}
-		}
-		salesMap[monthKey].TotalQuantitySold += t.Quantity
-		salesMap[monthKey].TotalRevenue += t.TotalPrice
-	}
-
-	//convert to slice
-	var result []MonthlySales
-	for _, v := range salesMap {
-		result = append(result, *v)
+func (s *Server) GetMonthlySales(w http.ResponseWriter, r *http.Request) {
+	months, ok := s.dashboard(w, r, aggregate.Months, func(a *aggregate.Set) *query.Result { return a.Months })
+	if !ok {
+		return
 	}
 
 	//parse sort and order query params
@@ -171,20 +264,24 @@ func GetMonthlySales(w http.ResponseWriter, r *http.Request) {
 	order := r.URL.Query().Get("order") // "asc" or "desc"
 
 	// Default behavior: sort by sales descending
+	by := "quantity"
 	if sortBy == "month" {
-		sort.Slice(result, func(i, j int) bool {
-			if order == "asc" {
-				return result[i].Month < result[j].Month
-			}
-			return result[i].Month > result[j].Month
-		})
-	} else {
-		sort.Slice(result, func(i, j int) bool {
-			if order == "asc" {
-				return result[i].TotalQuantitySold < result[j].TotalQuantitySold
-			}
-			return result[i].TotalQuantitySold > result[j].TotalQuantitySold
-		})
+		by = "date:month"
+	}
+	months, err := months.Sorted([]query.Order{{By: by, Desc: order != "asc"}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	//convert to slice
+	result := make([]MonthlySales, len(months.Rows))
+	for i, m := range months.Rows {
+		result[i] = MonthlySales{
+			Month:             m[0].(string), // YYYY-MM format
+			TotalQuantitySold: int(m[1].(int64)),
+			TotalRevenue:      m[2].(float64),
+		}
 	}
 
 	//Get "limit" from query param
@@ -212,36 +309,17 @@ type RegionStats struct {
 // @Description Returns regions with highest sales and revenue
 // @Tags regions
 // @Produce json
+// @Param filter query FilterParams false "Transaction filters"
 // @Success 200 {array} RegionStats
+// @Failure 400 {string} string "invalid filter"
 // @Router /top-regions [get]
-func GetTopRegions(w http.ResponseWriter, r *http.Request) {
-	data := repository.GlobalDataStore
-	regionMap := make(map[string]*RegionStats)
-
-	// Aggregate data
-	for _, t := range data.AllTransactions {
-		if _, ok := regionMap[t.Region]; !ok {
-			regionMap[t.Region] = &RegionStats{
-				Region:        t.Region,
-				TotalRevenue:  0,
-				TotalItemSold: 0,
-			}
-		}
-		regionMap[t.Region].TotalRevenue += t.TotalPrice
-		regionMap[t.Region].TotalItemSold += t.Quantity
-	}
-
-	// Convert to slice
-	var result []RegionStats
-	for _, entry := range regionMap {
-		result = append(result, *entry)
+func (s *Server) GetTopRegions(w http.ResponseWriter, r *http.Request) {
+	// sorted by total revenue decending
+	regions, ok := s.dashboard(w, r, aggregate.Regions, func(a *aggregate.Set) *query.Result { return a.Regions })
+	if !ok {
+		return
 	}
 
-	// Sort by total revenue decending
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].TotalRevenue > result[j].TotalRevenue
-	})
-
 	// Get "limit" from query param
 	limit := 30 // default
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -249,8 +327,15 @@ func GetTopRegions(w http.ResponseWriter, r *http.Request) {
 			limit = parsed
 		}
 	}
-	if len(result) > limit {
-		result = result[:limit]
+	regions = regions.Head(limit)
+
+	result := make([]RegionStats, len(regions.Rows))
+	for i, region := range regions.Rows {
+		result[i] = RegionStats{
+			Region:        region[0].(string),
+			TotalRevenue:  region[1].(float64),
+			TotalItemSold: int(region[2].(int64)),
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/repository"
+)
+
+func benchmarkStore(n int) *repository.DataStore {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	txs := make([]domain.Transaction, n)
+	for i := range txs {
+		txs[i] = domain.Transaction{
+			ID:          fmt.Sprintf("TX%d", i),
+			Date:        start.AddDate(0, 0, rng.Intn(1500)),
+			Country:     fmt.Sprintf("Country%d", rng.Intn(20)),
+			Region:      fmt.Sprintf("Region%d", rng.Intn(60)),
+			ProductName: fmt.Sprintf("Product %d", rng.Intn(500)),
+			Quantity:    1 + rng.Intn(9),
+			TotalPrice:  rng.Float64() * 900,
+			Stock:       rng.Intn(1000),
+		}
+	}
+	return repository.NewDataStore(txs)
+}
+
+// BenchmarkFullScan is what every request cost before the aggregates were
+// materialized at load time, BenchmarkHandlers what a request costs now.
+func BenchmarkFullScan(b *testing.B) {
+	ds := benchmarkStore(500000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aggregate.Build(ds.Table)
+	}
+}
+
+func BenchmarkHandlers(b *testing.B) {
+	s := NewServer(repository.Static(benchmarkStore(500000)))
+	handlers := map[string]http.HandlerFunc{
+		"country-revenue": s.GetCountryRevenue,
+		"top-products":    s.GetTopProducts,
+		"monthly-sales":   s.GetMonthlySales,
+		"top-regions":     s.GetTopRegions,
+	}
+	for name, h := range handlers {
+		b.Run(name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+name, nil)
+			for i := 0; i < b.N; i++ {
+				h(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
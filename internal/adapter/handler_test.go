@@ -1,14 +1,26 @@
 package adapter
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/columnar"
 	"Dashlytics/internal/domain"
+	"Dashlytics/internal/reload"
 	"Dashlytics/internal/repository"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // Fake data for testing
@@ -20,6 +32,10 @@ func mockTransactions() []domain.Transaction {
 	}
 }
 
+func newTestServer(txs []domain.Transaction) *Server {
+	return NewServer(repository.Static(repository.NewDataStore(txs)))
+}
+
 func parseDate(d string) time.Time {
 	t, _ := domain.ParseDate(d)
 	return t
@@ -28,7 +44,7 @@ func parseDate(d string) time.Time {
 // Test handler response
 func TestMonthlySalesHandler(t *testing.T) {
 	// Inject mock data
-	repository.InitDataStore(mockTransactions())
+	s := newTestServer(mockTransactions())
 
 	req, err := http.NewRequest("GET", "/api/monthly-sales", nil)
 	if err != nil {
@@ -36,7 +52,7 @@ func TestMonthlySalesHandler(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(GetMonthlySales)
+	handler := http.HandlerFunc(s.GetMonthlySales)
 
 	handler.ServeHTTP(rr, req)
 
@@ -60,12 +76,12 @@ func TestCountryRevenueHandler(t *testing.T) {
 		{ID: "2", Country: "USA", ProductName: "Widget", Quantity: 1, TotalPrice: 10, Date: mustParseDate("2024-01-02")},
 		{ID: "3", Country: "Canada", ProductName: "Gadget", Quantity: 3, TotalPrice: 30, Date: mustParseDate("2024-01-03")},
 	}
-	repository.InitDataStore(mockData)
+	s := newTestServer(mockData)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/country-revenue?limit=10", nil)
 	rr := httptest.NewRecorder()
 
-	GetCountryRevenue(rr, req)
+	s.GetCountryRevenue(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("Expected 200 OK, got %d", rr.Code)
@@ -87,12 +103,12 @@ func TestTopProductsHandler(t *testing.T) {
 		{ProductName: "Widget", Quantity: 3, Stock: 100},
 		{ProductName: "Gadget", Quantity: 7, Stock: 200},
 	}
-	repository.InitDataStore(mockData)
+	s := newTestServer(mockData)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/top-products", nil)
 	rr := httptest.NewRecorder()
 
-	GetTopProducts(rr, req)
+	s.GetTopProducts(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("Expected 200 OK, got %d", rr.Code)
@@ -114,12 +130,12 @@ func TestTopRegionsHandler(t *testing.T) {
 		{Region: "Ontario", TotalPrice: 50, Quantity: 2},
 		{Region: "California", TotalPrice: 200, Quantity: 6},
 	}
-	repository.InitDataStore(mockData)
+	s := newTestServer(mockData)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/top-regions", nil)
 	rr := httptest.NewRecorder()
 
-	GetTopRegions(rr, req)
+	s.GetTopRegions(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("Expected 200 OK, got %d", rr.Code)
@@ -134,3 +150,833 @@ func TestTopRegionsHandler(t *testing.T) {
 		t.Errorf("Expected California first, got %s", result[0].Region)
 	}
 }
+
+// Run with -race: handlers read the DataStore while a reload replaces it.
+func TestHandlersDuringDataStoreSwap(t *testing.T) {
+	holder := &repository.Holder{}
+	holder.Store(repository.NewDataStore(mockTransactions()))
+	s := NewServer(holder)
+
+	var wg sync.WaitGroup
+	handlers := []http.HandlerFunc{s.GetCountryRevenue, s.GetTopProducts, s.GetMonthlySales, s.GetTopRegions}
+	for _, h := range handlers {
+		wg.Add(1)
+		go func(h http.HandlerFunc) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				rr := httptest.NewRecorder()
+				h(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+				if rr.Code != http.StatusOK {
+					t.Errorf("Expected 200 OK, got %d", rr.Code)
+					return
+				}
+			}
+		}(h)
+	}
+	for i := 0; i < 50; i++ {
+		holder.Store(repository.NewDataStore(mockTransactions()))
+	}
+	wg.Wait()
+}
+
+// fakeRepository serves a fixed slice without building any indexes.
+type fakeRepository struct {
+	txs []domain.Transaction
+}
+
+func (f fakeRepository) Len() int { return len(f.txs) }
+
+func (f fakeRepository) Columns() *columnar.Table { return columnar.FromTransactions(f.txs) }
+
+func (f fakeRepository) Aggregates() *aggregate.Set { return aggregate.Build(f.Columns()) }
+
+func (f fakeRepository) Scan(fn func(tx *domain.Transaction) bool) {
+	for i := range f.txs {
+		if !fn(&f.txs[i]) {
+			return
+		}
+	}
+}
+
+func (f fakeRepository) Lookup(id string) (domain.Transaction, bool) {
+	return domain.Transaction{}, false
+}
+
+func (f fakeRepository) ByDimension(c repository.Column, value string) []int32 {
+	return nil
+}
+
+func (f fakeRepository) Row(i int32) domain.Transaction {
+	return f.txs[i]
+}
+
+func TestServerWithInjectedRepository(t *testing.T) {
+	s := NewServer(repository.Static(fakeRepository{txs: []domain.Transaction{
+		{Region: "Ontario", TotalPrice: 50, Quantity: 2},
+	}}))
+
+	rr := httptest.NewRecorder()
+	s.GetTopRegions(rr, httptest.NewRequest(http.MethodGet, "/api/top-regions", nil))
+
+	var result []RegionStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(result) != 1 || result[0].Region != "Ontario" {
+		t.Errorf("Expected the injected region, got %+v", result)
+	}
+}
+
+func TestHandlersApplyFilters(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Country: "USA", Region: "Texas", ProductName: "Widget", Quantity: 2, TotalPrice: 20, Date: mustParseDate("2024-01-01")},
+		{ID: "2", Country: "Canada", Region: "Ontario", ProductName: "Gadget", Quantity: 3, TotalPrice: 30, Date: mustParseDate("2024-02-01")},
+		{ID: "3", Country: "USA", Region: "Ohio", ProductName: "Gadget", Quantity: 1, TotalPrice: 10, Date: mustParseDate("2024-02-10")},
+	})
+
+	rr := httptest.NewRecorder()
+	s.GetMonthlySales(rr, httptest.NewRequest(http.MethodGet, "/api/monthly-sales?country=USA&date_from=2024-02-01", nil))
+	var months []MonthlySales
+	if err := json.Unmarshal(rr.Body.Bytes(), &months); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(months) != 1 || months[0].Month != "2024-02" || months[0].TotalQuantitySold != 1 {
+		t.Errorf("Expected only the filtered February sale, got %+v", months)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTopRegions(rr, httptest.NewRequest(http.MethodGet, "/api/top-regions?country=Mexico", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected an empty list, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetCountryRevenue(rr, httptest.NewRequest(http.MethodGet, "/api/country-revenue?date_from=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid date, got %d", rr.Code)
+	}
+}
+
+func TestPostQuery(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Country: "USA", Category: "Toys", Quantity: 2, TotalPrice: 20, Date: mustParseDate("2024-01-01")},
+		{ID: "2", Country: "Canada", Category: "Toys", Quantity: 3, TotalPrice: 30, Date: mustParseDate("2024-02-01")},
+		{ID: "3", Country: "USA", Category: "Books", Quantity: 1, TotalPrice: 10, Date: mustParseDate("2024-02-10")},
+	})
+
+	body := `{"group_by":["category"],"metrics":[{"op":"sum","field":"total_price","as":"revenue"}],
+		"filters":{"country":["USA"]},"sort":[{"by":"revenue","desc":true}]}`
+	rr := httptest.NewRecorder()
+	s.PostQuery(rr, httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d: %s", rr.Code, rr.Body.String())
+	}
+	want := `{"columns":[{"name":"category","type":"string"},{"name":"revenue","type":"number"}],"rows":[["Toys",20],["Books",10]],"groups":2}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	for _, body := range []string{
+		`{"group_by":["colour"]}`,
+		`{"group_by":["country"],"filters":{"planet":"Mars"}}`,
+		`{"group_by":["country"],"having":{}}`,
+		`not json`,
+	} {
+		rr := httptest.NewRecorder()
+		s.PostQuery(rr, httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestPostSQL(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Country: "USA", TotalPrice: 20, Date: mustParseDate("2024-01-01")},
+		{ID: "2", Country: "Canada", TotalPrice: 30, Date: mustParseDate("2024-02-01")},
+		{ID: "3", Country: "USA", TotalPrice: 15, Date: mustParseDate("2024-02-10")},
+	})
+
+	body := `{"query":"SELECT country, sum(total_price) AS revenue FROM transactions GROUP BY country ORDER BY revenue DESC"}`
+	rr := httptest.NewRecorder()
+	s.PostSQL(rr, httptest.NewRequest(http.MethodPost, "/api/v1/sql", strings.NewReader(body)))
+	want := `{"columns":[{"name":"country","type":"string"},{"name":"revenue","type":"number"}],"rows":[["USA",35],["Canada",30]]}`
+	if got := strings.TrimSpace(rr.Body.String()); rr.Code != http.StatusOK || got != want {
+		t.Errorf("got %d %s\nwant %s", rr.Code, got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	s.PostSQL(rr, httptest.NewRequest(http.MethodPost, "/api/v1/sql", strings.NewReader(`{"query":"SELECT country FROM transaction"}`)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "line 1, column 21: unknown table") {
+		t.Errorf("Expected a positioned 400, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.PostSQL(rr, httptest.NewRequest(http.MethodPost, "/api/v1/sql", strings.NewReader(`{"querry":"SELECT id FROM transactions"}`)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `unknown field "querry"`) {
+		t.Errorf("Expected a 400 naming the unknown field, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	long := `{"query":"SELECT id FROM transactions WHERE ` + strings.Repeat("id = '1' OR ", maxSQLBytes/12) + `id = '2'"}`
+	rr = httptest.NewRecorder()
+	s.PostSQL(rr, httptest.NewRequest(http.MethodPost, "/api/v1/sql", strings.NewReader(long)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "longer than") {
+		t.Errorf("Expected a 400 for an overlong query, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTimeSeriesHandler(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Country: "USA", Quantity: 2, TotalPrice: 20, Date: mustParseDate("2024-01-01")},
+		{ID: "2", Country: "USA", Quantity: 3, TotalPrice: 30, Date: mustParseDate("2024-01-03")},
+	})
+
+	rr := httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?granularity=day&tz=Asia/Tokyo", nil))
+	var result TimeSeries
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.TimeZone != "Asia/Tokyo" || len(result.Points) != 3 {
+		t.Fatalf("Expected 3 days in Asia/Tokyo, got %+v", result)
+	}
+	if p := result.Points[1]; p.Period != "2024-01-02" || p.Start != "2024-01-02T00:00:00+09:00" || p.TransactionCount != 0 {
+		t.Errorf("Expected an empty 2024-01-02, got %+v", p)
+	}
+
+	for _, q := range []string{"granularity=hour", "tz=Mars/Olympus", "tz=Local"} {
+		rr := httptest.NewRecorder()
+		s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
+
+func TestTimeSeriesCompare(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Quantity: 1, TotalPrice: 40, Date: mustParseDate("2023-02-15")},
+		{ID: "2", Quantity: 2, TotalPrice: 100, Date: mustParseDate("2024-01-15")},
+		{ID: "3", Quantity: 3, TotalPrice: 80, Date: mustParseDate("2024-02-15")},
+	})
+
+	rr := httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?compare=true&date_from=2024-01-01", nil))
+	var result TimeSeries
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(result.Points) != 2 || result.Points[0].Period != "2024-01" {
+		t.Fatalf("Expected January and February 2024, got %+v", result.Points)
+	}
+	c := result.Points[1].RevenueComparison
+	if c == nil || c.Previous.Delta != -20 || *c.Previous.Percent != -20 || c.LastYear.Value != 40 || c.Cumulative != 180 {
+		t.Errorf("Unexpected February revenue comparison: %+v", c)
+	}
+	if q := result.Points[1].QuantityComparison; q == nil || q.LastYear.Delta != 2 {
+		t.Errorf("Unexpected February quantity comparison: %+v", q)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?compare=maybe", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid compare, got %d", rr.Code)
+	}
+}
+
+func TestTimeSeriesRolling(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", Quantity: 1, TotalPrice: 10, Date: mustParseDate("2024-01-01")},
+		{ID: "2", Quantity: 2, TotalPrice: 20, Date: mustParseDate("2024-01-02")},
+		{ID: "3", Quantity: 3, TotalPrice: 30, Date: mustParseDate("2024-01-03")},
+	})
+
+	rr := httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?granularity=day&rolling=sum:2&date_from=2024-01-02", nil))
+	var result TimeSeries
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(result.Points) != 2 {
+		t.Fatalf("Expected 2 days, got %+v", result.Points)
+	}
+	// the window of the first requested day reaches back before date_from
+	if v := result.Points[0].Rolling["sum:2"]; v.Revenue == nil || *v.Revenue != 30 || *v.Quantity != 3 {
+		t.Errorf("Unexpected rolling sum on 2024-01-02: %+v", v)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?granularity=day&rolling=avg:2", nil))
+	result = TimeSeries{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if v := result.Points[0].Rolling["avg:2"]; v.Revenue != nil {
+		t.Errorf("Expected null before the window is full, got %v", *v.Revenue)
+	}
+	if v := result.Points[2].Rolling["avg:2"]; v.Revenue == nil || *v.Revenue != 25 {
+		t.Errorf("Unexpected rolling average on 2024-01-03: %+v", v)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTimeSeries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/timeseries?rolling=median:3", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown window, got %d", rr.Code)
+	}
+}
+
+func TestForecastHandler(t *testing.T) {
+	var txs []domain.Transaction
+	for m := 0; m < 24; m++ {
+		date := time.Date(2022+m/12, time.Month(m%12+1), 10, 0, 0, 0, 0, time.UTC)
+		txs = append(txs, domain.Transaction{ID: strconv.Itoa(m), Country: "USA", Quantity: m%12 + 1, TotalPrice: float64(10 * (m%12 + 1)), Date: date})
+	}
+	s := newTestServer(txs)
+
+	rr := httptest.NewRecorder()
+	s.GetForecast(rr, httptest.NewRequest(http.MethodGet, "/api/v1/forecast?horizon=3&method=seasonal_naive&country=USA", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var result Forecast
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.SeasonLength != 12 || result.HistoryPeriods != 24 || len(result.Revenue.Points) != 3 {
+		t.Fatalf("Unexpected forecast: %+v", result)
+	}
+	if p := result.Revenue.Points[0]; p.Period != "2024-01" || p.Value != 10 {
+		t.Errorf("Expected January 2024 to repeat January 2023, got %+v", p)
+	}
+	if result.Quantity.Backtest == nil || result.Quantity.Backtest.RMSE != 0 {
+		t.Errorf("Expected an exact backtest, got %+v", result.Quantity.Backtest)
+	}
+
+	for _, q := range []string{"horizon=0", "method=arima", "level=95", "granularity=year", "country=Nowhere"} {
+		rr := httptest.NewRecorder()
+		s.GetForecast(rr, httptest.NewRequest(http.MethodGet, "/api/v1/forecast?"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
+
+func TestAnomaliesHandler(t *testing.T) {
+	var txs []domain.Transaction
+	for d := 0; d < 40; d++ {
+		price := float64(100 + d%5)
+		if d == 35 {
+			price = 1000
+		}
+		txs = append(txs, domain.Transaction{ID: strconv.Itoa(d), Country: "USA", Quantity: 1, TotalPrice: price, Date: time.Date(2024, 1, 1+d, 0, 0, 0, 0, time.UTC)})
+	}
+	s := newTestServer(txs)
+
+	rr := httptest.NewRecorder()
+	s.GetAnomalies(rr, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies?granularity=day&country=USA", nil))
+	var result Anomalies
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Window != 28 || result.Checked != 12 || len(result.Points) != 1 {
+		t.Fatalf("Expected one anomaly among 12 checked days, got %+v", result)
+	}
+	if p := result.Points[0]; p.Period != "2024-02-05" || p.Direction != "high" || p.Value != 1000 || p.Upper >= 1000 {
+		t.Errorf("Unexpected anomaly: %+v", p)
+	}
+
+	// the days before date_from fill the window
+	rr = httptest.NewRecorder()
+	s.GetAnomalies(rr, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies?granularity=day&window=7&all=true&date_from=2024-02-01", nil))
+	result = Anomalies{}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if result.Checked != 9 || len(result.Points) != 9 || result.Points[0].Period != "2024-02-01" {
+		t.Errorf("Expected every day from February 1st, got %+v", result)
+	}
+
+	for _, q := range []string{"metric=price", "method=prophet", "threshold=-1", "window=2", "method=seasonal&granularity=year"} {
+		rr := httptest.NewRecorder()
+		s.GetAnomalies(rr, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies?"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
+
+func TestCohortsHandler(t *testing.T) {
+	s := newTestServer([]domain.Transaction{
+		{ID: "1", UserID: "u1", Country: "USA", TotalPrice: 10, Date: mustParseDate("2024-01-05")},
+		{ID: "2", UserID: "u1", Country: "USA", TotalPrice: 20, Date: mustParseDate("2024-02-05")},
+		{ID: "3", UserID: "u2", Country: "USA", TotalPrice: 30, Date: mustParseDate("2024-01-15")},
+		{ID: "4", UserID: "u2", Country: "Canada", TotalPrice: 40, Date: mustParseDate("2024-02-15")},
+	})
+
+	rr := httptest.NewRecorder()
+	s.GetCohorts(rr, httptest.NewRequest(http.MethodGet, "/api/v1/cohorts?country=USA", nil))
+	var result CohortRetention
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Periods != 2 || len(result.Cohorts) != 1 {
+		t.Fatalf("Expected one January cohort over two months, got %+v", result)
+	}
+	c := result.Cohorts[0]
+	if c.Cohort != "2024-01" || c.Users != 2 || c.Retention[1] != 0.5 || c.PeriodRevenue[1] != 20 || c.Revenue != 60 {
+		t.Errorf("Unexpected cohort: %+v", c)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetCohorts(rr, httptest.NewRequest(http.MethodGet, "/api/v1/cohorts?granularity=day", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for daily cohorts, got %d", rr.Code)
+	}
+}
+
+func TestRFMHandlers(t *testing.T) {
+	var txs []domain.Transaction
+	for u := 0; u < 10; u++ {
+		// user u bought u+1 times, the last time u days before the end
+		for k := 0; k <= u; k++ {
+			txs = append(txs, domain.Transaction{
+				ID:         strconv.Itoa(u*100 + k),
+				UserID:     "u" + strconv.Itoa(u),
+				TotalPrice: 10,
+				Date:       time.Date(2024, 6, 30-u-k, 0, 0, 0, 0, time.UTC),
+			})
+		}
+	}
+	s := newTestServer(txs)
+
+	rr := httptest.NewRecorder()
+	s.GetRFM(rr, httptest.NewRequest(http.MethodGet, "/api/v1/rfm", nil))
+	var result RFMSegmentation
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.ReferenceDate != "2024-06-30" || result.Users != 10 || result.Revenue != 550 || len(result.Segments) != 11 {
+		t.Fatalf("Unexpected segmentation: %+v", result)
+	}
+	users, share := 0, 0.0
+	for _, seg := range result.Segments {
+		users += seg.Users
+		share += seg.RevenueShare
+	}
+	if users != 10 || share < 0.999 || share > 1.001 {
+		t.Errorf("Segments should cover every user and all revenue: %d users, %v of revenue", users, share)
+	}
+
+	// paging through a segment
+	seg := result.Segments[0]
+	for _, candidate := range result.Segments {
+		if candidate.Users > seg.Users {
+			seg = candidate
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/rfm/"+seg.Segment+"/users?limit=1&offset=1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("segment", seg.Segment)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	s.GetRFMSegmentUsers(rr, req)
+	var page RFMSegmentUsers
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if page.Total != seg.Users || len(page.Users) != min(1, seg.Users-1) {
+		t.Errorf("Unexpected page of %s (%d users): %+v", seg.Segment, seg.Users, page)
+	}
+
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("segment", "vip")
+	rr = httptest.NewRecorder()
+	s.GetRFMSegmentUsers(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown segment, got %d", rr.Code)
+	}
+}
+
+func TestBasketHandlers(t *testing.T) {
+	var txs []domain.Transaction
+	add := func(user, date, product, name string) {
+		txs = append(txs, domain.Transaction{ID: strconv.Itoa(len(txs)), UserID: user, ProductID: product, ProductName: name, Country: "USA", Date: mustParseDate(date)})
+	}
+	for _, u := range []string{"u1", "u2", "u3"} {
+		add(u, "2024-01-01", "P1", "Bread")
+		add(u, "2024-01-01", "P2", "Butter")
+	}
+	add("u4", "2024-01-01", "P1", "Bread")
+	add("u4", "2024-01-02", "P3", "Milk")
+
+	rr := httptest.NewRecorder()
+	s := newTestServer(txs)
+	s.GetAssociations(rr, httptest.NewRequest(http.MethodGet, "/api/v1/associations?country=USA&min_confidence=0.7", nil))
+	var result Associations
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Baskets != 5 || result.TotalItemsets != 1 || result.Itemsets[0].Baskets != 3 {
+		t.Fatalf("Expected Bread and Butter in 3 of 5 baskets, got %+v", result)
+	}
+	// Bread => Butter has a confidence of 3/4, Butter => Bread of 1
+	if result.TotalRules != 2 || result.Rules[0].Consequent.ProductName != "Bread" || result.Rules[0].Confidence != 1 {
+		t.Errorf("Unexpected rules: %+v", result.Rules)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/products/P1/related", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("productID", "P1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	s.GetRelatedProducts(rr, req)
+	var related RelatedProducts
+	if err := json.Unmarshal(rr.Body.Bytes(), &related); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if related.Product.ProductName != "Bread" || related.Baskets != 4 || len(related.Related) != 1 || related.Related[0].ProductID != "P2" {
+		t.Errorf("Unexpected related products: %+v", related)
+	}
+
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("productID", "P9")
+	rr = httptest.NewRecorder()
+	s.GetRelatedProducts(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown product, got %d", rr.Code)
+	}
+}
+
+func TestUserHandlers(t *testing.T) {
+	var txs []domain.Transaction
+	for u := 0; u < 12; u++ {
+		// user u bought every 30 days, u%4+1 times, from January 1st
+		for k := 0; k <= u%4; k++ {
+			txs = append(txs, domain.Transaction{
+				ID:         strconv.Itoa(u*100 + k),
+				UserID:     "u" + strconv.Itoa(u),
+				Country:    []string{"USA", "Canada"}[k%2],
+				Category:   "Books",
+				Quantity:   1,
+				TotalPrice: float64(10 * (u + 1)),
+				Date:       time.Date(2024, 1, 1+30*k, 0, 0, 0, 0, time.UTC),
+			})
+		}
+	}
+	txs = append(txs, domain.Transaction{ID: "x", UserID: "u3", Country: "USA", Category: "Toys", Quantity: 2, TotalPrice: 5, Date: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)})
+	s := newTestServer(txs)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/u3?horizon_days=90", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("userID", "u3")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	s.GetUserProfile(rr, req)
+	var profile UserProfile
+	if err := json.Unmarshal(rr.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	// four orders of 40 on days 0, 30, 60 and 90, and a fifth on day 120
+	if profile.Orders != 5 || profile.Transactions != 5 || profile.Units != 6 || profile.TotalSpend != 165 ||
+		profile.FavouriteCategory != "Books" || len(profile.Countries) != 2 || profile.LastPurchase != "2024-04-30" {
+		t.Fatalf("Unexpected profile: %+v", profile)
+	}
+	if len(profile.History) != 5 || profile.History[0].ID != "300" || profile.History[4].ID != "x" {
+		t.Errorf("Expected the history oldest first, got %+v", profile.History)
+	}
+	est := profile.CLV
+	if est == nil || est.Historical != 165 || est.Projected == nil || *est.Projected <= 0 || est.Total != est.Historical+*est.Projected {
+		t.Errorf("Unexpected CLV estimate: %+v", est)
+	}
+	repo := s.repos.Current()
+	if day := columnar.DayOf(time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)); s.fits.Get(repo, repo.Columns(), day) != s.fits.Get(repo, repo.Columns(), day) {
+		t.Errorf("Expected the CLV fit to be computed once per reference day")
+	}
+
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("userID", "nobody")
+	rr = httptest.NewRecorder()
+	s.GetUserProfile(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTopCustomers(rr, httptest.NewRequest(http.MethodGet, "/api/v1/clv?limit=3", nil))
+	var top TopCustomers
+	if err := json.Unmarshal(rr.Body.Bytes(), &top); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if top.Total != 12 || len(top.Users) != 3 || top.ReferenceDate != "2024-04-30" {
+		t.Fatalf("Unexpected ranking: %+v", top)
+	}
+	for i := 1; i < len(top.Users); i++ {
+		if top.Users[i].CLV > top.Users[i-1].CLV {
+			t.Errorf("Users not ranked by CLV: %+v", top.Users)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetTopCustomers(rr, httptest.NewRequest(http.MethodGet, "/api/v1/clv?user_id=u1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when too few users to fit, got %d", rr.Code)
+	}
+}
+
+func TestTransactionHandlers(t *testing.T) {
+	var txs []domain.Transaction
+	for k := 0; k < 5; k++ {
+		txs = append(txs, domain.Transaction{
+			ID:         "t" + strconv.Itoa(k),
+			UserID:     "u1",
+			Country:    []string{"USA", "Canada"}[k%2],
+			TotalPrice: float64(10 * k),
+			Date:       time.Date(2024, 1, 1+k, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	s := newTestServer(txs)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions/t3", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "t3")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	s.GetTransaction(rr, req)
+	var tx Transaction
+	if err := json.Unmarshal(rr.Body.Bytes(), &tx); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if tx.ID != "t3" || tx.Country != "Canada" || tx.Date != "2024-01-04" || tx.TotalPrice != 30 {
+		t.Errorf("Unexpected transaction: %+v", tx)
+	}
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("id", "t9")
+	rr = httptest.NewRecorder()
+	s.GetTransaction(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown transaction, got %d", rr.Code)
+	}
+
+	// page through the USA transactions by ascending total price
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		rr = httptest.NewRecorder()
+		s.GetTransactions(rr, httptest.NewRequest(http.MethodGet, "/api/v1/transactions?country=USA&sort=total_price&order=asc&limit=2&cursor="+cursor, nil))
+		var page TransactionPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to parse response %q: %v", rr.Body.String(), err)
+		}
+		if page.Total != 3 {
+			t.Fatalf("Expected 3 USA transactions, got %d", page.Total)
+		}
+		for _, tx := range page.Transactions {
+			ids = append(ids, tx.ID)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"t0", "t2", "t4"}) {
+		t.Errorf("Unexpected pages: %v", ids)
+	}
+
+	for _, bad := range []string{"sort=colour", "order=up", "cursor=junk"} {
+		rr = httptest.NewRecorder()
+		s.GetTransactions(rr, httptest.NewRequest(http.MethodGet, "/api/v1/transactions?"+bad, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", bad, rr.Code)
+		}
+	}
+}
+
+func TestCategoryHandlers(t *testing.T) {
+	txs := []domain.Transaction{
+		{ID: "1", Category: "Books", ProductID: "P1", ProductName: "Novel", Quantity: 2, TotalPrice: 20, Country: "USA", Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{ID: "2", Category: "Books", ProductID: "P2", ProductName: "Atlas", Quantity: 1, TotalPrice: 30, Country: "USA", Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{ID: "3", Category: "Books", ProductID: "P1", ProductName: "Novel", Quantity: 1, TotalPrice: 10, Country: "Canada", Date: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{ID: "4", Category: "Toys", ProductID: "P3", ProductName: "Kite", Quantity: 4, TotalPrice: 40, Country: "USA", Date: time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	s := newTestServer(txs)
+
+	rr := httptest.NewRecorder()
+	s.GetCategories(rr, httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil))
+	var breakdown CategoryBreakdown
+	if err := json.Unmarshal(rr.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if breakdown.Revenue != 100 || breakdown.Units != 8 || len(breakdown.Categories) != 2 {
+		t.Fatalf("Unexpected breakdown: %+v", breakdown)
+	}
+	books := breakdown.Categories[0]
+	if books.Category != "Books" || books.Revenue != 60 || books.RevenueShare != 0.6 || books.Units != 4 ||
+		books.UnitShare != 0.5 || books.AvgSellingPrice != 15 || books.Products != 2 || books.Transactions != 3 {
+		t.Errorf("Unexpected Books stats: %+v", books)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetCategories(rr, httptest.NewRequest(http.MethodGet, "/api/v1/categories?country=USA&sort=units", nil))
+	breakdown = CategoryBreakdown{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if breakdown.Revenue != 90 || breakdown.Categories[0].Category != "Toys" {
+		t.Errorf("Expected USA categories by units, Toys first: %+v", breakdown)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/categories/Books/products", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("category", "Books")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	s.GetCategoryProducts(rr, req)
+	var products CategoryProducts
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	// equal revenue, so by product ID
+	if products.Revenue != 60 || products.TotalProducts != 2 || products.Products[0].ProductName != "Novel" ||
+		products.Products[0].Units != 3 || products.Products[0].RevenueShare != 0.5 || products.Products[1].AvgSellingPrice != 30 {
+		t.Errorf("Unexpected Books products: %+v", products)
+	}
+	for query, want := range map[string]float64{"category=Books,Toys": 60, "category=Toys": 0} {
+		rr = httptest.NewRecorder()
+		filtered := httptest.NewRequest(http.MethodGet, "/api/v1/categories/Books/products?"+query, nil)
+		s.GetCategoryProducts(rr, filtered.WithContext(context.WithValue(filtered.Context(), chi.RouteCtxKey, rctx)))
+		products = CategoryProducts{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if products.Revenue != want || products.TotalProducts != len(products.Products) {
+			t.Errorf("Expected %v revenue of Books with %s, got %+v", want, query, products)
+		}
+	}
+
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("category", "Garden")
+	rr = httptest.NewRecorder()
+	s.GetCategoryProducts(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown category, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetCategoryMonths(rr, httptest.NewRequest(http.MethodGet, "/api/v1/categories/months", nil))
+	var matrix CategoryMonths
+	if err := json.Unmarshal(rr.Body.Bytes(), &matrix); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if !reflect.DeepEqual(matrix.Months, []string{"2024-01", "2024-02", "2024-03"}) || len(matrix.Categories) != 2 {
+		t.Fatalf("Unexpected matrix: %+v", matrix)
+	}
+	if c := matrix.Categories[1]; c.Category != "Toys" || !reflect.DeepEqual(c.Revenue, []float64{40, 0, 0}) || !reflect.DeepEqual(c.Units, []int{4, 0, 0}) {
+		t.Errorf("Unexpected Toys series: %+v", c)
+	}
+}
+
+func TestGeographyHandlers(t *testing.T) {
+	txs := []domain.Transaction{
+		{ID: "1", Country: "USA", Region: "Texas", Quantity: 1, TotalPrice: 50},
+		{ID: "2", Country: "USA", Region: "Ohio", Quantity: 3, TotalPrice: 30},
+		{ID: "3", Country: "USA", Region: "Texas", Quantity: 1, TotalPrice: 10, Category: "Toys"},
+		// a region of the same name in another country stays apart
+		{ID: "4", Country: "Mexico", Region: "Texas", Quantity: 5, TotalPrice: 10},
+	}
+	s := newTestServer(txs)
+
+	rr := httptest.NewRecorder()
+	s.GetGeography(rr, httptest.NewRequest(http.MethodGet, "/api/v1/geography", nil))
+	var root GeoNode
+	if err := json.Unmarshal(rr.Body.Bytes(), &root); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if root.Revenue != 100 || root.Units != 10 || root.ChildCount != 2 || len(root.Children) != 2 {
+		t.Fatalf("Unexpected root: %+v", root)
+	}
+	usa := root.Children[0]
+	if usa.Name != "USA" || usa.Revenue != 90 || usa.RevenueShare != 0.9 || usa.UnitShare != 0.5 || usa.ChildCount != 2 || usa.Children != nil {
+		t.Errorf("Expected a collapsed USA node first: %+v", usa)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetGeography(rr, httptest.NewRequest(http.MethodGet, "/api/v1/geography?expand=true", nil))
+	root = GeoNode{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &root); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if mexico := root.Children[1]; len(mexico.Children) != 1 || mexico.Children[0].Revenue != 10 || mexico.Children[0].RevenueShare != 1 {
+		t.Errorf("Unexpected Mexico node: %+v", mexico)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/geography/USA?category=Toys", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("country", "USA")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr = httptest.NewRecorder()
+	s.GetGeographyCountry(rr, req)
+	var country GeoNode
+	if err := json.Unmarshal(rr.Body.Bytes(), &country); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if country.Revenue != 10 || country.RevenueShare != 1 || len(country.Children) != 1 || country.Children[0].Name != "Texas" {
+		t.Errorf("Unexpected USA node for toys: %+v", country)
+	}
+
+	rr = httptest.NewRecorder()
+	s.GetGeographyCountry(rr, httptest.NewRequest(http.MethodGet, "/api/v1/geography/USA?category=Garden", nil).WithContext(req.Context()))
+	country = GeoNode{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &country); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if country.Name != "USA" || country.Revenue != 0 {
+		t.Errorf("Expected an empty USA node: %+v", country)
+	}
+
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("country", "Peru")
+	rr = httptest.NewRecorder()
+	s.GetGeographyCountry(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown country, got %d", rr.Code)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cases := []struct {
+		token, remote, header string
+		want                  int
+	}{
+		{"", "127.0.0.1:5000", "", http.StatusOK},
+		{"", "[::1]:5000", "", http.StatusOK},
+		{"", "192.0.2.1:5000", "", http.StatusForbidden},
+		{"secret", "192.0.2.1:5000", "Bearer secret", http.StatusOK},
+		{"secret", "127.0.0.1:5000", "", http.StatusUnauthorized},
+		{"secret", "192.0.2.1:5000", "Bearer guess", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil)
+		req.RemoteAddr = c.remote
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rr := httptest.NewRecorder()
+		AdminAuth(c.token)(ok).ServeHTTP(rr, req)
+		if rr.Code != c.want {
+			t.Errorf("token %q from %s with %q: got %d, want %d", c.token, c.remote, c.header, rr.Code, c.want)
+		}
+	}
+}
+
+func TestTriggerReloadRateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\nTX1,2024-01-01,USA,Texas,Widget,1,10,5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rl := reload.New(repository.Source{CSVPath: path}, &repository.Holder{})
+	if err := rl.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	TriggerReload(rl)(rr, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After right after a reload, got %d %v", rr.Code, rr.Header())
+	}
+}
@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"Dashlytics/internal/filter"
+	"Dashlytics/internal/query"
+)
+
+const (
+	defaultQueryLimit = 1000
+	maxQueryLimit     = 10000
+	maxQueryBodyBytes = 1 << 20
+)
+
+// QueryRequest is the body of POST /query.
+type QueryRequest struct {
+	// GroupBy lists transaction fields, or date fields with a bucket (day, week, month, quarter, year)
+	GroupBy []string `json:"group_by" example:"country,date:month"`
+	// Metrics computed per group: sum, count, avg, min, max or first of quantity, total_price, price or stock
+	Metrics []query.Metric `json:"metrics"`
+	// Filters takes the same names and values as the analytics endpoints' query parameters
+	Filters map[string]any `json:"filters,omitempty"`
+	Sort    []query.Order  `json:"sort,omitempty"`
+	// Limit defaults to 1000 groups and is capped at 10000
+	Limit int `json:"limit,omitempty"`
+}
+
+// QueryHandler godoc
+// @Summary Run a group-by/aggregate query
+// @Description Groups the transactions by any fields or date buckets, computes the requested metrics per group and returns the sorted groups as a table
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param query body QueryRequest true "Dimensions, metrics, filters, sort and limit"
+// @Success 200 {object} query.Result
+// @Failure 400 {string} string "invalid query"
+// @Router /query [post]
+func (s *Server) PostQuery(w http.ResponseWriter, r *http.Request) {
+	var req QueryRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQueryBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := filter.ParseMap(req.Filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	limit = min(limit, maxQueryLimit)
+
+	repo := s.repos.Current()
+	var rows []int32 // nil: every row
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+	}
+	res, err := query.Execute(repo.Columns(), rows, query.Query{
+		GroupBy: req.GroupBy,
+		Metrics: req.Metrics,
+		Sort:    req.Sort,
+		Limit:   limit,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,202 @@
+package adapter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/rfm"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultSegmentPage = 100
+	maxSegmentPage     = 10000
+)
+
+// RFMSegment sums up one segment
+type RFMSegment struct {
+	Segment      string  `json:"segment"` // key for /rfm/{segment}/users
+	Name         string  `json:"name"`
+	Users        int     `json:"users"`
+	UserShare    float64 `json:"user_share"`
+	Revenue      float64 `json:"revenue"`
+	RevenueShare float64 `json:"revenue_share"`
+	AvgRecency   float64 `json:"avg_recency_days"`
+	AvgFrequency float64 `json:"avg_frequency"`
+	AvgMonetary  float64 `json:"avg_monetary"`
+}
+
+// RFMSegmentation splits the users into RFM segments
+type RFMSegmentation struct {
+	ReferenceDate string       `json:"reference_date"`
+	Quantiles     int          `json:"quantiles"`
+	Users         int          `json:"users"`
+	Revenue       float64      `json:"revenue"`
+	Segments      []RFMSegment `json:"segments"`
+}
+
+// RFMCustomer is the RFM profile of one user
+type RFMCustomer struct {
+	UserID      string  `json:"user_id"`
+	RecencyDays int32   `json:"recency_days"`
+	Frequency   int     `json:"frequency"`
+	Monetary    float64 `json:"monetary"`
+	R           int     `json:"r"`
+	F           int     `json:"f"`
+	M           int     `json:"m"`
+}
+
+// RFMSegmentUsers is one page of the users of a segment
+type RFMSegmentUsers struct {
+	Segment string        `json:"segment"`
+	Total   int           `json:"total"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+	Users   []RFMCustomer `json:"users"`
+}
+
+// scoreRFM reads the RFM and filter parameters and scores the matching users.
+func (s *Server) scoreRFM(w http.ResponseWriter, r *http.Request) ([]rfm.Customer, rfm.Options, *columnar.Table, bool) {
+	var opts rfm.Options
+	var ok bool
+	if opts.Quantiles, ok = intParam(w, r, "quantiles", 5, 2, rfm.MaxQuantiles); !ok {
+		return nil, opts, nil, false
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return nil, opts, nil, false
+	}
+	repo := s.repos.Current()
+	t := repo.Columns()
+	if opts.Reference, ok = referenceDay(w, r, f, t); !ok {
+		return nil, opts, nil, false
+	}
+
+	var rows []int32 // nil: every row
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+	}
+	customers, err := rfm.Score(t, rows, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, opts, nil, false
+	}
+	return customers, opts, t, true
+}
+
+// RFMHandler godoc
+// @Summary Get RFM segments
+// @Description Scores users by recency, frequency and monetary value in quantiles and returns the size and revenue share of each named segment. Segments are placed by the recency score against the average of the frequency and monetary scores.
+// @Tags customers
+// @Produce json
+// @Param reference_date query string false "Day recency is measured to (YYYY-MM-DD); date_to or the last day of the data by default"
+// @Param quantiles query int false "Score levels" default(5) minimum(2) maximum(10)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} RFMSegmentation
+// @Failure 400 {string} string "invalid parameter"
+// @Router /rfm [get]
+func (s *Server) GetRFM(w http.ResponseWriter, r *http.Request) {
+	customers, opts, _, ok := s.scoreRFM(w, r)
+	if !ok {
+		return
+	}
+
+	result := RFMSegmentation{
+		ReferenceDate: columnar.DayTime(opts.Reference).Format("2006-01-02"),
+		Quantiles:     opts.Quantiles,
+		Users:         len(customers),
+	}
+	summaries := rfm.Summarize(customers)
+	for _, sum := range summaries {
+		result.Revenue += sum.Revenue
+	}
+	for _, sum := range summaries {
+		seg := RFMSegment{
+			Segment:      string(sum.Segment),
+			Name:         sum.Segment.Name(),
+			Users:        sum.Users,
+			Revenue:      sum.Revenue,
+			AvgRecency:   sum.Recency,
+			AvgFrequency: sum.Frequency,
+			AvgMonetary:  sum.Monetary,
+		}
+		if result.Users > 0 {
+			seg.UserShare = float64(sum.Users) / float64(result.Users)
+		}
+		if result.Revenue != 0 {
+			seg.RevenueShare = sum.Revenue / result.Revenue
+		}
+		result.Segments = append(result.Segments, seg)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RFMSegmentUsersHandler godoc
+// @Summary List the users of an RFM segment
+// @Description Pages through the users of one segment, highest monetary value first, with their RFM values and scores. Takes the parameters of /rfm, which define the segments.
+// @Tags customers
+// @Produce json
+// @Param segment path string true "Segment key" Enums(champions,loyal_customers,potential_loyalists,new_customers,promising,need_attention,about_to_sleep,cant_lose_them,at_risk,hibernating,lost)
+// @Param offset query int false "Users to skip" default(0)
+// @Param limit query int false "Users per page" default(100) maximum(10000)
+// @Param reference_date query string false "Day recency is measured to (YYYY-MM-DD); date_to or the last day of the data by default"
+// @Param quantiles query int false "Score levels" default(5) minimum(2) maximum(10)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} RFMSegmentUsers
+// @Failure 400 {string} string "invalid parameter"
+// @Failure 404 {string} string "unknown segment"
+// @Router /rfm/{segment}/users [get]
+func (s *Server) GetRFMSegmentUsers(w http.ResponseWriter, r *http.Request) {
+	segment, err := rfm.ParseSegment(chi.URLParam(r, "segment"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	offset, ok := intParam(w, r, "offset", 0, 0, math.MaxInt32)
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, r, "limit", defaultSegmentPage, 1, maxSegmentPage)
+	if !ok {
+		return
+	}
+	customers, _, t, ok := s.scoreRFM(w, r)
+	if !ok {
+		return
+	}
+
+	var members []rfm.Customer
+	for _, c := range customers {
+		if c.Segment == segment {
+			members = append(members, c)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Monetary != members[j].Monetary {
+			return members[i].Monetary > members[j].Monetary
+		}
+		return t.UserID.Values[members[i].User] < t.UserID.Values[members[j].User]
+	})
+
+	result := RFMSegmentUsers{Segment: string(segment), Total: len(members), Offset: offset, Limit: limit, Users: []RFMCustomer{}}
+	for _, c := range members[min(offset, len(members)):min(offset+limit, len(members))] {
+		result.Users = append(result.Users, RFMCustomer{
+			UserID:      t.UserID.Values[c.User],
+			RecencyDays: c.Recency,
+			Frequency:   c.Frequency,
+			Monetary:    c.Monetary,
+			R:           c.R,
+			F:           c.F,
+			M:           c.M,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"Dashlytics/internal/sqlquery"
+)
+
+// maxSQLBytes caps the query text.
+const maxSQLBytes = 64 << 10
+
+// SQLRequest is the body of POST /sql.
+type SQLRequest struct {
+	Query string `json:"query" example:"SELECT country, sum(total_price) AS revenue FROM transactions GROUP BY country ORDER BY revenue DESC LIMIT 10"`
+	// Explain returns the query plan instead of running the query
+	Explain bool `json:"explain,omitempty"`
+}
+
+// SQLHandler godoc
+// @Summary Run a read-only SQL query
+// @Description Runs a SELECT over the virtual "transactions" table (WHERE, GROUP BY, HAVING, ORDER BY, LIMIT, date_trunc, count(DISTINCT ...)). Results are capped at 10000 rows, query text at 64 KiB.
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param query body SQLRequest true "SQL text, optionally explained"
+// @Success 200 {object} sqlquery.Result
+// @Failure 400 {string} string "syntax or planning error with its position"
+// @Router /sql [post]
+func (s *Server) PostSQL(w http.ResponseWriter, r *http.Request) {
+	var req SQLRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQueryBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Query) > maxSQLBytes {
+		http.Error(w, fmt.Sprintf("query is longer than %d bytes", maxSQLBytes), http.StatusBadRequest)
+		return
+	}
+
+	res, err := sqlquery.Run(s.repos.Current(), req.Query, sqlquery.Options{MaxRows: maxQueryLimit, Explain: req.Explain})
+	if err != nil {
+		msg := err.Error()
+		var perr *sqlquery.Error
+		if errors.As(err, &perr) && perr.Context() != "" {
+			msg += "\n" + perr.Context()
+		}
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// the status is out once encoding fails
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Printf("sql: encode result: %v", err)
+	}
+}
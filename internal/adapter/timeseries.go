@@ -0,0 +1,237 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"Dashlytics/internal/query"
+	"Dashlytics/internal/timeseries"
+	"Dashlytics/internal/window"
+)
+
+// TimeSeriesPoint is the sales of one period
+type TimeSeriesPoint struct {
+	Period            string  `json:"period"` // 2024-01-31, 2024-W05, 2024-01, 2024-Q1 or 2024
+	Start             string  `json:"start"`  // RFC 3339, at the UTC offset of tz
+	TotalQuantitySold int     `json:"total_quantity_sold"`
+	TotalRevenue      float64 `json:"total_revenue"`
+	TransactionCount  int     `json:"transaction_count"`
+	// with compare=true
+	RevenueComparison  *PeriodComparison `json:"revenue_comparison,omitempty"`
+	QuantityComparison *PeriodComparison `json:"quantity_comparison,omitempty"`
+	// with rolling=..., keyed by window such as "avg:7"
+	Rolling map[string]RollingValue `json:"rolling,omitempty"`
+}
+
+// RollingValue is a window function over the periods up to this one
+type RollingValue struct {
+	Revenue  *float64 `json:"revenue"`  // null until the window is full
+	Quantity *float64 `json:"quantity"` // null until the window is full
+}
+
+// PeriodChange compares a period with an earlier one
+type PeriodChange struct {
+	Value   float64  `json:"value"`   // the earlier period's value
+	Delta   float64  `json:"delta"`   // current minus earlier
+	Percent *float64 `json:"percent"` // null when the earlier value is zero
+}
+
+// PeriodComparison is the period-over-period view of one metric
+type PeriodComparison struct {
+	Previous   *PeriodChange `json:"previous"`  // null for the first period
+	LastYear   *PeriodChange `json:"last_year"` // same ISO week, month, quarter or date a year earlier
+	Cumulative float64       `json:"cumulative"`
+}
+
+// TimeSeries is a gap-free series of periods
+type TimeSeries struct {
+	Granularity string            `json:"granularity"`
+	TimeZone    string            `json:"tz"`
+	Points      []TimeSeriesPoint `json:"points"`
+}
+
+// parseSeriesOptions reads granularity and tz, answering 400 when they are
+// invalid. tz doesn't move sales between periods: it only labels the start
+// of each with its UTC offset.
+func parseSeriesOptions(w http.ResponseWriter, r *http.Request) (timeseries.Options, bool) {
+	opts := timeseries.Options{Bucket: query.Month, Location: time.UTC}
+	if g := r.URL.Query().Get("granularity"); g != "" {
+		b, err := query.ParseBucket(g)
+		if err != nil {
+			http.Error(w, "invalid granularity: "+err.Error(), http.StatusBadRequest)
+			return opts, false
+		}
+		opts.Bucket = b
+	}
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			http.Error(w, fmt.Sprintf("invalid tz %q: want an IANA time zone such as Europe/Berlin", tz), http.StatusBadRequest)
+			return opts, false
+		}
+		opts.Location = loc
+	}
+	return opts, true
+}
+
+// buildSeries reads the filter and series options of r and buckets the
+// matching rows. With a date_from, the series starts lookback(granularity)
+// days early, so the requested periods can be compared with earlier ones;
+// first is the position of the first requested period. lookback may be nil.
+func (s *Server) buildSeries(w http.ResponseWriter, r *http.Request, lookback func(query.Bucket) int32) (series *timeseries.Series, first int, ok bool) {
+	opts, ok := parseSeriesOptions(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	opts.From, opts.To = f.DateFrom, f.DateTo
+	requestedFrom := f.DateFrom
+	if lookback != nil && f.DateFrom != nil {
+		if days := lookback(opts.Bucket); days > 0 {
+			from := *f.DateFrom - days
+			opts.From, f.DateFrom = &from, &from
+		}
+	}
+
+	repo := s.repos.Current()
+	var rows []int32 // nil: every row
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+	}
+	series, err := timeseries.Build(repo.Columns(), rows, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, 0, false
+	}
+	if requestedFrom != nil && opts.From != requestedFrom {
+		if first = series.Find(*requestedFrom); first < 0 {
+			first = len(series.Points)
+		}
+	}
+	return series, first, true
+}
+
+// yearLookback covers the same ISO week a year earlier.
+const yearLookback = 366 + 7
+
+// windowLookback covers the periods the windows read before date_from.
+func windowLookback(b query.Bucket, specs []window.Spec) int32 {
+	periods := 0
+	for _, spec := range specs {
+		periods = max(periods, spec.Lookback())
+	}
+	return int32(periods) * b.MaxDays()
+}
+
+// TimeSeriesHandler godoc
+// @Summary Get sales per period
+// @Description Returns quantity, revenue and transaction count per day, ISO week, month, quarter or year. Periods without sales are included with zeros. date_from and date_to extend or cut the series to the periods holding them. tz only sets the UTC offset of each period's start, as dates are stored as calendar days.
+// @Tags sales
+// @Produce json
+// @Param granularity query string false "Period length" Enums(day,week,month,quarter,year) default(month)
+// @Param tz query string false "IANA time zone whose UTC offset the period start times carry, UTC by default. Sales are bucketed by their stored calendar day in any zone." example(America/New_York)
+// @Param compare query bool false "Add previous-period and same-period-last-year values, deltas and a running total of revenue and quantity"
+// @Param rolling query string false "Window functions over revenue and quantity, in periods (comma-separated sum:N, avg:N or ewma:N)" example(avg:7,ewma:30)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} TimeSeries
+// @Failure 400 {string} string "invalid parameter"
+// @Router /timeseries [get]
+func (s *Server) GetTimeSeries(w http.ResponseWriter, r *http.Request) {
+	compare, ok := boolParam(w, r, "compare")
+	if !ok {
+		return
+	}
+	specs, err := window.ParseList(r.URL.Query().Get("rolling"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lookback := func(b query.Bucket) int32 {
+		days := windowLookback(b, specs)
+		if compare {
+			days = max(days, yearLookback)
+		}
+		return days
+	}
+	series, first, ok := s.buildSeries(w, r, lookback)
+	if !ok {
+		return
+	}
+
+	var revenue, quantity []timeseries.Comparison
+	if compare {
+		revenue = series.Compare(first, func(p timeseries.Point) float64 { return p.Revenue })
+		quantity = series.Compare(first, func(p timeseries.Point) float64 { return float64(p.Quantity) })
+	}
+	rollingRevenue := make([][]float64, len(specs))
+	rollingQuantity := make([][]float64, len(specs))
+	if len(specs) > 0 {
+		revenues, quantities := series.Revenues(), series.Quantities()
+		for j, spec := range specs {
+			rollingRevenue[j] = spec.Apply(revenues)
+			rollingQuantity[j] = spec.Apply(quantities)
+		}
+	}
+
+	result := TimeSeries{
+		Granularity: string(series.Bucket),
+		TimeZone:    series.Location.String(),
+		Points:      make([]TimeSeriesPoint, 0, len(series.Points)-first),
+	}
+	for i := first; i < len(series.Points); i++ {
+		p := series.Points[i]
+		point := TimeSeriesPoint{
+			Period:            series.Label(i),
+			Start:             series.StartTime(i).Format(time.RFC3339),
+			TotalQuantitySold: p.Quantity,
+			TotalRevenue:      p.Revenue,
+			TransactionCount:  p.Transactions,
+		}
+		if compare {
+			point.RevenueComparison = periodComparison(revenue[i-first])
+			point.QuantityComparison = periodComparison(quantity[i-first])
+		}
+		if len(specs) > 0 {
+			point.Rolling = make(map[string]RollingValue, len(specs))
+			for j, spec := range specs {
+				point.Rolling[spec.String()] = RollingValue{
+					Revenue:  finite(rollingRevenue[j][i]),
+					Quantity: finite(rollingQuantity[j][i]),
+				}
+			}
+		}
+		result.Points = append(result.Points, point)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func periodComparison(c timeseries.Comparison) *PeriodComparison {
+	return &PeriodComparison{
+		Previous:   periodChange(c.Previous),
+		LastYear:   periodChange(c.LastYear),
+		Cumulative: c.Cumulative,
+	}
+}
+
+func periodChange(c *timeseries.Change) *PeriodChange {
+	if c == nil {
+		return nil
+	}
+	return &PeriodChange{Value: c.Value, Delta: c.Delta, Percent: c.Percent}
+}
+
+// finite maps NaN, which JSON can't carry, to null.
+func finite(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
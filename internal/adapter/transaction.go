@@ -0,0 +1,135 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/query"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const defaultTransactionPage = 100
+
+// Transaction is one sale
+type Transaction struct {
+	ID          string  `json:"id"`
+	Date        string  `json:"date"` // YYYY-MM-DD
+	UserID      string  `json:"user_id"`
+	Country     string  `json:"country"`
+	Region      string  `json:"region"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Category    string  `json:"category"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"`
+	TotalPrice  float64 `json:"total_price"`
+	Stock       int     `json:"stock"`
+	AddedDate   string  `json:"added_date"` // YYYY-MM-DD
+}
+
+func newTransaction(tx domain.Transaction) Transaction {
+	return Transaction{
+		ID:          tx.ID,
+		Date:        tx.Date.Format("2006-01-02"),
+		UserID:      tx.UserID,
+		Country:     tx.Country,
+		Region:      tx.Region,
+		ProductID:   tx.ProductID,
+		ProductName: tx.ProductName,
+		Category:    tx.Category,
+		Price:       tx.Price,
+		Quantity:    tx.Quantity,
+		TotalPrice:  tx.TotalPrice,
+		Stock:       tx.Stock,
+		AddedDate:   tx.AddedDate.Format("2006-01-02"),
+	}
+}
+
+// TransactionPage is one page of a transaction listing
+type TransactionPage struct {
+	Total        int           `json:"total"` // matching transactions
+	Transactions []Transaction `json:"transactions"`
+	// NextCursor fetches the following page, empty on the last one
+	NextCursor string `json:"next_cursor"`
+}
+
+// TransactionHandler godoc
+// @Summary Get a transaction
+// @Description Returns one transaction by its ID.
+// @Tags transactions
+// @Produce json
+// @Param id path string true "Transaction ID"
+// @Success 200 {object} Transaction
+// @Failure 404 {string} string "unknown transaction"
+// @Router /transactions/{id} [get]
+func (s *Server) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tx, ok := s.repos.Current().Lookup(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown transaction %q", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTransaction(tx))
+}
+
+// TransactionsHandler godoc
+// @Summary List transactions
+// @Description Pages through the matching transactions sorted by any field, ties broken by ID. Pass the next_cursor of a page as cursor, with the same sort and order, to get the next one. Cursors point at a transaction's values rather than its position, so paging stays consistent when the data is reloaded in between.
+// @Tags transactions
+// @Produce json
+// @Param sort query string false "Field to sort by" Enums(id,date,user_id,country,region,product_id,product_name,category,price,quantity,total_price,stock,added_date) default(date)
+// @Param order query string false "Sort order" Enums(asc,desc) default(desc)
+// @Param limit query int false "Transactions per page" default(100) maximum(10000)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} TransactionPage
+// @Failure 400 {string} string "invalid parameter or cursor"
+// @Router /transactions [get]
+func (s *Server) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	limit, ok := intParam(w, r, "limit", defaultTransactionPage, 1, query.MaxPage)
+	if !ok {
+		return
+	}
+	listing := query.Listing{Sort: "date", Desc: true}
+	if by := r.URL.Query().Get("sort"); by != "" {
+		listing.Sort = by
+	}
+	switch order := r.URL.Query().Get("order"); order {
+	case "", "desc":
+	case "asc":
+		listing.Desc = false
+	default:
+		http.Error(w, fmt.Sprintf("invalid order %q: want asc or desc", order), http.StatusBadRequest)
+		return
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	repo := s.repos.Current()
+	var rows []int32 // nil: every row
+	total := repo.Len()
+	if !f.IsEmpty() {
+		rows = f.Select(repo)
+		total = len(rows)
+	}
+	page, next, err := query.Page(repo.Columns(), rows, listing, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := TransactionPage{Total: total, Transactions: make([]Transaction, len(page)), NextCursor: next}
+	for k, i := range page {
+		result.Transactions[k] = newTransaction(repo.Row(i))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,263 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+
+	"Dashlytics/internal/clv"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/filter"
+	"Dashlytics/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHorizonDays = 365
+	maxHorizonDays     = 3650
+	defaultCLVPage     = 100
+	maxCLVPage         = 10000
+)
+
+// CLVEstimate is the lifetime value of one user
+type CLVEstimate struct {
+	ReferenceDate string  `json:"reference_date"`
+	HorizonDays   int     `json:"horizon_days"`
+	Historical    float64 `json:"historical"` // revenue up to the reference date
+	// the model's outlook over the horizon, null when it can't be fitted
+	ExpectedOrders   *float64 `json:"expected_orders"`
+	ProbabilityAlive *float64 `json:"probability_alive"`
+	Projected        *float64 `json:"projected"` // expected orders at the user's average order value
+	Total            float64  `json:"total"`     // historical plus projected
+}
+
+// UserProfile is the history of one user with derived stats
+type UserProfile struct {
+	UserID            string        `json:"user_id"`
+	FirstPurchase     string        `json:"first_purchase"`
+	LastPurchase      string        `json:"last_purchase"`
+	Orders            int           `json:"orders"` // days with purchases
+	Transactions      int           `json:"transactions"`
+	Units             int           `json:"units"`
+	TotalSpend        float64       `json:"total_spend"`
+	AvgOrderValue     float64       `json:"avg_order_value"`
+	FavouriteCategory string        `json:"favourite_category"` // by spend
+	Countries         []string      `json:"countries"`
+	CLV               *CLVEstimate  `json:"clv"` // null without purchases up to the reference date
+	History           []Transaction `json:"history"`
+}
+
+// CLVModel holds the fitted BG/NBD parameters, with rates per day
+type CLVModel struct {
+	R     float64 `json:"r"`
+	Alpha float64 `json:"alpha"`
+	A     float64 `json:"a"`
+	B     float64 `json:"b"`
+}
+
+// CustomerValue is the lifetime value of one user in a ranking
+type CustomerValue struct {
+	UserID           string  `json:"user_id"`
+	Orders           int     `json:"orders"`
+	LastPurchase     string  `json:"last_purchase"`
+	Historical       float64 `json:"historical"`
+	ExpectedOrders   float64 `json:"expected_orders"`
+	ProbabilityAlive float64 `json:"probability_alive"`
+	Projected        float64 `json:"projected"`
+	CLV              float64 `json:"clv"`
+}
+
+// TopCustomers is one page of the users ranked by lifetime value
+type TopCustomers struct {
+	ReferenceDate string          `json:"reference_date"`
+	HorizonDays   int             `json:"horizon_days"`
+	Model         CLVModel        `json:"model"`
+	Total         int             `json:"total"`
+	Offset        int             `json:"offset"`
+	Limit         int             `json:"limit"`
+	Users         []CustomerValue `json:"users"`
+}
+
+// formatDay formats a columnar day number as YYYY-MM-DD.
+func formatDay(d int32) string {
+	return columnar.DayTime(d).Format("2006-01-02")
+}
+
+// UserProfileHandler godoc
+// @Summary Get a user's profile
+// @Description Returns every transaction of a user, oldest first, with derived stats and a lifetime value estimate: the revenue up to the reference date plus the revenue a BG/NBD model, fitted to all users, expects over the horizon. An order is a day with purchases.
+// @Tags customers
+// @Produce json
+// @Param userID path string true "User ID"
+// @Param reference_date query string false "Day the lifetime value is estimated on (YYYY-MM-DD); the last day of the data by default"
+// @Param horizon_days query int false "Days the projection looks ahead" default(365) maximum(3650)
+// @Success 200 {object} UserProfile
+// @Failure 400 {string} string "invalid parameter"
+// @Failure 404 {string} string "unknown user"
+// @Router /users/{userID} [get]
+func (s *Server) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	horizon, ok := intParam(w, r, "horizon_days", defaultHorizonDays, 1, maxHorizonDays)
+	if !ok {
+		return
+	}
+	repo := s.repos.Current()
+	t := repo.Columns()
+	id := chi.URLParam(r, "userID")
+	rows := repo.ByDimension(repository.ColUserID, id)
+	if len(rows) == 0 {
+		http.Error(w, fmt.Sprintf("unknown user %q", id), http.StatusNotFound)
+		return
+	}
+	reference, ok := referenceDay(w, r, filter.Filter{}, t)
+	if !ok {
+		return
+	}
+
+	rows = slices.Clone(rows)
+	sort.SliceStable(rows, func(i, j int) bool { return t.Date[rows[i]] < t.Date[rows[j]] })
+	result := UserProfile{
+		UserID:        id,
+		FirstPurchase: formatDay(t.Date[rows[0]]),
+		LastPurchase:  formatDay(t.Date[rows[len(rows)-1]]),
+		Transactions:  len(rows),
+		History:       make([]Transaction, len(rows)),
+	}
+	spend := make(map[uint32]float64)
+	countries := make(map[string]bool)
+	for k, i := range rows {
+		if k == 0 || t.Date[i] != t.Date[rows[k-1]] {
+			result.Orders++
+		}
+		result.Units += int(t.Quantity[i])
+		result.TotalSpend += t.TotalPrice[i]
+		spend[t.Category.Codes[i]] += t.TotalPrice[i]
+		countries[t.Country.At(int(i))] = true
+		result.History[k] = newTransaction(repo.Row(i))
+	}
+	result.AvgOrderValue = result.TotalSpend / float64(result.Orders)
+	best := math.Inf(-1)
+	for code, v := range spend {
+		name := t.Category.Values[code]
+		if v > best || v == best && name < result.FavouriteCategory {
+			best, result.FavouriteCategory = v, name
+		}
+	}
+	for c := range countries {
+		result.Countries = append(result.Countries, c)
+	}
+	slices.Sort(result.Countries)
+
+	// the model is fitted to every user once per reference day
+	fit := s.fits.Get(repo, t, reference)
+	code, _ := t.UserID.Code(id)
+	if k, found := slices.BinarySearchFunc(fit.Customers, code, func(c clv.Customer, u uint32) int { return int(c.User) - int(u) }); found {
+		c := &fit.Customers[k]
+		est := &CLVEstimate{
+			ReferenceDate: formatDay(reference),
+			HorizonDays:   horizon,
+			Historical:    c.Revenue,
+			Total:         c.Revenue,
+		}
+		if m := fit.Model; fit.Err == nil {
+			orders, alive, projected := m.ExpectedOrders(c, float64(horizon)), m.Alive(c), m.Projected(c, float64(horizon))
+			est.ExpectedOrders, est.ProbabilityAlive, est.Projected = &orders, &alive, &projected
+			est.Total += projected
+		}
+		result.CLV = est
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// TopCustomersHandler godoc
+// @Summary Rank users by lifetime value
+// @Description Fits a BG/NBD model to the users of the matching transactions and pages through them by descending lifetime value: revenue up to the reference date plus the revenue expected over the horizon. An order is a day with purchases.
+// @Tags customers
+// @Produce json
+// @Param reference_date query string false "Day the lifetime value is estimated on (YYYY-MM-DD); date_to or the last day of the data by default"
+// @Param horizon_days query int false "Days the projection looks ahead" default(365) maximum(3650)
+// @Param offset query int false "Users to skip" default(0)
+// @Param limit query int false "Users per page" default(100) maximum(10000)
+// @Param filter query FilterParams false "Transaction filters"
+// @Success 200 {object} TopCustomers
+// @Failure 400 {string} string "invalid parameter or too few users to fit the model"
+// @Router /clv [get]
+func (s *Server) GetTopCustomers(w http.ResponseWriter, r *http.Request) {
+	horizon, ok := intParam(w, r, "horizon_days", defaultHorizonDays, 1, maxHorizonDays)
+	if !ok {
+		return
+	}
+	offset, ok := intParam(w, r, "offset", 0, 0, math.MaxInt32)
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, r, "limit", defaultCLVPage, 1, maxCLVPage)
+	if !ok {
+		return
+	}
+	f, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+	repo := s.repos.Current()
+	t := repo.Columns()
+	reference, ok := referenceDay(w, r, f, t)
+	if !ok {
+		return
+	}
+
+	var customers []clv.Customer
+	var m clv.Model
+	var err error
+	if f.IsEmpty() {
+		fit := s.fits.Get(repo, t, reference)
+		customers, m, err = fit.Customers, fit.Model, fit.Err
+	} else {
+		customers = clv.Summarize(t, f.Select(repo), reference)
+		m, err = clv.Fit(customers, reference)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := make([]CustomerValue, len(customers))
+	for i := range customers {
+		c := &customers[i]
+		projected := m.Projected(c, float64(horizon))
+		values[i] = CustomerValue{
+			UserID:           t.UserID.Values[c.User],
+			Orders:           c.Orders,
+			LastPurchase:     formatDay(c.Last),
+			Historical:       c.Revenue,
+			ExpectedOrders:   m.ExpectedOrders(c, float64(horizon)),
+			ProbabilityAlive: m.Alive(c),
+			Projected:        projected,
+			CLV:              c.Revenue + projected,
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].CLV != values[j].CLV {
+			return values[i].CLV > values[j].CLV
+		}
+		return values[i].UserID < values[j].UserID
+	})
+
+	result := TopCustomers{
+		ReferenceDate: formatDay(reference),
+		HorizonDays:   horizon,
+		Model:         CLVModel{R: m.R, Alpha: m.Alpha, A: m.A, B: m.B},
+		Total:         len(values),
+		Offset:        offset,
+		Limit:         limit,
+		Users:         values[min(offset, len(values)):min(offset+limit, len(values))],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,115 @@
+// Package aggregate materializes the queries behind the dashboard
+// endpoints. A Set is built once per dataset, when the DataStore is
+// created or reloaded, so unfiltered requests only slice its results.
+package aggregate
+
+import (
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+)
+
+// The dashboard queries. Their result columns are read by position.
+var (
+	// CountryProducts is the revenue and transaction count per country and
+	// product name, highest revenue first.
+	CountryProducts = query.Query{
+		GroupBy: []string{"country", "product_name"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "total_price", As: "revenue"},
+			{Op: "count", As: "transactions"},
+		},
+		Sort: []query.Order{{By: "revenue", Desc: true}},
+	}
+	// Products is the quantity sold per product name, highest first. Stock
+	// is taken from the first transaction of the product.
+	Products = query.Query{
+		GroupBy: []string{"product_name"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "quantity", As: "quantity_sold"},
+			{Op: "first", Field: "stock", As: "stock"},
+		},
+		Sort: []query.Order{{By: "quantity_sold", Desc: true}},
+	}
+	// Months is the quantity and revenue per calendar month, in
+	// chronological order.
+	Months = query.Query{
+		GroupBy: []string{"date:month"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "quantity", As: "quantity"},
+			{Op: "sum", Field: "total_price", As: "revenue"},
+		},
+	}
+	// Regions is the revenue and quantity per region, highest revenue first.
+	Regions = query.Query{
+		GroupBy: []string{"region"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "total_price", As: "revenue"},
+			{Op: "sum", Field: "quantity", As: "quantity"},
+		},
+		Sort: []query.Order{{By: "revenue", Desc: true}},
+	}
+	// CountryRegions is the revenue, quantity and transaction count per
+	// country and region, highest revenue first.
+	CountryRegions = query.Query{
+		GroupBy: []string{"country", "region"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "total_price", As: "revenue"},
+			{Op: "sum", Field: "quantity", As: "quantity"},
+			{Op: "count", As: "transactions"},
+		},
+		Sort: []query.Order{{By: "revenue", Desc: true}},
+	}
+	// CategoryProducts is the revenue, quantity and transaction count per
+	// category and product ID, highest revenue first.
+	CategoryProducts = query.Query{
+		GroupBy: []string{"category", "product_id"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "total_price", As: "revenue"},
+			{Op: "sum", Field: "quantity", As: "quantity"},
+			{Op: "count", As: "transactions"},
+		},
+		Sort: []query.Order{{By: "revenue", Desc: true}},
+	}
+	// CategoryMonths is the revenue and quantity per category and calendar
+	// month, by category, then in chronological order.
+	CategoryMonths = query.Query{
+		GroupBy: []string{"category", "date:month"},
+		Metrics: []query.Metric{
+			{Op: "sum", Field: "total_price", As: "revenue"},
+			{Op: "sum", Field: "quantity", As: "quantity"},
+		},
+	}
+)
+
+// Set holds the results of the dashboard queries over a whole dataset.
+type Set struct {
+	CountryProducts  *query.Result
+	Products         *query.Result
+	Months           *query.Result
+	Regions          *query.Result
+	CategoryProducts *query.Result
+	CategoryMonths   *query.Result
+	CountryRegions   *query.Result
+}
+
+// Build runs every dashboard query over t.
+func Build(t *columnar.Table) *Set {
+	return &Set{
+		CountryProducts:  mustExecute(t, CountryProducts),
+		Products:         mustExecute(t, Products),
+		Months:           mustExecute(t, Months),
+		Regions:          mustExecute(t, Regions),
+		CategoryProducts: mustExecute(t, CategoryProducts),
+		CategoryMonths:   mustExecute(t, CategoryMonths),
+		CountryRegions:   mustExecute(t, CountryRegions),
+	}
+}
+
+// mustExecute runs one of the fixed queries above, which cannot be invalid.
+func mustExecute(t *columnar.Table, q query.Query) *query.Result {
+	res, err := query.Execute(t, nil, q)
+	if err != nil {
+		panic("aggregate: " + err.Error())
+	}
+	return res
+}
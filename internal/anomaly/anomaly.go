@@ -0,0 +1,220 @@
+// Package anomaly flags values of an evenly spaced series that depart from
+// what their neighbours predict, using robust statistics so that the
+// anomalies themselves don't skew the expectation.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Method is a detection method.
+type Method string
+
+const (
+	// RollingMAD compares each value with the median of the Window values
+	// before it, scaled by their median absolute deviation.
+	RollingMAD Method = "rolling_mad"
+	// Seasonal removes a centered moving-average trend and the median
+	// seasonal pattern, and scores the residuals against their median and
+	// MAD over the whole series. The fit is repeated without the anomalies
+	// of the first pass.
+	Seasonal Method = "seasonal"
+)
+
+// Methods lists every Method.
+var Methods = []Method{RollingMAD, Seasonal}
+
+// ParseMethod validates a method name.
+func ParseMethod(s string) (Method, error) {
+	for _, m := range Methods {
+		if strings.EqualFold(s, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown anomaly method %q (want rolling_mad or seasonal)", s)
+}
+
+// DefaultThreshold is the score beyond which a value is anomalous, the
+// usual cutoff for modified z-scores.
+const DefaultThreshold = 3.5
+
+// madScale turns a median absolute deviation into an estimate of the
+// standard deviation of normally distributed values.
+const madScale = 1.4826
+
+// Options configure a detection.
+type Options struct {
+	Method    Method  // RollingMAD when empty
+	Window    int     // values before each one, for RollingMAD
+	Season    int     // periods per season, for Seasonal
+	Threshold float64 // DefaultThreshold when zero
+}
+
+// Point is the verdict on one value.
+type Point struct {
+	// Scored is false when there is too little history before the value
+	// or its neighbours don't vary at all; the other fields are then zero.
+	Scored   bool
+	Expected float64
+	Lower    float64 // Expected minus Threshold deviations
+	Upper    float64 // Expected plus Threshold deviations
+	Score    float64 // robust z-score, positive above Expected
+	Anomaly  bool    // |Score| > Threshold
+}
+
+// Lookback is how many values before the first one of interest the method
+// reads.
+func (o Options) Lookback() int {
+	if o.Method == Seasonal {
+		return 2 * o.Season
+	}
+	return o.Window
+}
+
+// Detect scores every value.
+func Detect(values []float64, opts Options) ([]Point, error) {
+	if opts.Method == "" {
+		opts.Method = RollingMAD
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.Threshold < 0 {
+		return nil, fmt.Errorf("threshold must be positive")
+	}
+	if opts.Method == Seasonal {
+		if opts.Season < 2 {
+			return nil, fmt.Errorf("season must be at least 2 periods")
+		}
+		if len(values) < 2*opts.Season {
+			return nil, fmt.Errorf("seasonal decomposition needs at least %d periods, got %d", 2*opts.Season, len(values))
+		}
+		return seasonal(values, opts), nil
+	}
+	if opts.Window < 3 {
+		return nil, fmt.Errorf("window must be at least 3 periods")
+	}
+	return rollingMAD(values, opts), nil
+}
+
+// score places value against expected with spread sigma.
+func score(value, expected, sigma, threshold float64) Point {
+	if sigma == 0 {
+		return Point{}
+	}
+	p := Point{
+		Scored:   true,
+		Expected: expected,
+		Lower:    expected - threshold*sigma,
+		Upper:    expected + threshold*sigma,
+		Score:    (value - expected) / sigma,
+	}
+	p.Anomaly = math.Abs(p.Score) > threshold
+	return p
+}
+
+func rollingMAD(values []float64, opts Options) []Point {
+	out := make([]Point, len(values))
+	window := make([]float64, opts.Window)
+	for i := opts.Window; i < len(values); i++ {
+		copy(window, values[i-opts.Window:i])
+		med, mad := medianMAD(window)
+		out[i] = score(values[i], med, madScale*mad, opts.Threshold)
+	}
+	return out
+}
+
+func seasonal(values []float64, opts Options) []Point {
+	points := scoreSeasonal(values, values, opts)
+	// an anomaly drags the moving average of its neighbours along: fit
+	// again with the anomalies replaced by their expected values
+	cleaned := slices.Clone(values)
+	for i, p := range points {
+		if p.Anomaly {
+			cleaned[i] = p.Expected
+		}
+	}
+	return scoreSeasonal(values, cleaned, opts)
+}
+
+// scoreSeasonal scores values against the decomposition of fit.
+func scoreSeasonal(values, fit []float64, opts Options) []Point {
+	m, n := opts.Season, len(fit)
+	trend := movingAverage(fit, m)
+
+	// median detrended value per position in the season, centered on zero
+	pattern := make([]float64, m)
+	mean := 0.0
+	for j := range pattern {
+		var detrended []float64
+		for i := j; i < n; i += m {
+			detrended = append(detrended, fit[i]-trend[i])
+		}
+		pattern[j] = median(detrended)
+		mean += pattern[j] / float64(m)
+	}
+	for j := range pattern {
+		pattern[j] -= mean
+	}
+
+	residuals := make([]float64, n)
+	for i, v := range values {
+		residuals[i] = v - trend[i] - pattern[i%m]
+	}
+	med, mad := medianMAD(residuals)
+	out := make([]Point, n)
+	for i, v := range values {
+		out[i] = score(v, trend[i]+pattern[i%m]+med, madScale*mad, opts.Threshold)
+	}
+	return out
+}
+
+// movingAverage is the centered moving average over m values, a 2×m one
+// for even m so it stays centered. The ends, which the window doesn't fit,
+// repeat the nearest average.
+func movingAverage(values []float64, m int) []float64 {
+	n, half := len(values), m/2
+	out := make([]float64, n)
+	for i := half; i < n-half; i++ {
+		sum := 0.0
+		if m%2 == 1 {
+			for _, v := range values[i-half : i+half+1] {
+				sum += v
+			}
+			out[i] = sum / float64(m)
+			continue
+		}
+		for _, v := range values[i-half+1 : i+half] {
+			sum += v
+		}
+		sum += (values[i-half] + values[i+half]) / 2
+		out[i] = sum / float64(m)
+	}
+	for i := 0; i < half; i++ {
+		out[i] = out[half]
+		out[n-1-i] = out[n-1-half]
+	}
+	return out
+}
+
+// medianMAD returns the median of values and their median absolute
+// deviation from it. It reorders values.
+func medianMAD(values []float64) (med, mad float64) {
+	med = median(values)
+	for i, v := range values {
+		values[i] = math.Abs(v - med)
+	}
+	return med, median(values)
+}
+
+func median(values []float64) float64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
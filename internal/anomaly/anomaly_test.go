@@ -0,0 +1,70 @@
+package anomaly
+
+import "testing"
+
+func TestRollingMAD(t *testing.T) {
+	values := []float64{10, 12, 9, 11, 10, 13, 9, 11, 50, 10, 12}
+	points, err := Detect(values, Options{Window: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		if points[i].Scored {
+			t.Errorf("point %d has no full window but was scored", i)
+		}
+	}
+	spike := points[8]
+	if !spike.Anomaly || spike.Score <= 0 || spike.Expected != 10.5 || spike.Upper >= 50 {
+		t.Errorf("spike = %+v", spike)
+	}
+	// the spike is in the window but doesn't move the median
+	if p := points[9]; p.Anomaly || p.Expected != 11 {
+		t.Errorf("point after the spike = %+v", p)
+	}
+
+	flat, _ := Detect([]float64{0, 0, 0, 0, 5}, Options{Window: 4})
+	if flat[4].Scored {
+		t.Error("a window without spread can't score")
+	}
+	if _, err := Detect(values, Options{Window: 1}); err == nil {
+		t.Error("a window of 1 should fail")
+	}
+}
+
+func TestSeasonal(t *testing.T) {
+	pattern := []float64{0, 5, 10, 5}
+	var values []float64
+	for i := 0; i < 24; i++ {
+		// a trend, the pattern and a little noise
+		values = append(values, 100+float64(i)+pattern[i%4]+float64(i%3)-1)
+	}
+	values[14] -= 30
+
+	points, err := Detect(values, Options{Method: Seasonal, Season: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range points {
+		if p.Anomaly != (i == 14) {
+			t.Errorf("point %d: %+v", i, p)
+		}
+	}
+	if p := points[14]; p.Score >= 0 || p.Expected < values[14]+20 || p.Lower < values[14] {
+		t.Errorf("dip = %+v", p)
+	}
+
+	if _, err := Detect(values[:7], Options{Method: Seasonal, Season: 4}); err == nil {
+		t.Error("fewer than two seasons should fail")
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	got := movingAverage([]float64{1, 2, 3, 4, 5, 6}, 2)
+	// (x[i-1]/2 + x[i] + x[i+1]/2) / 2 = x[i] for a line, ends repeated
+	want := []float64{2, 2, 3, 4, 5, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("movingAverage = %v, want %v", got, want)
+		}
+	}
+}
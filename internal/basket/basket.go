@@ -0,0 +1,92 @@
+// Package basket finds the products bought together. A basket is the set
+// of products one user bought on one day; frequent itemsets are mined with
+// FP-Growth and turned into association rules.
+package basket
+
+import (
+	"slices"
+
+	"Dashlytics/internal/columnar"
+)
+
+// Baskets holds baskets of product codes, from the table's ProductID
+// dictionary, back to back.
+type Baskets struct {
+	items []uint32
+	ends  []int32 // basket i is items[ends[i-1]:ends[i]]
+	// Products is the number of product codes, the dictionary's cardinality.
+	Products int
+}
+
+// Len is the number of baskets.
+func (b *Baskets) Len() int {
+	return len(b.ends)
+}
+
+// At returns the distinct products of basket i in ascending order.
+func (b *Baskets) At(i int) []uint32 {
+	start := int32(0)
+	if i > 0 {
+		start = b.ends[i-1]
+	}
+	return b.items[start:b.ends[i]]
+}
+
+// Counts returns how many baskets hold each product.
+func (b *Baskets) Counts() []int {
+	counts := make([]int, b.Products)
+	for _, p := range b.items {
+		counts[p]++
+	}
+	return counts
+}
+
+// Build groups the given rows of t, all of them when rows is nil, into
+// baskets per user and day.
+func Build(t *columnar.Table, rows []int32) *Baskets {
+	if rows == nil {
+		rows = make([]int32, t.Len())
+		for i := range rows {
+			rows[i] = int32(i)
+		}
+	}
+
+	// rows by user, then by day within each user
+	users := t.UserID.Cardinality()
+	offsets := make([]int32, users+1)
+	for _, i := range rows {
+		offsets[t.UserID.Codes[i]+1]++
+	}
+	for u := 0; u < users; u++ {
+		offsets[u+1] += offsets[u]
+	}
+	byUser := make([]int32, len(rows))
+	next := append([]int32(nil), offsets[:users]...)
+	for _, i := range rows {
+		u := t.UserID.Codes[i]
+		byUser[next[u]] = i
+		next[u]++
+	}
+
+	b := &Baskets{items: make([]uint32, 0, len(rows)), Products: t.ProductID.Cardinality()}
+	for u := 0; u < users; u++ {
+		own := byUser[offsets[u]:offsets[u+1]]
+		slices.SortFunc(own, func(x, y int32) int { return int(t.Date[x] - t.Date[y]) })
+		for start := 0; start < len(own); {
+			end := start + 1
+			for end < len(own) && t.Date[own[end]] == t.Date[own[start]] {
+				end++
+			}
+			first := len(b.items)
+			for _, i := range own[start:end] {
+				b.items = append(b.items, t.ProductID.Codes[i])
+			}
+			basket := b.items[first:]
+			slices.Sort(basket)
+			b.items = b.items[:first+len(slices.Compact(basket))]
+			b.ends = append(b.ends, int32(len(b.items)))
+			start = end
+		}
+	}
+	return b
+}
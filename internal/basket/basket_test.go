@@ -0,0 +1,142 @@
+package basket
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+func testTable() *columnar.Table {
+	var txs []domain.Transaction
+	add := func(user, date string, products ...string) {
+		d, _ := domain.ParseDate(date)
+		for _, p := range products {
+			txs = append(txs, domain.Transaction{UserID: user, Date: d, ProductID: p})
+		}
+	}
+	add("u1", "2024-01-01", "bread", "butter", "jam")
+	add("u1", "2024-01-02", "bread", "butter")
+	add("u2", "2024-01-01", "bread", "butter", "bread")
+	add("u2", "2024-01-05", "milk")
+	add("u3", "2024-01-01", "bread", "jam")
+	add("u1", "2024-01-01", "milk") // joins u1's first basket
+	return columnar.FromTransactions(txs)
+}
+
+func names(t *columnar.Table, items []uint32) []string {
+	var out []string
+	for _, p := range items {
+		out = append(out, t.ProductID.Values[p])
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestBuild(t *testing.T) {
+	tbl := testTable()
+	b := Build(tbl, nil)
+	if b.Len() != 5 {
+		t.Fatalf("got %d baskets, want 5", b.Len())
+	}
+	var sizes []int
+	for i := 0; i < b.Len(); i++ {
+		sizes = append(sizes, len(b.At(i)))
+	}
+	if want := []int{4, 2, 2, 1, 2}; !slices.Equal(sizes, want) {
+		t.Errorf("basket sizes = %v, want %v", sizes, want)
+	}
+}
+
+func TestMineAndRules(t *testing.T) {
+	tbl := testTable()
+	b := Build(tbl, nil)
+	itemsets, err := Mine(b, Options{MinCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, s := range itemsets {
+		got[key(s.Items)] = s.Count
+	}
+	code := func(p string) uint32 { c, _ := tbl.ProductID.Code(p); return c }
+	pair := []uint32{code("bread"), code("butter")}
+	slices.Sort(pair)
+	if got[key(pair)] != 3 || got[key([]uint32{code("bread")})] != 4 || len(itemsets) != 6 {
+		t.Errorf("itemsets = %v", itemsets)
+	}
+
+	rules := Rules(itemsets, b.Len(), 0.7)
+	var found bool
+	for _, r := range rules {
+		if names(tbl, r.Antecedent)[0] == "butter" && len(r.Antecedent) == 1 && r.Consequent == code("bread") {
+			found = true
+			// butter is always bought with bread, which 4 of 5 baskets hold
+			if r.Confidence != 1 || r.Lift != 1.25 || r.Support != 0.6 {
+				t.Errorf("butter => bread = %+v", r)
+			}
+		}
+		if r.Confidence < 0.7 {
+			t.Errorf("rule below the minimum confidence: %+v", r)
+		}
+	}
+	if !found {
+		t.Errorf("no butter => bread in %+v", rules)
+	}
+
+	related := RelatedTo(b, code("jam"), 1)
+	// bread and milk lift jam equally, bread shares more baskets
+	if len(related) != 3 || related[0].Product != code("bread") || related[0].Lift != 1.25 || related[2].Product != code("butter") {
+		t.Errorf("related to jam = %+v", related)
+	}
+}
+
+// TestMineMatchesBruteForce checks FP-Growth against counting every subset.
+func TestMineMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := &Baskets{Products: 12}
+	for i := 0; i < 300; i++ {
+		var basket []uint32
+		for p := uint32(0); p < 12; p++ {
+			if rng.Intn(12) < int(12-p)/2 {
+				basket = append(basket, p)
+			}
+		}
+		b.items = append(b.items, basket...)
+		b.ends = append(b.ends, int32(len(b.items)))
+	}
+
+	itemsets, err := Mine(b, Options{MinCount: 20, MaxLength: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{}
+	for i := 0; i < b.Len(); i++ {
+		items := b.At(i)
+		for x := 0; x < len(items); x++ {
+			want[key(items[x:x+1])]++
+			for y := x + 1; y < len(items); y++ {
+				want[key([]uint32{items[x], items[y]})]++
+				for z := y + 1; z < len(items); z++ {
+					want[key([]uint32{items[x], items[y], items[z]})]++
+				}
+			}
+		}
+	}
+	frequent := 0
+	for _, c := range want {
+		if c >= 20 {
+			frequent++
+		}
+	}
+	if len(itemsets) != frequent {
+		t.Errorf("mined %d itemsets, want %d", len(itemsets), frequent)
+	}
+	for _, s := range itemsets {
+		if want[key(s.Items)] != s.Count {
+			t.Errorf("%v counted %d, want %d", s.Items, s.Count, want[key(s.Items)])
+		}
+	}
+}
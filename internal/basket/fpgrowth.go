@@ -0,0 +1,218 @@
+package basket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// MaxLength bounds the size of the itemsets mined.
+const MaxLength = 4
+
+// MaxItemsets bounds how many itemsets one mining run may find, so a
+// support threshold too low for the data fails fast instead of exhausting
+// memory.
+const MaxItemsets = 200000
+
+// Options configure the mining.
+type Options struct {
+	// MinSupport is the share of baskets an itemset must appear in.
+	MinSupport float64
+	// MinCount is the number of baskets an itemset must appear in, 2 when
+	// lower; the higher of it and MinSupport applies.
+	MinCount int
+	// MaxLength bounds the itemset size, 3 when zero.
+	MaxLength int
+}
+
+// Itemset is a set of products bought together.
+type Itemset struct {
+	Items []uint32 // ascending product codes
+	Count int      // baskets holding every item
+}
+
+// errTooMany aborts mining past MaxItemsets.
+var errTooMany = errors.New("too many itemsets")
+
+// Mine returns the frequent itemsets of b with FP-Growth, most frequent
+// first.
+func Mine(b *Baskets, opts Options) ([]Itemset, error) {
+	if opts.MaxLength == 0 {
+		opts.MaxLength = 3
+	}
+	if opts.MaxLength < 1 || opts.MaxLength > MaxLength {
+		return nil, fmt.Errorf("max length must be between 1 and %d", MaxLength)
+	}
+	if opts.MinSupport < 0 || opts.MinSupport > 1 {
+		return nil, fmt.Errorf("min support must be between 0 and 1")
+	}
+	minCount := max(opts.MinCount, 2, int(math.Ceil(opts.MinSupport*float64(b.Len()))))
+
+	// rank the frequent products, most frequent first
+	counts := b.Counts()
+	var products []uint32
+	for p, c := range counts {
+		if c >= minCount {
+			products = append(products, uint32(p))
+		}
+	}
+	slices.SortFunc(products, func(x, y uint32) int {
+		if counts[x] != counts[y] {
+			return counts[y] - counts[x]
+		}
+		return int(x) - int(y)
+	})
+	rank := make([]int32, b.Products)
+	for i := range rank {
+		rank[i] = -1
+	}
+	for r, p := range products {
+		rank[p] = int32(r)
+	}
+
+	tree := newTree()
+	var path []int32
+	for i := 0; i < b.Len(); i++ {
+		path = path[:0]
+		for _, p := range b.At(i) {
+			if rank[p] >= 0 {
+				path = append(path, rank[p])
+			}
+		}
+		slices.Sort(path)
+		tree.insert(path, 1)
+	}
+
+	var out []Itemset
+	err := tree.mine(nil, minCount, opts.MaxLength, func(ranks []int32, count int) error {
+		if len(out) == MaxItemsets {
+			return errTooMany
+		}
+		items := make([]uint32, len(ranks))
+		for i, r := range ranks {
+			items[i] = products[r]
+		}
+		slices.Sort(items)
+		out = append(out, Itemset{Items: items, Count: count})
+		return nil
+	})
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d itemsets at this support: raise the minimum support", MaxItemsets)
+	}
+	slices.SortFunc(out, func(x, y Itemset) int {
+		if x.Count != y.Count {
+			return y.Count - x.Count
+		}
+		if len(x.Items) != len(y.Items) {
+			return len(x.Items) - len(y.Items)
+		}
+		return slices.Compare(x.Items, y.Items)
+	})
+	return out, nil
+}
+
+// key identifies an itemset in a map.
+func key(items []uint32) string {
+	b := make([]byte, 0, 4*len(items))
+	for _, p := range items {
+		b = binary.LittleEndian.AppendUint32(b, p)
+	}
+	return string(b)
+}
+
+// fpNode is a prefix of ranks shared by count baskets.
+type fpNode struct {
+	rank     int32 // -1 at the root
+	count    int
+	parent   *fpNode
+	children []*fpNode
+	next     *fpNode // the next node of the same rank
+}
+
+// fpTree is an FP-tree over ranks, 0 being the most frequent item.
+// Conditional trees hold few of the ranks, so the headers are maps.
+type fpTree struct {
+	root   *fpNode
+	heads  map[int32]*fpNode // the nodes of each rank, linked through next
+	counts map[int32]int     // count of each rank
+}
+
+func newTree() *fpTree {
+	return &fpTree{root: &fpNode{rank: -1}, heads: make(map[int32]*fpNode), counts: make(map[int32]int)}
+}
+
+// insert adds count baskets holding the ascending ranks of path.
+func (t *fpTree) insert(path []int32, count int) {
+	node := t.root
+	for _, r := range path {
+		var child *fpNode
+		for _, c := range node.children {
+			if c.rank == r {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			child = &fpNode{rank: r, parent: node, next: t.heads[r]}
+			t.heads[r] = child
+			node.children = append(node.children, child)
+		}
+		child.count += count
+		t.counts[r] += count
+		node = child
+	}
+}
+
+// mine emits suffix extended by each frequent rank of t, least frequent
+// first, and recurses into the tree of the baskets holding it.
+func (t *fpTree) mine(suffix []int32, minCount, maxLength int, emit func(ranks []int32, count int) error) error {
+	ranks := make([]int32, 0, len(t.counts))
+	for r, c := range t.counts {
+		if c >= minCount {
+			ranks = append(ranks, r)
+		}
+	}
+	// least frequent first
+	slices.Sort(ranks)
+	slices.Reverse(ranks)
+	for _, r := range ranks {
+		itemset := append(slices.Clip(suffix), r)
+		if err := emit(itemset, t.counts[r]); err != nil {
+			return err
+		}
+		if len(itemset) == maxLength {
+			continue
+		}
+
+		// the paths above the nodes of r, weighted by their counts
+		counts := make(map[int32]int)
+		for n := t.heads[r]; n != nil; n = n.next {
+			for p := n.parent; p.rank >= 0; p = p.parent {
+				counts[p.rank] += n.count
+			}
+		}
+		if len(counts) == 0 {
+			continue
+		}
+		cond := newTree()
+		var path []int32
+		for n := t.heads[r]; n != nil; n = n.next {
+			path = path[:0]
+			for p := n.parent; p.rank >= 0; p = p.parent {
+				if counts[p.rank] >= minCount {
+					path = append(path, p.rank)
+				}
+			}
+			if len(path) > 0 {
+				slices.Reverse(path)
+				cond.insert(path, n.count)
+			}
+		}
+		if err := cond.mine(itemset, minCount, maxLength, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
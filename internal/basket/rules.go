@@ -0,0 +1,105 @@
+package basket
+
+import (
+	"slices"
+)
+
+// Rule says that baskets holding Antecedent tend to hold Consequent too.
+type Rule struct {
+	Antecedent []uint32
+	Consequent uint32
+	Count      int     // baskets holding both
+	Support    float64 // share of baskets holding both
+	Confidence float64 // share of the baskets holding Antecedent that hold Consequent
+	Lift       float64 // Confidence over the share of baskets holding Consequent
+}
+
+// Rules derives the rules with a single consequent from itemsets, as mined
+// from the given number of baskets, keeping those of at least minConfidence.
+// Every subset of a frequent itemset is frequent, so the supports needed
+// are all in itemsets.
+func Rules(itemsets []Itemset, baskets int, minConfidence float64) []Rule {
+	counts := make(map[string]int, len(itemsets))
+	for _, s := range itemsets {
+		counts[key(s.Items)] = s.Count
+	}
+	var rules []Rule
+	antecedent := make([]uint32, 0, MaxLength)
+	for _, s := range itemsets {
+		if len(s.Items) < 2 {
+			continue
+		}
+		for i, consequent := range s.Items {
+			antecedent = append(append(antecedent[:0], s.Items[:i]...), s.Items[i+1:]...)
+			confidence := float64(s.Count) / float64(counts[key(antecedent)])
+			if confidence < minConfidence {
+				continue
+			}
+			rules = append(rules, Rule{
+				Antecedent: slices.Clone(antecedent),
+				Consequent: consequent,
+				Count:      s.Count,
+				Support:    float64(s.Count) / float64(baskets),
+				Confidence: confidence,
+				Lift:       confidence * float64(baskets) / float64(counts[key([]uint32{consequent})]),
+			})
+		}
+	}
+	return rules
+}
+
+// Related is a product bought together with another one.
+type Related struct {
+	Product    uint32
+	Count      int     // baskets holding both
+	Confidence float64 // share of the other product's baskets holding this one
+	Lift       float64 // Confidence over the share of all baskets holding this one
+}
+
+// RelatedTo returns the products sharing at least minCount baskets with
+// product, by descending lift, then count.
+func RelatedTo(b *Baskets, product uint32, minCount int) []Related {
+	counts := b.Counts()
+	if int(product) >= len(counts) || counts[product] == 0 {
+		return nil
+	}
+	together := make(map[uint32]int)
+	for i := 0; i < b.Len(); i++ {
+		items := b.At(i)
+		if _, ok := slices.BinarySearch(items, product); !ok {
+			continue
+		}
+		for _, p := range items {
+			if p != product {
+				together[p]++
+			}
+		}
+	}
+
+	var out []Related
+	for p, n := range together {
+		if n < minCount {
+			continue
+		}
+		confidence := float64(n) / float64(counts[product])
+		out = append(out, Related{
+			Product:    p,
+			Count:      n,
+			Confidence: confidence,
+			Lift:       confidence * float64(b.Len()) / float64(counts[p]),
+		})
+	}
+	slices.SortFunc(out, func(x, y Related) int {
+		switch {
+		case x.Lift != y.Lift:
+			if x.Lift > y.Lift {
+				return -1
+			}
+			return 1
+		case x.Count != y.Count:
+			return y.Count - x.Count
+		}
+		return int(x.Product) - int(y.Product)
+	})
+	return out
+}
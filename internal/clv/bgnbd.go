@@ -0,0 +1,255 @@
+package clv
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// MinCustomers is the fewest customers Fit accepts.
+const MinCustomers = 10
+
+// MaxSample bounds the customers the model is fitted to; larger sets are
+// sampled evenly, which changes the estimates little and keeps a fit to a
+// fraction of a second.
+const MaxSample = 10000
+
+// Model is a fitted BG/NBD model (Fader, Hardie and Lee, 2005). While
+// alive, a customer orders at a rate drawn from Gamma(R, Alpha), per day;
+// after each order they drop out with a probability drawn from Beta(A, B).
+type Model struct {
+	R, Alpha, A, B float64
+	Reference      int32 // the day the customers' histories end
+}
+
+// Fit estimates the model by maximum likelihood over the customers, whose
+// histories end on reference, or an even sample of MaxSample of them.
+func Fit(customers []Customer, reference int32) (Model, error) {
+	// customers with the same history weigh in once
+	type history struct {
+		x      int
+		tx, tt float64
+	}
+	weights := make(map[history]float64)
+	repeaters, sampled := 0, 0
+	step := max(1, (len(customers)+MaxSample-1)/MaxSample)
+	for i := 0; i < len(customers); i += step {
+		c := &customers[i]
+		weights[history{c.Repeats(), float64(c.Last - c.First), float64(reference - c.First)}]++
+		sampled++
+		if c.Orders > 1 {
+			repeaters++
+		}
+	}
+	if len(customers) < MinCustomers || repeaters == 0 {
+		return Model{}, fmt.Errorf("at least %d customers, some of them ordering on more than one day, are needed to fit the model", MinCustomers)
+	}
+	histories := make([]history, 0, len(weights))
+	for h := range weights {
+		histories = append(histories, h)
+	}
+	// a fixed order keeps the fit deterministic
+	slices.SortFunc(histories, func(p, q history) int {
+		if c := cmp.Compare(p.x, q.x); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(p.tx, q.tx); c != 0 {
+			return c
+		}
+		return cmp.Compare(p.tt, q.tt)
+	})
+
+	var meanAge float64
+	for h, w := range weights {
+		meanAge += w * h.tt
+	}
+	meanAge /= float64(sampled)
+
+	// the parameters are searched in log space, which keeps them positive
+	const bound = 12.0
+	negLL := func(p []float64) float64 {
+		for _, v := range p {
+			if math.Abs(v) > bound {
+				return math.Inf(1)
+			}
+		}
+		m := Model{R: math.Exp(p[0]), Alpha: math.Exp(p[1]), A: math.Exp(p[2]), B: math.Exp(p[3])}
+		var sum, head float64
+		for i, h := range histories {
+			if i == 0 || h.x != histories[i-1].x {
+				head = m.logHead(h.x)
+			}
+			sum += weights[h] * m.logLikelihood(head, h.x, h.tx, h.tt)
+		}
+		return -sum / float64(sampled)
+	}
+	p := nelderMead(negLL, []float64{0, math.Log(max(meanAge, 1)), 0, 0})
+	m := Model{R: math.Exp(p[0]), Alpha: math.Exp(p[1]), A: math.Exp(p[2]), B: math.Exp(p[3]), Reference: reference}
+	if ll := negLL(p); math.IsNaN(ll) || math.IsInf(ll, 0) {
+		return Model{}, fmt.Errorf("the model could not be fitted to these customers")
+	}
+	return m, nil
+}
+
+// logHead is the part of the log-likelihood that only depends on the
+// number of repeat orders x.
+func (m Model) logHead(x int) float64 {
+	r, alpha, a, b, n := m.R, m.Alpha, m.A, m.B, float64(x)
+	lgamma := func(v float64) float64 {
+		l, _ := math.Lgamma(v)
+		return l
+	}
+	return lgamma(r+n) - lgamma(r) + r*math.Log(alpha) +
+		lgamma(a+b) + lgamma(b+n) - lgamma(b) - lgamma(a+b+n)
+}
+
+// logLikelihood is the log-likelihood of x repeat orders, the last tx days
+// after the first, in a history of tt days, given logHead(x).
+func (m Model) logLikelihood(head float64, x int, tx, tt float64) float64 {
+	r, alpha, a, b, n := m.R, m.Alpha, m.A, m.B, float64(x)
+	a3 := -(r + n) * math.Log(alpha+tt)
+	if x == 0 {
+		return head + a3
+	}
+	a4 := math.Log(a) - math.Log(b+n-1) - (r+n)*math.Log(alpha+tx)
+	hi := max(a3, a4)
+	return head + hi + math.Log(math.Exp(a3-hi)+math.Exp(a4-hi))
+}
+
+// age is the days from the customer's first order to the model's reference.
+func (m Model) age(c *Customer) float64 {
+	return float64(m.Reference - c.First)
+}
+
+// deadOdds is the odds that the customer has dropped out by the reference
+// day, zero for customers without repeat orders, who can't have.
+func (m Model) deadOdds(c *Customer) float64 {
+	x := float64(c.Repeats())
+	if x == 0 {
+		return 0
+	}
+	return m.A / (m.B + x - 1) * math.Pow((m.Alpha+m.age(c))/(m.Alpha+float64(c.Last-c.First)), m.R+x)
+}
+
+// Alive is the probability that the customer is still ordering.
+func (m Model) Alive(c *Customer) float64 {
+	return 1 / (1 + m.deadOdds(c))
+}
+
+// ExpectedOrders is the number of orders the customer is expected to place
+// in the days after the reference day.
+func (m Model) ExpectedOrders(c *Customer, days float64) float64 {
+	// the formula has a removable singularity at a = 1, where it cancels
+	// out to nothing but rounding errors; take the mean of either side
+	const near = 1e-4
+	if math.Abs(m.A-1) < near {
+		lo, hi := m, m
+		lo.A, hi.A = 1-2*near, 1+2*near
+		return (lo.ExpectedOrders(c, days) + hi.ExpectedOrders(c, days)) / 2
+	}
+	r, alpha, a, b := m.R, m.Alpha, m.A, m.B
+	x, tt := float64(c.Repeats()), m.age(c)
+	z := days / (alpha + tt + days)
+	head := (a + b + x - 1) / (a - 1)
+	tail := 1 - math.Pow((alpha+tt)/(alpha+tt+days), r+x)*hyp2f1(r+x, b+x, a+b+x-1, z)
+	e := head * tail / (1 + m.deadOdds(c))
+	if math.IsNaN(e) || math.IsInf(e, 0) || e < 0 {
+		return 0
+	}
+	return e
+}
+
+// Projected is the revenue expected from the customer in the days after the
+// reference day, at their average order value.
+func (m Model) Projected(c *Customer, days float64) float64 {
+	return m.ExpectedOrders(c, days) * c.AvgOrderValue()
+}
+
+// hyp2f1 is the Gauss hypergeometric function 2F1(a, b; c; z) for 0 <= z < 1.
+func hyp2f1(a, b, c, z float64) float64 {
+	term, sum := 1.0, 1.0
+	for n := 0.0; n < 100000; n++ {
+		term *= (a + n) * (b + n) / ((c + n) * (n + 1)) * z
+		sum += term
+		if math.Abs(term) <= 1e-15*math.Abs(sum) {
+			break
+		}
+	}
+	return sum
+}
+
+// nelderMead minimizes f from x0 with the Nelder-Mead simplex method.
+func nelderMead(f func([]float64) float64, x0 []float64) []float64 {
+	const (
+		maxIterations = 2000
+		tolerance     = 1e-10
+	)
+	n := len(x0)
+	points := make([][]float64, n+1)
+	values := make([]float64, n+1)
+	for i := range points {
+		points[i] = slices.Clone(x0)
+		if i > 0 {
+			points[i][i-1] += 0.5
+		}
+		values[i] = f(points[i])
+	}
+	order := make([]int, n+1)
+	along := func(from, to []float64, t float64) []float64 {
+		p := make([]float64, n)
+		for i := range p {
+			p[i] = from[i] + t*(to[i]-from[i])
+		}
+		return p
+	}
+
+	for it := 0; it < maxIterations; it++ {
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortFunc(order, func(i, j int) int { return cmp.Compare(values[i], values[j]) })
+		best, worst, second := order[0], order[n], order[n-1]
+		if math.Abs(values[worst]-values[best]) <= tolerance*(math.Abs(values[best])+tolerance) {
+			break
+		}
+
+		centroid := make([]float64, n)
+		for _, i := range order[:n] {
+			for k := range centroid {
+				centroid[k] += points[i][k] / float64(n)
+			}
+		}
+		reflected := along(centroid, points[worst], -1)
+		fr := f(reflected)
+		switch {
+		case fr < values[best]:
+			expanded := along(centroid, points[worst], -2)
+			if fe := f(expanded); fe < fr {
+				points[worst], values[worst] = expanded, fe
+			} else {
+				points[worst], values[worst] = reflected, fr
+			}
+		case fr < values[second]:
+			points[worst], values[worst] = reflected, fr
+		default:
+			contracted := along(centroid, points[worst], 0.5)
+			if fc := f(contracted); fc < values[worst] {
+				points[worst], values[worst] = contracted, fc
+				continue
+			}
+			// shrink towards the best point
+			for _, i := range order[1:] {
+				points[i] = along(points[best], points[i], 0.5)
+				values[i] = f(points[i])
+			}
+		}
+	}
+	best := 0
+	for i := range values {
+		if values[i] < values[best] {
+			best = i
+		}
+	}
+	return points[best]
+}
@@ -0,0 +1,57 @@
+package clv
+
+import (
+	"sync"
+
+	"Dashlytics/internal/columnar"
+)
+
+// maxCachedFits bounds the reference days a Cache keeps fits for.
+const maxCachedFits = 8
+
+// Fitted is every customer of a dataset up to a reference day, with the
+// model fitted to them.
+type Fitted struct {
+	Customers []Customer // in the order of their user codes
+	Model     Model
+	Err       error // why the model could not be fitted
+
+	once sync.Once
+}
+
+// Cache keeps the fits to the customers of the latest dataset by reference
+// day, dropping the oldest day beyond a few. The zero value is ready to use.
+type Cache struct {
+	mu      sync.Mutex
+	dataset any
+	fits    map[int32]*Fitted
+	order   []int32
+}
+
+// Get returns the fit to the customers of t up to reference, computed once
+// per day. dataset identifies t, such as the repository holding it; the
+// fits of the previous one are dropped when it changes.
+func (c *Cache) Get(dataset any, t *columnar.Table, reference int32) *Fitted {
+	c.mu.Lock()
+	if c.fits == nil || c.dataset != dataset {
+		c.dataset, c.fits, c.order = dataset, make(map[int32]*Fitted), nil
+	}
+	fit, ok := c.fits[reference]
+	if !ok {
+		if len(c.order) == maxCachedFits {
+			delete(c.fits, c.order[0])
+			c.order = c.order[1:]
+		}
+		fit = &Fitted{}
+		c.fits[reference] = fit
+		c.order = append(c.order, reference)
+	}
+	c.mu.Unlock()
+
+	// other days don't wait while this one is fitted
+	fit.once.Do(func() {
+		fit.Customers = Summarize(t, nil, reference)
+		fit.Model, fit.Err = Fit(fit.Customers, reference)
+	})
+	return fit
+}
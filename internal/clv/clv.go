@@ -0,0 +1,86 @@
+// Package clv estimates customer lifetime value: the revenue a user has
+// brought so far plus the revenue a BG/NBD model expects from them over a
+// horizon.
+package clv
+
+import (
+	"slices"
+
+	"Dashlytics/internal/columnar"
+)
+
+// Customer is the purchase history of one user up to a reference day. An
+// order is a day with purchases, so several products bought on one day
+// count once, as in the model.
+type Customer struct {
+	User         uint32 // code in the table's UserID dictionary
+	First, Last  int32  // days of the first and last order
+	Orders       int
+	Transactions int
+	Revenue      float64
+}
+
+// Repeats is the number of orders after the first, the x of BG/NBD.
+func (c *Customer) Repeats() int {
+	return c.Orders - 1
+}
+
+// AvgOrderValue is the revenue per order.
+func (c *Customer) AvgOrderValue() float64 {
+	return c.Revenue / float64(c.Orders)
+}
+
+// Summarize returns the customers of the given rows of t, all of them when
+// rows is nil, in the order of their user codes. Purchases after reference
+// are ignored, and users without earlier purchases left out.
+func Summarize(t *columnar.Table, rows []int32, reference int32) []Customer {
+	forEach := func(fn func(i int32)) {
+		if rows == nil {
+			for i := 0; i < t.Len(); i++ {
+				fn(int32(i))
+			}
+			return
+		}
+		for _, i := range rows {
+			fn(i)
+		}
+	}
+
+	// the days of each user's purchases, grouped by user
+	users := t.UserID.Cardinality()
+	offsets := make([]int32, users+1)
+	n := 0
+	forEach(func(i int32) {
+		if t.Date[i] <= reference {
+			offsets[t.UserID.Codes[i]+1]++
+			n++
+		}
+	})
+	for u := 0; u < users; u++ {
+		offsets[u+1] += offsets[u]
+	}
+	days := make([]int32, n)
+	revenue := make([]float64, users)
+	next := append([]int32(nil), offsets[:users]...)
+	forEach(func(i int32) {
+		if t.Date[i] <= reference {
+			u := t.UserID.Codes[i]
+			days[next[u]] = t.Date[i]
+			next[u]++
+			revenue[u] += t.TotalPrice[i]
+		}
+	})
+
+	var customers []Customer
+	for u := 0; u < users; u++ {
+		own := days[offsets[u]:offsets[u+1]]
+		if len(own) == 0 {
+			continue
+		}
+		slices.Sort(own)
+		c := Customer{User: uint32(u), First: own[0], Last: own[len(own)-1], Transactions: len(own), Revenue: revenue[u]}
+		c.Orders = len(slices.Compact(own))
+		customers = append(customers, c)
+	}
+	return customers
+}
@@ -0,0 +1,159 @@
+package clv
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+func TestSummarize(t *testing.T) {
+	day := func(s string) time.Time { d, _ := domain.ParseDate(s); return d }
+	tbl := columnar.FromTransactions([]domain.Transaction{
+		{UserID: "u1", Date: day("2024-01-01"), TotalPrice: 10},
+		{UserID: "u2", Date: day("2024-01-05"), TotalPrice: 7},
+		{UserID: "u1", Date: day("2024-01-01"), TotalPrice: 5},
+		{UserID: "u1", Date: day("2024-01-10"), TotalPrice: 15},
+		{UserID: "u1", Date: day("2024-02-01"), TotalPrice: 100},
+		{UserID: "u3", Date: day("2024-03-01"), TotalPrice: 1},
+	})
+	customers := Summarize(tbl, nil, columnar.DayOf(day("2024-01-31")))
+	if len(customers) != 2 {
+		t.Fatalf("Expected u3 to be left out, got %+v", customers)
+	}
+	u1 := customers[0]
+	if u1.Orders != 2 || u1.Transactions != 3 || u1.Revenue != 30 || u1.Last-u1.First != 9 || u1.AvgOrderValue() != 15 {
+		t.Errorf("Unexpected summary of u1: %+v", u1)
+	}
+}
+
+func TestHyp2f1(t *testing.T) {
+	for _, z := range []float64{0, 0.3, 0.9} {
+		want := 1.0
+		if z > 0 {
+			want = -math.Log(1-z) / z
+		}
+		if got := hyp2f1(1, 1, 2, z); math.Abs(got-want) > 1e-9 {
+			t.Errorf("2F1(1,1;2;%v) = %v, want %v", z, got, want)
+		}
+	}
+}
+
+// gamma draws from Gamma(shape, 1) with the method of Marsaglia and Tsang.
+func gamma(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return gamma(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := math.Pow(1+c*x, 3)
+		if v > 0 && math.Log(rng.Float64()) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// TestFitPredictsHoldout simulates customers of a known BG/NBD process and
+// checks that the fitted model predicts the orders of a holdout year.
+func TestFitPredictsHoldout(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const r, alpha, a, b = 0.5, 40.0, 0.8, 2.5
+	const reference, horizon = 365, 365
+	var customers []Customer
+	holdout := 0
+	for u := 0; u < 5000; u++ {
+		rate := gamma(rng, r) / alpha
+		pa := gamma(rng, a)
+		p := pa / (pa + gamma(rng, b))
+		first := rng.Float64() * reference
+		c := Customer{User: uint32(u), First: int32(first), Last: int32(first), Orders: 1, Revenue: 10}
+		for at := first; ; {
+			at += rng.ExpFloat64() / rate
+			if at >= reference+horizon {
+				break
+			}
+			if at < reference {
+				if int32(at) != c.Last {
+					c.Orders++
+					c.Revenue += 10
+				}
+				c.Last = int32(at)
+			} else {
+				holdout++
+			}
+			if rng.Float64() < p {
+				break
+			}
+		}
+		customers = append(customers, c)
+	}
+
+	m, err := Fit(customers, reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected float64
+	for i := range customers {
+		expected += m.ExpectedOrders(&customers[i], horizon)
+		if alive := m.Alive(&customers[i]); alive <= 0 || alive > 1 {
+			t.Fatalf("Alive out of range: %v", alive)
+		}
+	}
+	if math.Abs(expected-float64(holdout)) > 0.1*float64(holdout) {
+		t.Errorf("Expected about %d holdout orders, the model (%+v) predicts %.0f", holdout, m, expected)
+	}
+	if got := m.Projected(&customers[0], horizon); got != 10*m.ExpectedOrders(&customers[0], horizon) {
+		t.Errorf("Projected %v, want the expected orders at 10 each", got)
+	}
+}
+
+func TestFitNeedsRepeatOrders(t *testing.T) {
+	customers := make([]Customer, MinCustomers)
+	for i := range customers {
+		customers[i] = Customer{Orders: 1, Revenue: 1}
+	}
+	if _, err := Fit(customers, 30); err == nil {
+		t.Error("Expected an error without repeat orders")
+	}
+}
+
+func TestExpectedOrdersAroundAOfOne(t *testing.T) {
+	c := &Customer{First: 0, Last: 60, Orders: 4, Revenue: 40}
+	at := func(a float64) float64 {
+		m := Model{R: 0.8, Alpha: 20, A: a, B: 2, Reference: 100}
+		return m.ExpectedOrders(c, 365)
+	}
+	below, above := at(0.99), at(1.01)
+	for _, a := range []float64{1, 1 + 1e-9, 1 - 1e-7} {
+		got := at(a)
+		if math.IsNaN(got) || math.IsInf(got, 0) || got < min(below, above) || got > max(below, above) {
+			t.Errorf("ExpectedOrders at a=%v is %v, want between %v and %v", a, got, below, above)
+		}
+	}
+}
+
+func TestCacheFitsOncePerDatasetAndDay(t *testing.T) {
+	tbl := columnar.FromTransactions(nil)
+	var c Cache
+	if c.Get("a", tbl, 10) != c.Get("a", tbl, 10) {
+		t.Error("Expected one fit per reference day")
+	}
+	first := c.Get("a", tbl, 10)
+	if c.Get("a", tbl, 11) == first || c.Get("b", tbl, 10) == first {
+		t.Error("Expected separate fits for other days and datasets")
+	}
+	for day := int32(0); day <= maxCachedFits; day++ {
+		c.Get("b", tbl, day)
+	}
+	if len(c.fits) != maxCachedFits {
+		t.Errorf("Expected at most %d cached fits, got %d", maxCachedFits, len(c.fits))
+	}
+	if first.Err == nil {
+		t.Error("Expected an error fitting no customers")
+	}
+}
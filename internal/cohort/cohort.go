@@ -0,0 +1,155 @@
+// Package cohort groups users by the period of their first purchase and
+// follows how many of them buy again, and for how much, in each period
+// after it.
+package cohort
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+)
+
+// MaxPeriods bounds the periods between the first cohort and the end of
+// the data, e.g. about 19 years of weeks.
+const MaxPeriods = 1000
+
+// Cohort is the users whose first purchase fell in one period.
+type Cohort struct {
+	Start int32 // first day of the period, as a columnar day number
+	Users int
+	// Active and Revenue are indexed by periods since Start: Active[0] is
+	// Users, Active[2] how many of them bought two periods later. They run
+	// up to the last period of the data.
+	Active  []int
+	Revenue []float64
+}
+
+// Retention is the share of the cohort active k periods after its first.
+func (c *Cohort) Retention(k int) float64 {
+	return float64(c.Active[k]) / float64(c.Users)
+}
+
+// Options select the cohorts.
+type Options struct {
+	Bucket query.Bucket // Month when empty
+	// From and To bound the first purchases that form cohorts. Users who
+	// bought before From belong to no cohort, as they weren't new in the
+	// range. The cohorts' periods stop at To, by default the last day of
+	// t, so periods without purchases after a filtered series ends are zero
+	// rather than missing.
+	From, To *int32
+}
+
+// Build groups the users of the given rows of t, all of them when rows is
+// nil, and returns the cohorts that have users, oldest first.
+func Build(t *columnar.Table, rows []int32, opts Options) ([]Cohort, error) {
+	b := opts.Bucket
+	if b == "" {
+		b = query.Month
+	}
+	var end int32
+	if opts.To != nil {
+		end = *opts.To
+	} else if t.Len() > 0 {
+		end = slices.Max(t.Date)
+	}
+	forEach := func(fn func(i int32)) {
+		if rows == nil {
+			for i := 0; i < t.Len(); i++ {
+				fn(int32(i))
+			}
+			return
+		}
+		for _, i := range rows {
+			fn(i)
+		}
+	}
+
+	// the first purchase of each user, and the rows of each user
+	users := t.UserID.Cardinality()
+	first := make([]int32, users)
+	for u := range first {
+		first[u] = math.MaxInt32
+	}
+	offsets := make([]int32, users+1)
+	n := 0
+	forEach(func(i int32) {
+		if d := t.Date[i]; d <= end {
+			u := t.UserID.Codes[i]
+			first[u] = min(first[u], d)
+			offsets[u+1]++
+			n++
+		}
+	})
+	if n == 0 {
+		return nil, nil
+	}
+	for u := 0; u < users; u++ {
+		offsets[u+1] += offsets[u]
+	}
+	byUser := make([]int32, n)
+	next := append([]int32(nil), offsets[:users]...)
+	forEach(func(i int32) {
+		if t.Date[i] <= end {
+			u := t.UserID.Codes[i]
+			byUser[next[u]] = i
+			next[u]++
+		}
+	})
+
+	// cohorts are numbered from the earliest first purchase
+	lo := int32(math.MaxInt32)
+	for _, d := range first {
+		if d != math.MaxInt32 && (opts.From == nil || d >= *opts.From) {
+			lo = min(lo, d)
+		}
+	}
+	if lo == math.MaxInt32 {
+		return nil, nil
+	}
+	base, last := b.Ordinal(lo), b.Ordinal(end)
+	if count := last - base + 1; count > MaxPeriods {
+		return nil, fmt.Errorf("%d %s periods requested, at most %d are supported: use a coarser granularity or a shorter date range", count, b, MaxPeriods)
+	}
+	cohorts := make([]Cohort, last-base+1)
+	for c := range cohorts {
+		cohorts[c].Active = make([]int, int(last-base)-c+1)
+		cohorts[c].Revenue = make([]float64, int(last-base)-c+1)
+	}
+	for d := b.Start(lo); d <= end; d = b.Next(d) {
+		cohorts[b.Ordinal(d)-base].Start = d
+	}
+
+	// seen[k] is the last user counted as active k periods after their first
+	seen := make([]int32, len(cohorts))
+	for k := range seen {
+		seen[k] = -1
+	}
+	for u := int32(0); u < int32(users); u++ {
+		if first[u] == math.MaxInt32 || opts.From != nil && first[u] < *opts.From {
+			continue
+		}
+		own := b.Ordinal(first[u])
+		c := &cohorts[own-base]
+		c.Users++
+		for _, i := range byUser[offsets[u]:offsets[u+1]] {
+			k := b.Ordinal(t.Date[i]) - own
+			c.Revenue[k] += t.TotalPrice[i]
+			if seen[k] != u {
+				seen[k] = u
+				c.Active[k]++
+			}
+		}
+	}
+
+	out := cohorts[:0]
+	for _, c := range cohorts {
+		if c.Users > 0 {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package cohort
+
+import (
+	"testing"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/query"
+)
+
+func tx(user, date string, total float64) domain.Transaction {
+	d, _ := domain.ParseDate(date)
+	return domain.Transaction{UserID: user, Date: d, TotalPrice: total}
+}
+
+func day(s string) *int32 {
+	d, _ := domain.ParseDate(s)
+	n := columnar.DayOf(d)
+	return &n
+}
+
+func testTable() *columnar.Table {
+	return columnar.FromTransactions([]domain.Transaction{
+		tx("b", "2024-02-10", 5),
+		tx("a", "2024-01-05", 10),
+		tx("a", "2024-01-20", 10),
+		tx("a", "2024-03-01", 30),
+		tx("b", "2024-01-31", 20),
+		tx("c", "2024-02-02", 7),
+		tx("c", "2024-04-30", 1),
+	})
+}
+
+func TestBuild(t *testing.T) {
+	cohorts, err := Build(testTable(), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cohorts) != 2 {
+		t.Fatalf("got %d cohorts, want January and February", len(cohorts))
+	}
+	jan := cohorts[0]
+	if query.Month.Format(jan.Start) != "2024-01" || jan.Users != 2 || len(jan.Active) != 4 {
+		t.Fatalf("January = %+v", jan)
+	}
+	// a buys again in March, b in February
+	if want := []int{2, 1, 1, 0}; jan.Active[0] != want[0] || jan.Active[1] != want[1] || jan.Active[2] != want[2] || jan.Active[3] != want[3] {
+		t.Errorf("January active = %v, want %v", jan.Active, want)
+	}
+	if jan.Revenue[0] != 40 || jan.Revenue[2] != 30 || jan.Retention(1) != 0.5 {
+		t.Errorf("January = %+v", jan)
+	}
+	if feb := cohorts[1]; feb.Users != 1 || len(feb.Active) != 3 || feb.Active[2] != 1 {
+		t.Errorf("February = %+v", feb)
+	}
+}
+
+func TestBuildRange(t *testing.T) {
+	// a and b first bought before February, so only c is new
+	cohorts, err := Build(testTable(), nil, Options{From: day("2024-02-01"), To: day("2024-03-31")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cohorts) != 1 || cohorts[0].Users != 1 || len(cohorts[0].Active) != 2 || cohorts[0].Active[1] != 0 {
+		t.Errorf("cohorts = %+v", cohorts)
+	}
+
+	// only b's rows: still measured up to the end of the data
+	cohorts, _ = Build(testTable(), []int32{0, 4}, Options{Bucket: query.Quarter})
+	if len(cohorts) != 1 || len(cohorts[0].Active) != 2 || cohorts[0].Revenue[0] != 25 {
+		t.Errorf("cohorts = %+v", cohorts)
+	}
+
+	if _, err := Build(testTable(), nil, Options{Bucket: query.Day, To: day("2030-01-01")}); err == nil {
+		t.Error("more than MaxPeriods periods should fail")
+	}
+}
@@ -0,0 +1,64 @@
+package columnar
+
+import (
+	"fmt"
+	"time"
+)
+
+const secondsPerDay = 86400
+
+// DayOf returns the number of days between the Unix epoch and t's UTC date.
+func DayOf(t time.Time) int32 {
+	s := t.Unix()
+	d := s / secondsPerDay
+	if s%secondsPerDay < 0 {
+		d--
+	}
+	return int32(d)
+}
+
+// DayTime returns midnight UTC of day d.
+func DayTime(d int32) time.Time {
+	return time.Unix(int64(d)*secondsPerDay, 0).UTC()
+}
+
+// Civil converts a day number to its calendar date without going through
+// time.Time, which keeps per-row date bucketing cheap.
+// See http://howardhinnant.github.io/date_algorithms.html#civil_from_days.
+func Civil(d int32) (year int, month time.Month, day int) {
+	z := int64(d) + 719468
+	era := z / 146097
+	if z < 0 && z%146097 != 0 {
+		era--
+	}
+	doe := z - era*146097
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365
+	doy := doe - (365*yoe + yoe/4 - yoe/100)
+	mp := (5*doy + 2) / 153
+	day = int(doy - (153*mp+2)/5 + 1)
+	m := mp + 3
+	if m > 12 {
+		m -= 12
+	}
+	year = int(yoe + era*400)
+	if m <= 2 {
+		year++
+	}
+	return year, time.Month(m), day
+}
+
+// MonthIndex numbers months continuously (year*12 + month-1) so consecutive
+// months differ by one.
+func MonthIndex(d int32) int32 {
+	y, m, _ := Civil(d)
+	return int32(y*12 + int(m) - 1)
+}
+
+// FormatMonth renders a MonthIndex as "YYYY-MM".
+func FormatMonth(m int32) string {
+	y, mm := m/12, m%12
+	if mm < 0 {
+		y, mm = y-1, mm+12
+	}
+	return fmt.Sprintf("%04d-%02d", y, mm+1)
+}
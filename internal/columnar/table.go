@@ -0,0 +1,147 @@
+// Package columnar stores transactions column by column. Low-cardinality
+// strings are dictionary encoded, numbers are kept in packed slices and
+// dates as day numbers, so aggregations only touch the columns they read.
+package columnar
+
+import (
+	"Dashlytics/internal/domain"
+)
+
+// Dict is a dictionary-encoded string column: row i holds Values[Codes[i]].
+type Dict struct {
+	Values []string
+	Codes  []uint32
+	lookup map[string]uint32
+}
+
+func newDict(capacity int) *Dict {
+	return &Dict{Codes: make([]uint32, 0, capacity), lookup: make(map[string]uint32)}
+}
+
+// NewDict rebuilds a Dict from its parts, as stored in a snapshot.
+func NewDict(values []string, codes []uint32) *Dict {
+	d := &Dict{Values: values, Codes: codes, lookup: make(map[string]uint32, len(values))}
+	for i, v := range values {
+		d.lookup[v] = uint32(i)
+	}
+	return d
+}
+
+func (d *Dict) append(v string) {
+	code, ok := d.lookup[v]
+	if !ok {
+		code = uint32(len(d.Values))
+		d.lookup[v] = code
+		d.Values = append(d.Values, v)
+	}
+	d.Codes = append(d.Codes, code)
+}
+
+// At returns the value of row i.
+func (d *Dict) At(i int) string {
+	return d.Values[d.Codes[i]]
+}
+
+// Code returns the dictionary code of v.
+func (d *Dict) Code(v string) (uint32, bool) {
+	code, ok := d.lookup[v]
+	return code, ok
+}
+
+// Cardinality is the number of distinct values.
+func (d *Dict) Cardinality() int {
+	return len(d.Values)
+}
+
+// Table holds the domain.Transaction schema in columns of equal length.
+type Table struct {
+	ID          []string
+	Date        []int32 // days since the Unix epoch
+	UserID      *Dict
+	Country     *Dict
+	Region      *Dict
+	ProductID   *Dict
+	ProductName *Dict
+	Category    *Dict
+	Price       []float64
+	Quantity    []int32
+	TotalPrice  []float64
+	Stock       []int32
+	AddedDate   []int32 // days since the Unix epoch
+}
+
+// New returns an empty Table with room for capacity rows.
+func New(capacity int) *Table {
+	return &Table{
+		ID:          make([]string, 0, capacity),
+		Date:        make([]int32, 0, capacity),
+		UserID:      newDict(capacity),
+		Country:     newDict(capacity),
+		Region:      newDict(capacity),
+		ProductID:   newDict(capacity),
+		ProductName: newDict(capacity),
+		Category:    newDict(capacity),
+		Price:       make([]float64, 0, capacity),
+		Quantity:    make([]int32, 0, capacity),
+		TotalPrice:  make([]float64, 0, capacity),
+		Stock:       make([]int32, 0, capacity),
+		AddedDate:   make([]int32, 0, capacity),
+	}
+}
+
+// FromTransactions converts rows into a Table.
+func FromTransactions(txs []domain.Transaction) *Table {
+	t := New(len(txs))
+	for i := range txs {
+		t.Append(&txs[i])
+	}
+	return t
+}
+
+// Append adds tx as the last row.
+func (t *Table) Append(tx *domain.Transaction) {
+	t.ID = append(t.ID, tx.ID)
+	t.Date = append(t.Date, DayOf(tx.Date))
+	t.UserID.append(tx.UserID)
+	t.Country.append(tx.Country)
+	t.Region.append(tx.Region)
+	t.ProductID.append(tx.ProductID)
+	t.ProductName.append(tx.ProductName)
+	t.Category.append(tx.Category)
+	t.Price = append(t.Price, tx.Price)
+	t.Quantity = append(t.Quantity, int32(tx.Quantity))
+	t.TotalPrice = append(t.TotalPrice, tx.TotalPrice)
+	t.Stock = append(t.Stock, int32(tx.Stock))
+	t.AddedDate = append(t.AddedDate, DayOf(tx.AddedDate))
+}
+
+// Len is the number of rows.
+func (t *Table) Len() int {
+	return len(t.ID)
+}
+
+// Row materializes row i.
+func (t *Table) Row(i int) domain.Transaction {
+	var tx domain.Transaction
+	t.Fill(i, &tx)
+	return tx
+}
+
+// Fill materializes row i into tx, avoiding an allocation when scanning.
+func (t *Table) Fill(i int, tx *domain.Transaction) {
+	*tx = domain.Transaction{
+		ID:          t.ID[i],
+		Date:        DayTime(t.Date[i]),
+		UserID:      t.UserID.At(i),
+		Country:     t.Country.At(i),
+		Region:      t.Region.At(i),
+		ProductID:   t.ProductID.At(i),
+		ProductName: t.ProductName.At(i),
+		Category:    t.Category.At(i),
+		Price:       t.Price[i],
+		Quantity:    int(t.Quantity[i]),
+		TotalPrice:  t.TotalPrice[i],
+		Stock:       int(t.Stock[i]),
+		AddedDate:   DayTime(t.AddedDate[i]),
+	}
+}
@@ -0,0 +1,117 @@
+package columnar
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/domain"
+)
+
+func TestTableRoundTrip(t *testing.T) {
+	date := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	txs := []domain.Transaction{
+		{ID: "TX1", Date: date, UserID: "U1", Country: "USA", Region: "Texas", ProductID: "P1", ProductName: "Widget", Category: "Tools", Price: 2.5, Quantity: 4, TotalPrice: 10, Stock: 7, AddedDate: date.AddDate(-2, 0, 0)},
+		{ID: "TX2", Date: date.AddDate(0, 0, 1), UserID: "U2", Country: "USA", Region: "Ohio", ProductID: "P1", ProductName: "Widget", Category: "Tools", Price: 2.5, Quantity: 1, TotalPrice: 2.5, Stock: 6},
+	}
+	table := FromTransactions(txs)
+
+	if table.Country.Cardinality() != 1 || table.Region.Cardinality() != 2 {
+		t.Errorf("unexpected dictionaries: %v, %v", table.Country.Values, table.Region.Values)
+	}
+	for i, want := range txs {
+		if got := table.Row(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestCivilMatchesTime(t *testing.T) {
+	for d := int32(-800000); d < 800000; d += 37 {
+		want := DayTime(d)
+		y, m, day := Civil(d)
+		if y != want.Year() || m != want.Month() || day != want.Day() {
+			t.Fatalf("Civil(%d) = %d-%d-%d, want %s", d, y, m, day, want.Format("2006-01-02"))
+		}
+		if DayOf(want) != d {
+			t.Fatalf("DayOf(%s) = %d, want %d", want, DayOf(want), d)
+		}
+	}
+	if got := FormatMonth(MonthIndex(DayOf(time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)))); got != "2023-12" {
+		t.Errorf("expected 2023-12, got %s", got)
+	}
+}
+
+func benchmarkTransactions(n int) []domain.Transaction {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	txs := make([]domain.Transaction, n)
+	for i := range txs {
+		txs[i] = domain.Transaction{
+			ID:          fmt.Sprintf("TX%d", i),
+			Date:        start.AddDate(0, 0, rng.Intn(1500)),
+			UserID:      fmt.Sprintf("U%d", rng.Intn(50000)),
+			Country:     fmt.Sprintf("Country%d", rng.Intn(20)),
+			Region:      fmt.Sprintf("Region%d", rng.Intn(60)),
+			ProductID:   fmt.Sprintf("P%d", rng.Intn(500)),
+			ProductName: fmt.Sprintf("Product %d", rng.Intn(500)),
+			Category:    fmt.Sprintf("Category%d", rng.Intn(12)),
+			Price:       rng.Float64() * 100,
+			Quantity:    1 + rng.Intn(9),
+			TotalPrice:  rng.Float64() * 900,
+			Stock:       rng.Intn(1000),
+		}
+	}
+	return txs
+}
+
+const benchRows = 500000
+
+// The Rows benchmarks are the []domain.Transaction scans the handlers used
+// before the columnar store, the Columns ones what they do now.
+
+func BenchmarkRegionRevenueRows(b *testing.B) {
+	txs := benchmarkTransactions(benchRows)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		revenue := make(map[string]float64)
+		for i := range txs {
+			revenue[txs[i].Region] += txs[i].TotalPrice
+		}
+	}
+}
+
+func BenchmarkRegionRevenueColumns(b *testing.B) {
+	table := FromTransactions(benchmarkTransactions(benchRows))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		revenue := make([]float64, table.Region.Cardinality())
+		for i, code := range table.Region.Codes {
+			revenue[code] += table.TotalPrice[i]
+		}
+	}
+}
+
+func BenchmarkMonthlySalesRows(b *testing.B) {
+	txs := benchmarkTransactions(benchRows)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sales := make(map[string]int)
+		for i := range txs {
+			sales[txs[i].Date.Format("2006-01")] += txs[i].Quantity
+		}
+	}
+}
+
+func BenchmarkMonthlySalesColumns(b *testing.B) {
+	table := FromTransactions(benchmarkTransactions(benchRows))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sales := make(map[int32]int)
+		for i, day := range table.Date {
+			sales[MonthIndex(day)] += int(table.Quantity[i])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package config holds the command-line flags shared by the Dashlytics binaries.
+package config
+
+import (
+	"flag"
+
+	"Dashlytics/internal/repository"
+)
+
+// SourceFlags registers the dataset flags on fs. The returned function
+// builds the repository.Source once fs has been parsed.
+func SourceFlags(fs *flag.FlagSet) func() (repository.Source, error) {
+	csvPath := fs.String("data", "data/GO_test_5m.csv", "path to the transactions CSV file")
+	snapshotPath := fs.String("snapshot", "", "binary snapshot of the dataset (default: the data path with a .snapshot extension, \"off\" to disable)")
+	columnsPath := fs.String("columns", "", "optional JSON file mapping CSV header names to Transaction fields")
+	lenient := fs.Bool("lenient", false, "skip rows that fail to parse instead of failing")
+	quarantinePath := fs.String("quarantine", "", "in lenient mode, write skipped rows and the reason to this CSV file")
+	workers := fs.Int("workers", 0, "number of CSV chunks parsed in parallel (default: number of CPUs)")
+
+	return func() (repository.Source, error) {
+		src := repository.Source{
+			CSVPath:      *csvPath,
+			SnapshotPath: *snapshotPath,
+			Options:      repository.LoadOptions{QuarantinePath: *quarantinePath, Workers: *workers},
+		}
+		switch src.SnapshotPath {
+		case "":
+			src.SnapshotPath = repository.SnapshotPath(src.CSVPath)
+		case "off":
+			src.SnapshotPath = ""
+		}
+		if *lenient {
+			src.Options.Mode = repository.Lenient
+		}
+		if *columnsPath != "" {
+			mapping, err := repository.LoadColumnMapping(*columnsPath)
+			if err != nil {
+				return src, err
+			}
+			src.Options.Mapping = mapping
+		}
+		return src, nil
+	}
+}
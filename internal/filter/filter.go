@@ -0,0 +1,296 @@
+// Package filter parses the filter query parameters shared by the analytics
+// endpoints and selects the matching rows, starting from the dimension
+// indexes whenever a filter on an indexed column is present.
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/repository"
+)
+
+// Filter restricts the transactions an endpoint aggregates. Every set field
+// must match; a dimension given several values matches any of them.
+type Filter struct {
+	Countries  []string
+	Regions    []string
+	Categories []string
+	ProductIDs []string
+	UserIDs    []string
+	// DateFrom and DateTo bound the transaction date, inclusive, as columnar day numbers.
+	DateFrom, DateTo *int32
+	// MinPrice and MaxPrice bound the unit price, inclusive.
+	MinPrice, MaxPrice *float64
+}
+
+// Params lists the query parameters Parse understands.
+var Params = []string{"country", "region", "category", "product_id", "user_id", "date_from", "date_to", "min_price", "max_price"}
+
+// Parse reads the filter from query parameters. Dimension parameters accept
+// comma-separated values or may be repeated, dates use YYYY-MM-DD.
+func Parse(q url.Values) (Filter, error) {
+	f := Filter{
+		Countries:  listParam(q, "country"),
+		Regions:    listParam(q, "region"),
+		Categories: listParam(q, "category"),
+		ProductIDs: listParam(q, "product_id"),
+		UserIDs:    listParam(q, "user_id"),
+	}
+	var err error
+	if f.DateFrom, err = dateParam(q, "date_from"); err != nil {
+		return f, err
+	}
+	if f.DateTo, err = dateParam(q, "date_to"); err != nil {
+		return f, err
+	}
+	if f.MinPrice, err = floatParam(q, "min_price"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = floatParam(q, "max_price"); err != nil {
+		return f, err
+	}
+	if f.DateFrom != nil && f.DateTo != nil && *f.DateFrom > *f.DateTo {
+		return f, fmt.Errorf("date_from must not be after date_to")
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return f, fmt.Errorf("min_price must not be greater than max_price")
+	}
+	return f, nil
+}
+
+// ParseMap reads the filter from a decoded JSON object keyed by the query
+// parameter names. Values may be strings, numbers or lists of them.
+func ParseMap(m map[string]any) (Filter, error) {
+	q := url.Values{}
+	for name, v := range m {
+		if !slices.Contains(Params, name) {
+			return Filter{}, fmt.Errorf("unknown filter %q (want one of %s)", name, strings.Join(Params, ", "))
+		}
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				q.Add(name, v)
+			case float64:
+				q.Add(name, strconv.FormatFloat(v, 'f', -1, 64))
+			case nil:
+			default:
+				return Filter{}, fmt.Errorf("invalid %s: want a string, a number or a list of them", name)
+			}
+		}
+	}
+	return Parse(q)
+}
+
+func listParam(q url.Values, name string) []string {
+	var values []string
+	for _, v := range q[name] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+func dateParam(q url.Values, name string) (*int32, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := domain.ParseDate(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: want YYYY-MM-DD", name, v)
+	}
+	day := columnar.DayOf(t)
+	return &day, nil
+}
+
+func floatParam(q url.Values, name string) (*float64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: want a number", name, v)
+	}
+	return &f, nil
+}
+
+// IsEmpty reports whether the filter matches every transaction.
+func (f Filter) IsEmpty() bool {
+	return len(f.dimensions()) == 0 && f.DateFrom == nil && f.DateTo == nil && f.MinPrice == nil && f.MaxPrice == nil
+}
+
+type dimension struct {
+	column repository.Column
+	values []string
+}
+
+func (f Filter) dimensions() []dimension {
+	var dims []dimension
+	for _, d := range []dimension{
+		{repository.ColCountry, f.Countries},
+		{repository.ColRegion, f.Regions},
+		{repository.ColCategory, f.Categories},
+		{repository.ColProductID, f.ProductIDs},
+		{repository.ColUserID, f.UserIDs},
+	} {
+		if len(d.values) > 0 {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
+
+// Select returns the ascending rows of repo matching the filter. It is
+// never nil, so it can be told apart from the "all rows" nil that the
+// aggregate functions accept. Dimension filters are resolved through the
+// indexes, smallest posting list first, and only the candidate rows are
+// checked against the date and price bounds.
+func (f Filter) Select(repo repository.Repository) []int32 {
+	t := repo.Columns()
+	var candidates []int32
+	indexed := false
+	for _, d := range f.sortedDimensions(repo) {
+		rows := postings(repo, d)
+		if !indexed {
+			candidates, indexed = rows, true
+		} else {
+			candidates = intersect(candidates, rows)
+		}
+		if len(candidates) == 0 {
+			return []int32{}
+		}
+	}
+
+	selected := []int32{}
+	match := f.rowMatcher(t)
+	if indexed {
+		for _, row := range candidates {
+			if match(row) {
+				selected = append(selected, row)
+			}
+		}
+		return selected
+	}
+	for row := int32(0); int(row) < t.Len(); row++ {
+		if match(row) {
+			selected = append(selected, row)
+		}
+	}
+	return selected
+}
+
+// sortedDimensions orders the dimension filters by their number of
+// matching rows, so intersections start from the most selective one.
+func (f Filter) sortedDimensions(repo repository.Repository) []dimension {
+	dims := f.dimensions()
+	sizes := make([]int, len(dims))
+	for i, d := range dims {
+		for _, v := range d.values {
+			sizes[i] += len(repo.ByDimension(d.column, v))
+		}
+	}
+	sort.Sort(bySize{dims, sizes})
+	return dims
+}
+
+type bySize struct {
+	dims  []dimension
+	sizes []int
+}
+
+func (s bySize) Len() int           { return len(s.dims) }
+func (s bySize) Less(i, j int) bool { return s.sizes[i] < s.sizes[j] }
+func (s bySize) Swap(i, j int) {
+	s.dims[i], s.dims[j] = s.dims[j], s.dims[i]
+	s.sizes[i], s.sizes[j] = s.sizes[j], s.sizes[i]
+}
+
+// postings returns the rows matching any value of d.
+func postings(repo repository.Repository, d dimension) []int32 {
+	var rows []int32
+	for _, v := range d.values {
+		rows = union(rows, repo.ByDimension(d.column, v))
+	}
+	return rows
+}
+
+// rowMatcher checks the bounds that have no index.
+func (f Filter) rowMatcher(t *columnar.Table) func(row int32) bool {
+	return func(row int32) bool {
+		if f.DateFrom != nil && t.Date[row] < *f.DateFrom {
+			return false
+		}
+		if f.DateTo != nil && t.Date[row] > *f.DateTo {
+			return false
+		}
+		if f.MinPrice != nil && t.Price[row] < *f.MinPrice {
+			return false
+		}
+		if f.MaxPrice != nil && t.Price[row] > *f.MaxPrice {
+			return false
+		}
+		return true
+	}
+}
+
+// intersect merges two ascending row lists.
+func intersect(a, b []int32) []int32 {
+	out := make([]int32, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// union merges two ascending row lists. Posting lists of one column never
+// overlap, but the merge doesn't rely on it.
+func union(a, b []int32) []int32 {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	out := make([]int32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/repository"
+)
+
+func day(s string) time.Time {
+	t, _ := domain.ParseDate(s)
+	return t
+}
+
+func filterStore() *repository.DataStore {
+	return repository.NewDataStore([]domain.Transaction{
+		{ID: "1", Date: day("2024-01-05"), Country: "USA", Region: "Texas", Category: "Toys", Price: 10},
+		{ID: "2", Date: day("2024-02-05"), Country: "Canada", Region: "Ontario", Category: "Toys", Price: 20},
+		{ID: "3", Date: day("2024-03-05"), Country: "USA", Region: "Ohio", Category: "Books", Price: 30},
+		{ID: "4", Date: day("2024-04-05"), Country: "USA", Region: "Texas", Category: "Toys", Price: 40},
+		{ID: "5", Date: day("2024-05-05"), Country: "Mexico", Region: "Jalisco", Category: "Books", Price: 50},
+	})
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(url.Values{
+		"country":   {"USA, Canada"},
+		"region":    {"Texas", "Ohio"},
+		"date_from": {"2024-01-01"},
+		"max_price": {"35.5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Countries, []string{"USA", "Canada"}) || !reflect.DeepEqual(f.Regions, []string{"Texas", "Ohio"}) {
+		t.Errorf("unexpected dimensions: %+v", f)
+	}
+	if f.DateFrom == nil || f.DateTo != nil || f.MaxPrice == nil || *f.MaxPrice != 35.5 {
+		t.Errorf("unexpected bounds: %+v", f)
+	}
+
+	for _, q := range []url.Values{
+		{"date_from": {"01/02/2024"}},
+		{"min_price": {"cheap"}},
+		{"date_from": {"2024-02-01"}, "date_to": {"2024-01-01"}},
+		{"min_price": {"5"}, "max_price": {"1"}},
+	} {
+		if _, err := Parse(q); err == nil {
+			t.Errorf("Parse(%v) should fail", q)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	ds := filterStore()
+	cases := []struct {
+		query string
+		want  []int32
+	}{
+		{"", []int32{0, 1, 2, 3, 4}},
+		{"country=USA", []int32{0, 2, 3}},
+		{"country=USA,Mexico&category=Books", []int32{2, 4}},
+		{"country=USA&region=Texas&date_from=2024-02-01", []int32{3}},
+		{"min_price=20&max_price=40", []int32{1, 2, 3}},
+		{"country=Canada&category=Books", []int32{}},
+		{"country=Atlantis", []int32{}},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		f, err := Parse(q)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if got := f.Select(ds); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got rows %v, want %v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := []int32{1, 3, 5, 7}, []int32{2, 3, 7, 9}
+	if got := intersect(a, b); !reflect.DeepEqual(got, []int32{3, 7}) {
+		t.Errorf("intersect = %v", got)
+	}
+	if got := union(a, b); !reflect.DeepEqual(got, []int32{1, 2, 3, 5, 7, 9}) {
+		t.Errorf("union = %v", got)
+	}
+}
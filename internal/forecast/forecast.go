@@ -0,0 +1,248 @@
+// Package forecast projects evenly spaced series, such as monthly revenue,
+// with additive Holt-Winters smoothing or a seasonal naive baseline.
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Method is a forecasting method.
+type Method string
+
+const (
+	// HoltWinters is additive triple exponential smoothing of level, trend
+	// and season, its parameters chosen by grid search on one-step errors.
+	HoltWinters Method = "holt_winters"
+	// SeasonalNaive repeats the last season.
+	SeasonalNaive Method = "seasonal_naive"
+)
+
+// Methods lists every Method.
+var Methods = []Method{HoltWinters, SeasonalNaive}
+
+// ParseMethod validates a method name.
+func ParseMethod(s string) (Method, error) {
+	for _, m := range Methods {
+		if strings.EqualFold(s, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown forecasting method %q (want holt_winters or seasonal_naive)", s)
+}
+
+// Options configure a forecast.
+type Options struct {
+	Method  Method // HoltWinters when empty
+	Horizon int    // periods to project
+	Season  int    // periods per season, e.g. 12 for months
+	// Level is the coverage of the prediction intervals, 0.95 when zero.
+	Level float64
+	// Holdout is how many trailing periods the backtest projects from the
+	// rest, min(Horizon, Season) when zero.
+	Holdout int
+	// NonNegative clips forecasts and interval bounds at zero.
+	NonNegative bool
+}
+
+// Prediction is the forecast of one period.
+type Prediction struct {
+	Value, Lower, Upper float64
+}
+
+// Accuracy is the error of a backtest.
+type Accuracy struct {
+	Periods int
+	MAPE    *float64 // mean absolute percentage error; nil when every actual value is zero
+	RMSE    float64  // root mean squared error
+}
+
+// Forecast is the projection of a series.
+type Forecast struct {
+	Method      Method
+	Predictions []Prediction
+	// Backtest is nil when the series is too short to fit without its holdout.
+	Backtest *Accuracy
+	// smoothing parameters, for HoltWinters
+	Alpha, Beta, Gamma float64
+}
+
+// MinLength is the shortest series m can project with seasons of season periods.
+func MinLength(m Method, season int) int {
+	if m == SeasonalNaive {
+		return season
+	}
+	return 2 * season
+}
+
+// Project forecasts the opts.Horizon periods after values.
+func Project(values []float64, opts Options) (*Forecast, error) {
+	if opts.Method == "" {
+		opts.Method = HoltWinters
+	}
+	if opts.Level == 0 {
+		opts.Level = 0.95
+	}
+	if opts.Holdout == 0 {
+		opts.Holdout = min(opts.Horizon, opts.Season)
+	}
+	switch {
+	case opts.Horizon < 1:
+		return nil, fmt.Errorf("horizon must be at least 1")
+	case opts.Season < 2:
+		return nil, fmt.Errorf("season must be at least 2 periods")
+	case opts.Level <= 0 || opts.Level >= 1:
+		return nil, fmt.Errorf("interval level must be between 0 and 1")
+	}
+	if n := MinLength(opts.Method, opts.Season); len(values) < n {
+		return nil, fmt.Errorf("%s needs at least %d periods of history, got %d", opts.Method, n, len(values))
+	}
+
+	f := fit(values, opts)
+	if n := len(values) - opts.Holdout; opts.Holdout > 0 && n >= MinLength(opts.Method, opts.Season) {
+		back := opts
+		back.Horizon = opts.Holdout
+		f.Backtest = accuracy(values[n:], fit(values[:n], back).Predictions)
+	}
+	return f, nil
+}
+
+func fit(values []float64, opts Options) *Forecast {
+	var f *Forecast
+	if opts.Method == SeasonalNaive {
+		f = seasonalNaive(values, opts)
+	} else {
+		f = holtWinters(values, opts)
+	}
+	if opts.NonNegative {
+		for i := range f.Predictions {
+			p := &f.Predictions[i]
+			p.Value, p.Lower, p.Upper = max(p.Value, 0), max(p.Lower, 0), max(p.Upper, 0)
+		}
+	}
+	return f
+}
+
+// z is the standard normal quantile of a two-sided interval of this level.
+func z(level float64) float64 {
+	return math.Sqrt2 * math.Erfinv(level)
+}
+
+func seasonalNaive(values []float64, opts Options) *Forecast {
+	m, n := opts.Season, len(values)
+	sse, count := 0.0, 0
+	for t := m; t < n; t++ {
+		e := values[t] - values[t-m]
+		sse += e * e
+		count++
+	}
+	sigma := 0.0
+	if count > 0 {
+		sigma = math.Sqrt(sse / float64(count))
+	}
+
+	f := &Forecast{Method: SeasonalNaive, Predictions: make([]Prediction, opts.Horizon)}
+	for h := 1; h <= opts.Horizon; h++ {
+		seasons := (h-1)/m + 1
+		v := values[n-m+(h-1)%m]
+		width := z(opts.Level) * sigma * math.Sqrt(float64(seasons))
+		f.Predictions[h-1] = Prediction{Value: v, Lower: v - width, Upper: v + width}
+	}
+	return f
+}
+
+// grid are the smoothing parameters tried for each of alpha, beta and gamma.
+var grid = []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+// hwState is the level, trend and seasonal components after smoothing.
+type hwState struct {
+	level, trend float64
+	season       []float64
+	sse          float64 // of the one-step forecasts
+	count        int
+}
+
+func smooth(values []float64, m int, alpha, beta, gamma float64) hwState {
+	// start from the first two seasons
+	first, second := 0.0, 0.0
+	for i := 0; i < m; i++ {
+		first += values[i]
+		second += values[m+i]
+	}
+	first /= float64(m)
+	second /= float64(m)
+	// the first season's mean is its level halfway through
+	trend := (second - first) / float64(m)
+	mid := float64(m-1) / 2
+	s := hwState{level: first + mid*trend, trend: trend, season: make([]float64, m)}
+	for i := 0; i < m; i++ {
+		s.season[i] = values[i] - (first + (float64(i)-mid)*trend)
+	}
+
+	for t := m; t < len(values); t++ {
+		y, slot := values[t], t%m
+		e := y - (s.level + s.trend + s.season[slot])
+		s.sse += e * e
+		s.count++
+		level := alpha*(y-s.season[slot]) + (1-alpha)*(s.level+s.trend)
+		s.trend = beta*(level-s.level) + (1-beta)*s.trend
+		s.season[slot] = gamma*(y-level) + (1-gamma)*s.season[slot]
+		s.level = level
+	}
+	return s
+}
+
+func holtWinters(values []float64, opts Options) *Forecast {
+	m, n := opts.Season, len(values)
+	f := &Forecast{Method: HoltWinters}
+	var best hwState
+	for _, alpha := range grid {
+		for _, beta := range grid {
+			for _, gamma := range grid {
+				s := smooth(values, m, alpha, beta, gamma)
+				if f.Alpha == 0 || s.sse < best.sse {
+					best, f.Alpha, f.Beta, f.Gamma = s, alpha, beta, gamma
+				}
+			}
+		}
+	}
+
+	sigma := math.Sqrt(best.sse / float64(best.count))
+	f.Predictions = make([]Prediction, opts.Horizon)
+	// the variance of the h-step forecast grows by c_j^2 for each step j
+	// before it (Hyndman et al., Forecasting with Exponential Smoothing)
+	variance := 1.0
+	for h := 1; h <= opts.Horizon; h++ {
+		if j := h - 1; j > 0 {
+			c := f.Alpha * (1 + float64(j)*f.Beta)
+			if j%m == 0 {
+				c += f.Gamma
+			}
+			variance += c * c
+		}
+		v := best.level + float64(h)*best.trend + best.season[(n-1+h)%m]
+		width := z(opts.Level) * sigma * math.Sqrt(variance)
+		f.Predictions[h-1] = Prediction{Value: v, Lower: v - width, Upper: v + width}
+	}
+	return f
+}
+
+func accuracy(actual []float64, predicted []Prediction) *Accuracy {
+	a := &Accuracy{Periods: len(actual)}
+	sse, ape, nonzero := 0.0, 0.0, 0
+	for i, y := range actual {
+		e := y - predicted[i].Value
+		sse += e * e
+		if y != 0 {
+			ape += math.Abs(e / y)
+			nonzero++
+		}
+	}
+	a.RMSE = math.Sqrt(sse / float64(len(actual)))
+	if nonzero > 0 {
+		mape := ape / float64(nonzero) * 100
+		a.MAPE = &mape
+	}
+	return a
+}
@@ -0,0 +1,87 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+// seasonal is four years of months with a trend and a yearly pattern.
+func seasonal() []float64 {
+	pattern := []float64{-20, -15, -5, 0, 5, 10, 20, 15, 5, 0, -5, -10}
+	var values []float64
+	for t := 0; t < 48; t++ {
+		values = append(values, 100+2*float64(t)+pattern[t%12])
+	}
+	return values
+}
+
+func TestHoltWinters(t *testing.T) {
+	values := seasonal()
+	f, err := Project(values, Options{Horizon: 12, Season: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Method != HoltWinters || len(f.Predictions) != 12 {
+		t.Fatalf("forecast = %+v", f)
+	}
+	// month 48 is a January: 100 + 96 - 20
+	if p := f.Predictions[0]; math.Abs(p.Value-176) > 2 || p.Lower > p.Value || p.Upper < p.Value {
+		t.Errorf("first prediction = %+v, want about 176", p)
+	}
+	if f.Predictions[11].Upper-f.Predictions[11].Lower < f.Predictions[0].Upper-f.Predictions[0].Lower {
+		t.Error("intervals should widen with the horizon")
+	}
+	if b := f.Backtest; b == nil || b.Periods != 12 || b.MAPE == nil || *b.MAPE > 2 {
+		t.Errorf("backtest = %+v", f.Backtest)
+	}
+}
+
+func TestSeasonalNaive(t *testing.T) {
+	values := seasonal()
+	f, err := Project(values, Options{Method: SeasonalNaive, Horizon: 13, Season: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Predictions[0].Value != values[36] || f.Predictions[12].Value != values[36] {
+		t.Errorf("seasonal naive should repeat the last season: %+v", f.Predictions)
+	}
+	// the trend adds 24 a year, which the baseline misses
+	if b := f.Backtest; b == nil || math.Abs(b.RMSE-24) > 1e-9 {
+		t.Errorf("backtest = %+v, want an RMSE of 24", f.Backtest)
+	}
+	// two seasons ahead, the interval is wider by sqrt(2)
+	w0 := f.Predictions[0].Upper - f.Predictions[0].Lower
+	w12 := f.Predictions[12].Upper - f.Predictions[12].Lower
+	if math.Abs(w12/w0-math.Sqrt2) > 1e-9 {
+		t.Errorf("interval widths %v and %v", w0, w12)
+	}
+}
+
+func TestProjectErrors(t *testing.T) {
+	if _, err := Project(make([]float64, 20), Options{Horizon: 3, Season: 12}); err == nil {
+		t.Error("Holt-Winters should need two seasons")
+	}
+	f, err := Project(make([]float64, 12), Options{Method: SeasonalNaive, Horizon: 3, Season: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Backtest != nil {
+		t.Error("a single season leaves nothing to backtest")
+	}
+	if _, err := Project(seasonal(), Options{Horizon: 0, Season: 12}); err == nil {
+		t.Error("a zero horizon should fail")
+	}
+}
+
+func TestNonNegative(t *testing.T) {
+	values := []float64{10, 0, 10, 0, 10, 0, 10, 0}
+	f, err := Project(values, Options{Horizon: 2, Season: 2, NonNegative: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range f.Predictions {
+		if p.Value < 0 || p.Lower < 0 {
+			t.Errorf("prediction below zero: %+v", p)
+		}
+	}
+}
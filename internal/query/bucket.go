@@ -0,0 +1,123 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"Dashlytics/internal/columnar"
+)
+
+// Bucket truncates dates to a calendar period.
+type Bucket string
+
+const (
+	Day     Bucket = "day"
+	Week    Bucket = "week" // ISO 8601 weeks, starting on Monday
+	Month   Bucket = "month"
+	Quarter Bucket = "quarter"
+	Year    Bucket = "year"
+)
+
+// Buckets lists every Bucket, finest first.
+var Buckets = []Bucket{Day, Week, Month, Quarter, Year}
+
+// ParseBucket validates a bucket name.
+func ParseBucket(s string) (Bucket, error) {
+	for _, b := range Buckets {
+		if strings.EqualFold(s, string(b)) {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("unknown date bucket %q (want day, week, month, quarter or year)", s)
+}
+
+// Of numbers the bucket holding day d. Consecutive months, quarters and
+// years differ by one; days and weeks are numbered by their first day.
+func (b Bucket) Of(d int32) int32 {
+	switch b {
+	case Week:
+		return d - mod7(d+3) // the epoch was a Thursday
+	case Month:
+		return columnar.MonthIndex(d)
+	case Quarter:
+		y, m, _ := columnar.Civil(d)
+		return int32(y*4 + (int(m)-1)/3)
+	case Year:
+		y, _, _ := columnar.Civil(d)
+		return int32(y)
+	}
+	return d
+}
+
+// Start returns the first day of the bucket holding day d.
+func (b Bucket) Start(d int32) int32 {
+	if b == Day || b == Week {
+		return b.Of(d)
+	}
+	y, m, _ := columnar.Civil(d)
+	switch b {
+	case Quarter:
+		m = (m-1)/3*3 + 1
+	case Year:
+		m = time.January
+	}
+	return columnar.DayOf(time.Date(y, m, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// MaxDays is the length of the longest bucket of its kind, e.g. 31 for months.
+func (b Bucket) MaxDays() int32 {
+	switch b {
+	case Week:
+		return 7
+	case Month:
+		return 31
+	case Quarter:
+		return 92
+	case Year:
+		return 366
+	}
+	return 1
+}
+
+// Next returns the first day of the bucket after the one starting on day start.
+func (b Bucket) Next(start int32) int32 {
+	if b == Day || b == Week {
+		return start + b.MaxDays()
+	}
+	// jump past the longest possible bucket, then back to its first day
+	return b.Start(start + b.MaxDays())
+}
+
+// Ordinal numbers buckets consecutively, so the buckets holding days a and
+// b are Ordinal(b)-Ordinal(a) apart.
+func (b Bucket) Ordinal(d int32) int32 {
+	if b == Week {
+		return (b.Of(d) - 4) / 7 // Mondays are day 4 modulo 7
+	}
+	return b.Of(d)
+}
+
+// Format labels the bucket holding day d: 2024-01-05, 2024-W01, 2024-01,
+// 2024-Q1 or 2024. Labels sort in chronological order.
+func (b Bucket) Format(d int32) string {
+	switch b {
+	case Week:
+		y, w := columnar.DayTime(b.Of(d)).ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", y, w)
+	case Month:
+		return columnar.FormatMonth(columnar.MonthIndex(d))
+	case Quarter:
+		y, m, _ := columnar.Civil(d)
+		return fmt.Sprintf("%04d-Q%d", y, (int(m)-1)/3+1)
+	case Year:
+		y, _, _ := columnar.Civil(d)
+		return fmt.Sprintf("%04d", y)
+	}
+	y, m, dd := columnar.Civil(d)
+	return fmt.Sprintf("%04d-%02d-%02d", y, int(m), dd)
+}
+
+func mod7(d int32) int32 {
+	return (d%7 + 7) % 7
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"Dashlytics/internal/columnar"
+)
+
+// field is a column of the transactions table.
+type field int
+
+const (
+	fieldID field = iota
+	fieldDate
+	fieldUserID
+	fieldCountry
+	fieldRegion
+	fieldProductID
+	fieldProductName
+	fieldCategory
+	fieldPrice
+	fieldQuantity
+	fieldTotalPrice
+	fieldStock
+	fieldAddedDate
+)
+
+// fieldNames are the canonical names, used for the result columns.
+var fieldNames = [...]string{
+	fieldID:          "id",
+	fieldDate:        "date",
+	fieldUserID:      "user_id",
+	fieldCountry:     "country",
+	fieldRegion:      "region",
+	fieldProductID:   "product_id",
+	fieldProductName: "product_name",
+	fieldCategory:    "category",
+	fieldPrice:       "price",
+	fieldQuantity:    "quantity",
+	fieldTotalPrice:  "total_price",
+	fieldStock:       "stock",
+	fieldAddedDate:   "added_date",
+}
+
+func (f field) String() string { return fieldNames[f] }
+
+// lookupField accepts the canonical names as well as the Transaction field
+// names, so "total_price", "TotalPrice" and "totalprice" are the same field.
+func lookupField(name string) (field, error) {
+	key := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	if key == "transactionid" {
+		return fieldID, nil
+	}
+	for f, n := range fieldNames {
+		if strings.ReplaceAll(n, "_", "") == key {
+			return field(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field %q (want one of %s)", name, strings.Join(fieldNames[:], ", "))
+}
+
+func (f field) isDate() bool { return f == fieldDate || f == fieldAddedDate }
+
+func (f field) isNumeric() bool {
+	return f == fieldPrice || f == fieldQuantity || f == fieldTotalPrice || f == fieldStock
+}
+
+// isInteger reports whether the numeric field holds whole numbers.
+func (f field) isInteger() bool { return f == fieldQuantity || f == fieldStock }
+
+func (f field) dict(t *columnar.Table) *columnar.Dict {
+	switch f {
+	case fieldUserID:
+		return t.UserID
+	case fieldCountry:
+		return t.Country
+	case fieldRegion:
+		return t.Region
+	case fieldProductID:
+		return t.ProductID
+	case fieldProductName:
+		return t.ProductName
+	case fieldCategory:
+		return t.Category
+	}
+	return nil
+}
+
+func (f field) days(t *columnar.Table) []int32 {
+	if f == fieldAddedDate {
+		return t.AddedDate
+	}
+	return t.Date
+}
+
+// number returns a reader of the numeric field f.
+func (f field) number(t *columnar.Table) func(i int) float64 {
+	switch f {
+	case fieldPrice:
+		return func(i int) float64 { return t.Price[i] }
+	case fieldQuantity:
+		return func(i int) float64 { return float64(t.Quantity[i]) }
+	case fieldTotalPrice:
+		return func(i int) float64 { return t.TotalPrice[i] }
+	case fieldStock:
+		return func(i int) float64 { return float64(t.Stock[i]) }
+	}
+	return nil
+}
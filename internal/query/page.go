@@ -0,0 +1,170 @@
+package query
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"Dashlytics/internal/columnar"
+)
+
+// MaxPage is the most rows one Page returns.
+const MaxPage = 10000
+
+// Listing orders rows by one field for paging through them.
+type Listing struct {
+	Sort string // any field name, e.g. total_price
+	Desc bool
+}
+
+// key is the position of a row in a listing: its value of the sorted
+// field, then its ID and offset, ascending, which break ties.
+type key struct {
+	Str string  `json:"str,omitempty"`
+	Num float64 `json:"num,omitempty"`
+	ID  string  `json:"id"`
+	Row int32   `json:"row"`
+}
+
+// cursor is the key of the last row of a page, with the listing it belongs to.
+type cursor struct {
+	Sort string `json:"sort"`
+	Desc bool   `json:"desc,omitempty"`
+	key
+}
+
+// Page returns up to limit of the given rows of t, all of them when rows is
+// nil, in the listing's order, starting after the row the cursor after
+// points to, or at the first when after is empty. next is the cursor of the
+// last row returned, empty when no rows follow.
+//
+// Cursors are opaque strings holding the sort value and ID of a row rather
+// than its position, so a page continues where the previous one ended even
+// when rows were added or removed in between.
+func Page(t *columnar.Table, rows []int32, l Listing, after string, limit int) (page []int32, next string, err error) {
+	f, err := lookupField(l.Sort)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit < 1 || limit > MaxPage {
+		return nil, "", fmt.Errorf("limit must be between 1 and %d", MaxPage)
+	}
+	keyOf := keyReader(t, f)
+	compare := func(a, b *key) int {
+		c := cmp.Compare(a.Str, b.Str)
+		if c == 0 {
+			c = cmp.Compare(a.Num, b.Num)
+		}
+		if l.Desc {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Row, b.Row)
+		}
+		return c
+	}
+
+	var from *key
+	if after != "" {
+		c, err := decodeCursor(after)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != f.String() || c.Desc != l.Desc {
+			return nil, "", fmt.Errorf("cursor belongs to another sort or order: start over without it")
+		}
+		from = &c.key
+	}
+
+	// keep the limit+1 first rows after the cursor, the extra one telling
+	// whether more follow
+	h := &keyHeap{compare: compare}
+	add := func(i int32) {
+		k := keyOf(i)
+		if from != nil && compare(&k, from) <= 0 {
+			return
+		}
+		if h.Len() <= limit {
+			heap.Push(h, k)
+		} else if compare(&k, &h.keys[0]) < 0 {
+			h.keys[0] = k
+			heap.Fix(h, 0)
+		}
+	}
+	if rows == nil {
+		for i := 0; i < t.Len(); i++ {
+			add(int32(i))
+		}
+	} else {
+		for _, i := range rows {
+			add(i)
+		}
+	}
+
+	keys := h.keys
+	slices.SortFunc(keys, func(a, b key) int { return compare(&a, &b) })
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = encodeCursor(cursor{Sort: f.String(), Desc: l.Desc, key: keys[limit-1]})
+	}
+	page = make([]int32, len(keys))
+	for i, k := range keys {
+		page[i] = k.Row
+	}
+	return page, next, nil
+}
+
+// keyReader returns the key of a row in a listing by f.
+func keyReader(t *columnar.Table, f field) func(i int32) key {
+	switch {
+	case f == fieldID:
+		return func(i int32) key { return key{ID: t.ID[i], Row: i} }
+	case f.dict(t) != nil:
+		d := f.dict(t)
+		return func(i int32) key { return key{Str: d.At(int(i)), ID: t.ID[i], Row: i} }
+	case f.isDate():
+		days := f.days(t)
+		return func(i int32) key { return key{Num: float64(days[i]), ID: t.ID[i], Row: i} }
+	}
+	number := f.number(t)
+	return func(i int32) key { return key{Num: number(int(i)), ID: t.ID[i], Row: i} }
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(raw, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+// keyHeap is a max-heap of keys, the last of them in the listing on top.
+type keyHeap struct {
+	keys    []key
+	compare func(a, b *key) int
+}
+
+func (h *keyHeap) Len() int           { return len(h.keys) }
+func (h *keyHeap) Less(i, j int) bool { return h.compare(&h.keys[i], &h.keys[j]) > 0 }
+func (h *keyHeap) Swap(i, j int)      { h.keys[i], h.keys[j] = h.keys[j], h.keys[i] }
+func (h *keyHeap) Push(x any)         { h.keys = append(h.keys, x.(key)) }
+func (h *keyHeap) Pop() any {
+	k := h.keys[len(h.keys)-1]
+	h.keys = h.keys[:len(h.keys)-1]
+	return k
+}
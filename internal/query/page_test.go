@@ -0,0 +1,81 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+// pageAll collects the IDs of every page of size limit.
+func pageAll(t *testing.T, rows []int32, l Listing, limit int) []string {
+	t.Helper()
+	tbl := testTable()
+	var ids []string
+	after := ""
+	for pages := 0; ; pages++ {
+		page, next, err := Page(tbl, rows, l, after, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range page {
+			ids = append(ids, tbl.ID[i])
+		}
+		if next == "" {
+			return ids
+		}
+		if pages > 10 {
+			t.Fatal("paging doesn't end")
+		}
+		after = next
+	}
+}
+
+func TestPage(t *testing.T) {
+	cases := []struct {
+		listing Listing
+		rows    []int32
+		want    []string
+	}{
+		{Listing{Sort: "date", Desc: true}, nil, []string{"4", "3", "2", "1"}},
+		// ties on price and product name are broken by ID
+		{Listing{Sort: "price"}, nil, []string{"1", "4", "2", "3"}},
+		{Listing{Sort: "ProductName", Desc: true}, nil, []string{"1", "4", "2", "3"}},
+		{Listing{Sort: "total_price", Desc: true}, []int32{0, 1, 3}, []string{"1", "2", "4"}},
+		{Listing{Sort: "id"}, nil, []string{"1", "2", "3", "4"}},
+	}
+	for _, c := range cases {
+		for _, limit := range []int{1, 3, 10} {
+			if got := pageAll(t, c.rows, c.listing, limit); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("%+v by %d = %v, want %v", c.listing, limit, got, c.want)
+			}
+		}
+	}
+}
+
+func TestPageCursorSurvivesChanges(t *testing.T) {
+	tbl := testTable()
+	l := Listing{Sort: "date"}
+	_, next, err := Page(tbl, nil, l, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second row of the first page is gone by the next request
+	page, _, err := Page(tbl, []int32{0, 2, 3}, l, next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || tbl.ID[page[0]] != "3" || tbl.ID[page[1]] != "4" {
+		t.Errorf("Expected rows 3 and 4 after the cursor, got %v", page)
+	}
+
+	if _, _, err := Page(tbl, nil, Listing{Sort: "date", Desc: true}, next, 2); err == nil {
+		t.Error("Expected an error for a cursor of another order")
+	}
+	for _, bad := range []string{"nope!", "bm9wZQ"} {
+		if _, _, err := Page(tbl, nil, l, bad, 2); err == nil {
+			t.Errorf("Expected an error for cursor %q", bad)
+		}
+	}
+	if _, _, err := Page(tbl, nil, Listing{Sort: "colour"}, "", 2); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
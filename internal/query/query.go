@@ -0,0 +1,336 @@
+// Package query runs group-by/aggregate queries over a columnar.Table. Rows
+// are grouped by any transaction field or date bucket and every group gets
+// the requested metrics, so a new dashboard question is a new Query rather
+// than another hand-written loop.
+package query
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"Dashlytics/internal/columnar"
+)
+
+// MaxGroupBy is the most dimensions a query may group by.
+const MaxGroupBy = 4
+
+// Query describes a grouping of the transactions.
+type Query struct {
+	// GroupBy lists field names, or date fields with a bucket such as
+	// "date:month". A date field without a bucket groups by day.
+	GroupBy []string `json:"group_by"`
+	Metrics []Metric `json:"metrics"`
+	// Sort orders the groups by result columns. Groups that compare equal
+	// are ordered by their dimensions, ascending.
+	Sort []Order `json:"sort,omitempty"`
+	// Limit keeps the first groups after sorting; zero keeps all of them.
+	Limit int `json:"limit,omitempty"`
+}
+
+// Metric is an aggregate computed for every group.
+type Metric struct {
+	// Op is sum, count, avg, min, max or first (the value of the group's
+	// first row in table order).
+	Op string `json:"op"`
+	// Field is one of quantity, total_price, price or stock. Count ignores it.
+	Field string `json:"field,omitempty"`
+	// As names the result column, "op_field" (or "count") by default.
+	As string `json:"as,omitempty"`
+}
+
+// Order sorts the result by one of its columns.
+type Order struct {
+	By   string `json:"by"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+// Ops lists the supported metric operations.
+var Ops = []string{"sum", "count", "avg", "min", "max", "first"}
+
+// Execute groups the given rows of t, all of them when rows is nil, and
+// returns the sorted and limited result.
+func Execute(t *columnar.Table, rows []int32, q Query) (*Result, error) {
+	p, err := compile(t, q)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.run(rows).Sorted(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	return res.Head(q.Limit), nil
+}
+
+// dimension is a compiled GroupBy entry. Rows with the same key fall in
+// the same group; value renders the key from any row of the group.
+type dimension struct {
+	name  string
+	typ   ColumnType
+	key   func(i int) int64
+	value func(i int) any
+}
+
+// metric is a compiled Metric.
+type metric struct {
+	name    string
+	op      string
+	read    func(i int) float64 // nil for count
+	integer bool
+}
+
+type plan struct {
+	t       *columnar.Table
+	dims    []dimension
+	metrics []metric
+}
+
+func compile(t *columnar.Table, q Query) (*plan, error) {
+	if len(q.GroupBy) == 0 && len(q.Metrics) == 0 {
+		return nil, fmt.Errorf("query needs at least one group_by dimension or metric")
+	}
+	if len(q.GroupBy) > MaxGroupBy {
+		return nil, fmt.Errorf("query groups by %d dimensions, at most %d are supported", len(q.GroupBy), MaxGroupBy)
+	}
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	p := &plan{t: t}
+	names := make(map[string]bool)
+	for _, spec := range q.GroupBy {
+		d, err := compileDimension(t, spec)
+		if err != nil {
+			return nil, err
+		}
+		if names[d.name] {
+			return nil, fmt.Errorf("duplicate group_by %q", d.name)
+		}
+		names[d.name] = true
+		p.dims = append(p.dims, d)
+	}
+	for _, m := range q.Metrics {
+		cm, err := compileMetric(t, m)
+		if err != nil {
+			return nil, err
+		}
+		if names[cm.name] {
+			return nil, fmt.Errorf("duplicate column %q, name the metric with \"as\"", cm.name)
+		}
+		names[cm.name] = true
+		p.metrics = append(p.metrics, cm)
+	}
+	for _, o := range q.Sort {
+		if !names[o.By] {
+			return nil, fmt.Errorf("cannot sort by %q: not a group_by dimension or metric", o.By)
+		}
+	}
+	return p, nil
+}
+
+func compileDimension(t *columnar.Table, spec string) (dimension, error) {
+	fieldName, bucketName, bucketed := strings.Cut(spec, ":")
+	f, err := lookupField(strings.TrimSpace(fieldName))
+	if err != nil {
+		return dimension{}, err
+	}
+	if bucketed && !f.isDate() {
+		return dimension{}, fmt.Errorf("group_by %q: only date fields take a bucket", spec)
+	}
+
+	switch {
+	case f.isDate():
+		b := Day
+		if bucketed {
+			if b, err = ParseBucket(strings.TrimSpace(bucketName)); err != nil {
+				return dimension{}, fmt.Errorf("group_by %q: %w", spec, err)
+			}
+		}
+		days := f.days(t)
+		return dimension{
+			name:  f.String() + ":" + string(b),
+			typ:   String,
+			key:   func(i int) int64 { return int64(b.Of(days[i])) },
+			value: func(i int) any { return b.Format(days[i]) },
+		}, nil
+	case f.dict(t) != nil:
+		d := f.dict(t)
+		return dimension{
+			name:  f.String(),
+			typ:   String,
+			key:   func(i int) int64 { return int64(d.Codes[i]) },
+			value: func(i int) any { return d.At(i) },
+		}, nil
+	case f.isNumeric():
+		read := f.number(t)
+		value := func(i int) any { return read(i) }
+		if f.isInteger() {
+			value = func(i int) any { return int64(read(i)) }
+		}
+		return dimension{
+			name:  f.String(),
+			typ:   Number,
+			key:   func(i int) int64 { return int64(math.Float64bits(read(i))) },
+			value: value,
+		}, nil
+	}
+
+	// IDs are not dictionary encoded, so they are numbered as they are met.
+	ids := make(map[string]int64)
+	return dimension{
+		name: f.String(),
+		typ:  String,
+		key: func(i int) int64 {
+			k, ok := ids[t.ID[i]]
+			if !ok {
+				k = int64(len(ids))
+				ids[t.ID[i]] = k
+			}
+			return k
+		},
+		value: func(i int) any { return t.ID[i] },
+	}, nil
+}
+
+func compileMetric(t *columnar.Table, m Metric) (metric, error) {
+	op := strings.ToLower(m.Op)
+	valid := false
+	for _, o := range Ops {
+		valid = valid || o == op
+	}
+	if !valid {
+		return metric{}, fmt.Errorf("unknown metric op %q (want one of %s)", m.Op, strings.Join(Ops, ", "))
+	}
+
+	cm := metric{name: m.As, op: op}
+	if op == "count" {
+		if cm.name == "" {
+			cm.name = "count"
+		}
+		return cm, nil
+	}
+	if m.Field == "" {
+		return metric{}, fmt.Errorf("metric %s needs a field", op)
+	}
+	f, err := lookupField(m.Field)
+	if err != nil {
+		return metric{}, err
+	}
+	if !f.isNumeric() {
+		return metric{}, fmt.Errorf("metric %s(%s): only quantity, total_price, price and stock can be aggregated", op, m.Field)
+	}
+	cm.read = f.number(t)
+	cm.integer = f.isInteger() && op != "avg"
+	if cm.name == "" {
+		cm.name = op + "_" + f.String()
+	}
+	return cm, nil
+}
+
+// acc accumulates one metric of one group.
+type acc struct {
+	n                    int
+	sum, min, max, first float64
+}
+
+func (a *acc) add(v float64) {
+	if a.n == 0 {
+		a.min, a.max, a.first = v, v, v
+	} else if v < a.min {
+		a.min = v
+	} else if v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.n++
+}
+
+func (m metric) result(a acc) any {
+	if m.op == "count" {
+		return int64(a.n)
+	}
+	if a.n == 0 {
+		return nil
+	}
+	var v float64
+	switch m.op {
+	case "sum":
+		v = a.sum
+	case "avg":
+		return a.sum / float64(a.n)
+	case "min":
+		v = a.min
+	case "max":
+		v = a.max
+	case "first":
+		v = a.first
+	}
+	if m.integer {
+		return int64(v)
+	}
+	return v
+}
+
+// run groups rows and renders one result row per group, in the order the
+// groups were met.
+func (p *plan) run(rows []int32) *Result {
+	groups := make(map[[MaxGroupBy]int64]int)
+	var firstRows []int
+	var accs []acc
+	nm := len(p.metrics)
+
+	add := func(i int) {
+		var key [MaxGroupBy]int64
+		for d := range p.dims {
+			key[d] = p.dims[d].key(i)
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = len(firstRows)
+			groups[key] = g
+			firstRows = append(firstRows, i)
+			accs = append(accs, make([]acc, nm)...)
+		}
+		a := accs[g*nm : (g+1)*nm]
+		for j := range p.metrics {
+			if read := p.metrics[j].read; read != nil {
+				a[j].add(read(i))
+			} else {
+				a[j].n++
+			}
+		}
+	}
+	if rows == nil {
+		for i := 0; i < p.t.Len(); i++ {
+			add(i)
+		}
+	} else {
+		for _, i := range rows {
+			add(int(i))
+		}
+	}
+	// without dimensions there is exactly one group, even over no rows
+	if len(p.dims) == 0 && len(firstRows) == 0 {
+		firstRows = append(firstRows, -1)
+		accs = make([]acc, nm)
+	}
+
+	res := &Result{Rows: make([][]any, len(firstRows)), Groups: len(firstRows), dims: len(p.dims)}
+	for _, d := range p.dims {
+		res.Columns = append(res.Columns, Column{Name: d.name, Type: d.typ})
+	}
+	for _, m := range p.metrics {
+		res.Columns = append(res.Columns, Column{Name: m.name, Type: Number})
+	}
+	for g, i := range firstRows {
+		row := make([]any, 0, len(res.Columns))
+		for _, d := range p.dims {
+			row = append(row, d.value(i))
+		}
+		for j, m := range p.metrics {
+			row = append(row, m.result(accs[g*nm+j]))
+		}
+		res.Rows[g] = row
+	}
+	return res
+}
@@ -0,0 +1,156 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+func day(s string) time.Time {
+	t, _ := domain.ParseDate(s)
+	return t
+}
+
+func testTable() *columnar.Table {
+	return columnar.FromTransactions([]domain.Transaction{
+		{ID: "1", Date: day("2024-01-05"), Country: "USA", ProductName: "Widget", Price: 10, Quantity: 2, TotalPrice: 20, Stock: 7},
+		{ID: "2", Date: day("2024-01-20"), Country: "Canada", ProductName: "Gadget", Price: 15, Quantity: 1, TotalPrice: 15, Stock: 3},
+		{ID: "3", Date: day("2024-02-02"), Country: "USA", ProductName: "Gadget", Price: 15, Quantity: 4, TotalPrice: 60, Stock: 5},
+		{ID: "4", Date: day("2024-04-11"), Country: "USA", ProductName: "Widget", Price: 12, Quantity: 1, TotalPrice: 12, Stock: 9},
+	})
+}
+
+func TestExecuteGroupsAndMetrics(t *testing.T) {
+	res, err := Execute(testTable(), nil, Query{
+		GroupBy: []string{"country"},
+		Metrics: []Metric{
+			{Op: "sum", Field: "TotalPrice", As: "revenue"},
+			{Op: "count"},
+			{Op: "avg", Field: "price"},
+			{Op: "min", Field: "quantity"},
+			{Op: "max", Field: "quantity"},
+			{Op: "first", Field: "stock"},
+		},
+		Sort: []Order{{By: "revenue", Desc: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCols := []Column{
+		{"country", String}, {"revenue", Number}, {"count", Number}, {"avg_price", Number},
+		{"min_quantity", Number}, {"max_quantity", Number}, {"first_stock", Number},
+	}
+	if !reflect.DeepEqual(res.Columns, wantCols) {
+		t.Errorf("columns = %+v", res.Columns)
+	}
+	wantRows := [][]any{
+		{"USA", 92.0, int64(3), 37.0 / 3, int64(1), int64(4), int64(7)},
+		{"Canada", 15.0, int64(1), 15.0, int64(1), int64(1), int64(3)},
+	}
+	if !reflect.DeepEqual(res.Rows, wantRows) {
+		t.Errorf("rows = %v, want %v", res.Rows, wantRows)
+	}
+}
+
+func TestExecuteDateBucketsFilterAndLimit(t *testing.T) {
+	tbl := testTable()
+	cases := []struct {
+		groupBy string
+		want    []string
+	}{
+		{"date", []string{"2024-01-05", "2024-01-20", "2024-02-02", "2024-04-11"}},
+		{"date:week", []string{"2024-W01", "2024-W03", "2024-W05", "2024-W15"}},
+		{"date:month", []string{"2024-01", "2024-02", "2024-04"}},
+		{"date:quarter", []string{"2024-Q1", "2024-Q2"}},
+		{"date:year", []string{"2024"}},
+	}
+	for _, c := range cases {
+		res, err := Execute(tbl, nil, Query{GroupBy: []string{c.groupBy}, Metrics: []Metric{{Op: "count"}}})
+		if err != nil {
+			t.Fatalf("%s: %v", c.groupBy, err)
+		}
+		var got []string
+		for _, row := range res.Rows {
+			got = append(got, row[0].(string))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.groupBy, got, c.want)
+		}
+	}
+
+	res, err := Execute(tbl, []int32{0, 2, 3}, Query{
+		GroupBy: []string{"product_name", "date:month"},
+		Metrics: []Metric{{Op: "sum", Field: "quantity"}},
+		Limit:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]any{{"Gadget", "2024-02", int64(4)}, {"Widget", "2024-01", int64(2)}}
+	if !reflect.DeepEqual(res.Rows, want) || res.Groups != 3 {
+		t.Errorf("got %v (%d groups), want %v (3 groups)", res.Rows, res.Groups, want)
+	}
+}
+
+func TestExecuteWithoutDimensions(t *testing.T) {
+	res, err := Execute(testTable(), []int32{}, Query{Metrics: []Metric{{Op: "count"}, {Op: "max", Field: "price"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]any{{int64(0), nil}}; !reflect.DeepEqual(res.Rows, want) {
+		t.Errorf("rows = %v, want %v", res.Rows, want)
+	}
+}
+
+func TestExecuteRejectsInvalidQueries(t *testing.T) {
+	for _, q := range []Query{
+		{},
+		{GroupBy: []string{"colour"}},
+		{GroupBy: []string{"country:month"}},
+		{GroupBy: []string{"date:fortnight"}},
+		{GroupBy: []string{"country", "country"}},
+		{Metrics: []Metric{{Op: "median", Field: "price"}}},
+		{Metrics: []Metric{{Op: "sum"}}},
+		{Metrics: []Metric{{Op: "sum", Field: "country"}}},
+		{Metrics: []Metric{{Op: "count"}, {Op: "count"}}},
+		{GroupBy: []string{"country"}, Sort: []Order{{By: "revenue"}}},
+		{GroupBy: []string{"id", "date", "country", "region", "category"}},
+	} {
+		if _, err := Execute(testTable(), nil, q); err == nil {
+			t.Errorf("Execute(%+v) should fail", q)
+		}
+	}
+}
+
+func TestBucketFormatAcrossYears(t *testing.T) {
+	// 2021-01-03 is a Sunday in the last ISO week of 2020
+	d := columnar.DayOf(day("2021-01-03"))
+	if got := Week.Format(d); got != "2020-W53" {
+		t.Errorf("Week.Format = %s", got)
+	}
+	if Week.Of(d) != columnar.DayOf(day("2020-12-28")) {
+		t.Errorf("Week.Of should be the Monday")
+	}
+	if Quarter.Of(columnar.DayOf(day("2024-01-01")))-Quarter.Of(d) != 12 {
+		t.Errorf("quarters should be numbered continuously")
+	}
+}
+
+func TestBucketNextAndOrdinal(t *testing.T) {
+	for _, b := range Buckets {
+		start := b.Start(columnar.DayOf(day("2023-11-15")))
+		for i := 0; i < 30; i++ {
+			next := b.Next(start)
+			if b.Start(next) != next || b.Start(next-1) != start {
+				t.Fatalf("%s: Next(%s) = %s", b, b.Format(start), b.Format(next))
+			}
+			if b.Ordinal(next)-b.Ordinal(start) != 1 || b.Ordinal(next-1) != b.Ordinal(start) {
+				t.Fatalf("%s: ordinals of %s and %s are not consecutive", b, b.Format(start), b.Format(next))
+			}
+			start = next
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnType is the JSON type of a result column.
+type ColumnType string
+
+const (
+	String ColumnType = "string"
+	Number ColumnType = "number"
+)
+
+// Column describes one result column.
+type Column struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+}
+
+// Result is a query answer in tabular form: the group_by dimensions come
+// first, then the metrics in the order they were requested. Cells hold a
+// string, an int64 or a float64, or nil for a metric over no rows.
+type Result struct {
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	// Groups is the number of groups before the limit was applied.
+	Groups int `json:"groups"`
+
+	dims int
+}
+
+// Index returns the position of the named column, or -1.
+func (r *Result) Index(name string) int {
+	for i, c := range r.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sorted returns a copy of r ordered by orders, then by the dimensions.
+// The rows themselves are shared, as results are never modified in place.
+func (r *Result) Sorted(orders []Order) (*Result, error) {
+	cols := make([]int, len(orders))
+	for i, o := range orders {
+		if cols[i] = r.Index(o.By); cols[i] < 0 {
+			return nil, fmt.Errorf("cannot sort by %q: not a result column", o.By)
+		}
+	}
+
+	sorted := *r
+	sorted.Rows = append([][]any(nil), r.Rows...)
+	sort.SliceStable(sorted.Rows, func(i, j int) bool {
+		a, b := sorted.Rows[i], sorted.Rows[j]
+		for k, o := range orders {
+			if c := compare(a[cols[k]], b[cols[k]]); c != 0 {
+				return (c < 0) != o.Desc
+			}
+		}
+		for d := 0; d < r.dims; d++ {
+			if c := compare(a[d], b[d]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return &sorted, nil
+}
+
+// Head returns r cut to its first n rows, or r itself when n is zero or
+// covers every row.
+func (r *Result) Head(n int) *Result {
+	if n <= 0 || n >= len(r.Rows) {
+		return r
+	}
+	head := *r
+	head.Rows = r.Rows[:n]
+	return &head
+}
+
+// compare orders cells: nil first, then numbers by value, strings bytewise.
+func compare(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.Compare(as, bs)
+		}
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
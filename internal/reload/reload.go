@@ -0,0 +1,183 @@
+// Package reload rebuilds the DataStore in the background and swaps it in
+// once it is ready, so the server keeps answering from the old data while
+// the new file is parsed.
+package reload
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Dashlytics/internal/repository"
+)
+
+// ErrInProgress is returned when a reload is requested while one is running.
+var ErrInProgress = errors.New("reload already in progress")
+
+// State is the phase of the most recent reload.
+type State string
+
+const (
+	StateIdle      State = "idle"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// Status describes the last reload for the admin endpoint.
+type Status struct {
+	State        State     `json:"state"`
+	Trigger      string    `json:"trigger,omitempty"`
+	StartedAt    time.Time `json:"started_at,omitempty"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	Rows         int       `json:"rows"`
+	PreviousRows int       `json:"previous_rows"`
+	SkippedRows  int       `json:"skipped_rows"`
+	FromSnapshot bool      `json:"from_snapshot"`
+	Error        string    `json:"error,omitempty"`
+	Reloads      int       `json:"reloads"`
+}
+
+// Reloader loads a repository.Source and publishes the result through a Holder.
+type Reloader struct {
+	src     repository.Source
+	holder  *repository.Holder
+	running atomic.Bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+func New(src repository.Source, holder *repository.Holder) *Reloader {
+	return &Reloader{src: src, holder: holder, status: Status{State: StateIdle}}
+}
+
+// Status returns a copy of the last reload status.
+func (rl *Reloader) Status() Status {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.status
+}
+
+// Reload builds a new DataStore and swaps it in. The current one stays in
+// place if loading fails.
+func (rl *Reloader) Reload(trigger string) error {
+	if !rl.running.CompareAndSwap(false, true) {
+		return ErrInProgress
+	}
+	defer rl.running.Store(false)
+	return rl.reload(trigger)
+}
+
+// Start runs Reload in the background.
+func (rl *Reloader) Start(trigger string) error {
+	if !rl.running.CompareAndSwap(false, true) {
+		return ErrInProgress
+	}
+	go func() {
+		defer rl.running.Store(false)
+		if err := rl.reload(trigger); err != nil {
+			log.Printf("reload (%s) failed: %v", trigger, err)
+		}
+	}()
+	return nil
+}
+
+func (rl *Reloader) reload(trigger string) error {
+	start := time.Now()
+	rl.mu.Lock()
+	rl.status.State = StateRunning
+	rl.status.Trigger = trigger
+	rl.status.StartedAt = start
+	rl.status.FinishedAt = time.Time{}
+	rl.status.Error = ""
+	rl.mu.Unlock()
+
+	ds, report, err := rl.src.Load()
+	if report.SnapshotErr != nil {
+		log.Printf("reload: ignoring snapshot: %v", report.SnapshotErr)
+	}
+	var previous int
+	if err == nil {
+		if old := rl.holder.Store(ds); old != nil {
+			previous = old.Len()
+		}
+		log.Printf("reload (%s): loaded %s", trigger, report)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.status.FinishedAt = time.Now()
+	rl.status.DurationMS = rl.status.FinishedAt.Sub(start).Milliseconds()
+	rl.status.Reloads++
+	if err != nil {
+		rl.status.State = StateFailed
+		rl.status.Error = err.Error()
+		return err
+	}
+	rl.status.State = StateSucceeded
+	rl.status.Rows = report.Rows
+	rl.status.PreviousRows = previous
+	rl.status.FromSnapshot = report.FromSnapshot
+	rl.status.SkippedRows = 0
+	if report.CSV != nil {
+		rl.status.SkippedRows = report.CSV.Skipped
+	}
+	return nil
+}
+
+// fileVersion identifies a revision of the data file.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{info.ModTime(), info.Size()}, nil
+}
+
+// Watch polls the CSV file every interval and reloads once a change has
+// settled, that is when two polls in a row see the same new version. This
+// avoids loading a file that is still being copied in.
+func (rl *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	seen, _ := statVersion(rl.src.CSVPath)
+	var pending *fileVersion
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		v, err := statVersion(rl.src.CSVPath)
+		if err != nil || v == seen {
+			pending = nil
+			continue
+		}
+		if pending == nil || *pending != v {
+			pending = &v
+			continue
+		}
+		err = rl.Reload("watch")
+		if errors.Is(err, ErrInProgress) {
+			continue
+		}
+		if err != nil {
+			// wait for the next change rather than retrying a broken file
+			log.Printf("reload (watch) failed: %v", err)
+		}
+		seen, pending = v, nil
+	}
+}
@@ -0,0 +1,67 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Dashlytics/internal/repository"
+)
+
+const header = "ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\n"
+
+func writeCSV(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(header+content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadSwapsDataStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	writeCSV(t, path, "TX1,2024-01-01,USA,Texas,Widget,1,10,5\n")
+	var holder repository.Holder
+	rl := New(repository.Source{CSVPath: path}, &holder)
+	if err := rl.Reload("test"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	before := holder.Load()
+
+	writeCSV(t, path, "TX1,2024-01-01,USA,Texas,Widget,1,10,5\nTX2,2024-01-02,USA,Texas,Widget,1,10,5\n")
+	if err := rl.Reload("test"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if before.Len() != 1 {
+		t.Errorf("the old DataStore must not change, got %d rows", before.Len())
+	}
+	if got := holder.Load().Len(); got != 2 {
+		t.Errorf("expected 2 rows after reload, got %d", got)
+	}
+	status := rl.Status()
+	if status.State != StateSucceeded || status.Rows != 2 || status.PreviousRows != 1 || status.Reloads != 2 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestFailedReloadKeepsCurrentData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	writeCSV(t, path, "TX1,2024-01-01,USA,Texas,Widget,1,10,5\n")
+	var holder repository.Holder
+	rl := New(repository.Source{CSVPath: path}, &holder)
+	if err := rl.Reload("test"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	before := holder.Load()
+
+	writeCSV(t, path, "TX1,2024-01-01,USA,Texas,Widget,lots,10,5\n")
+	if err := rl.Reload("test"); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+	if holder.Load() != before {
+		t.Error("a failed reload must keep the current DataStore")
+	}
+	if status := rl.Status(); status.State != StateFailed || status.Error == "" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
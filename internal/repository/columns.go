@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Column identifies a domain.Transaction field that is populated from a CSV column.
+type Column string
+
+const (
+	ColID          Column = "ID"
+	ColDate        Column = "Date"
+	ColUserID      Column = "UserID"
+	ColCountry     Column = "Country"
+	ColRegion      Column = "Region"
+	ColProductID   Column = "ProductID"
+	ColProductName Column = "ProductName"
+	ColCategory    Column = "Category"
+	ColPrice       Column = "Price"
+	ColQuantity    Column = "Quantity"
+	ColTotalPrice  Column = "TotalPrice"
+	ColStock       Column = "Stock"
+	ColAddedDate   Column = "AddedDate"
+)
+
+// AllColumns lists every column in domain.Transaction field order.
+var AllColumns = []Column{
+	ColID, ColDate, ColUserID, ColCountry, ColRegion, ColProductID, ColProductName,
+	ColCategory, ColPrice, ColQuantity, ColTotalPrice, ColStock, ColAddedDate,
+}
+
+// RequiredColumns must be present in the header, the remaining columns are
+// left at their zero value when the source file does not have them.
+var RequiredColumns = []Column{
+	ColID, ColDate, ColCountry, ColRegion, ColProductName, ColQuantity, ColTotalPrice, ColStock,
+}
+
+// ColumnMapping maps a source header name to the column it fills.
+// Header names are matched case-insensitively, ignoring surrounding
+// whitespace and treating spaces and dashes as underscores.
+type ColumnMapping map[string]Column
+
+// DefaultColumnMapping understands the field names themselves plus the
+// aliases seen in the exports we receive.
+var DefaultColumnMapping = ColumnMapping{
+	"id":               ColID,
+	"transaction_id":   ColID,
+	"txn_id":           ColID,
+	"date":             ColDate,
+	"transaction_date": ColDate,
+	"txn_date":         ColDate,
+	"userid":           ColUserID,
+	"user_id":          ColUserID,
+	"customer_id":      ColUserID,
+	"country":          ColCountry,
+	"region":           ColRegion,
+	"state":            ColRegion,
+	"productid":        ColProductID,
+	"product_id":       ColProductID,
+	"sku":              ColProductID,
+	"productname":      ColProductName,
+	"product_name":     ColProductName,
+	"product":          ColProductName,
+	"category":         ColCategory,
+	"product_category": ColCategory,
+	"price":            ColPrice,
+	"unit_price":       ColPrice,
+	"quantity":         ColQuantity,
+	"qty":              ColQuantity,
+	"totalprice":       ColTotalPrice,
+	"total_price":      ColTotalPrice,
+	"total":            ColTotalPrice,
+	"stock":            ColStock,
+	"stock_quantity":   ColStock,
+	"addeddate":        ColAddedDate,
+	"added_date":       ColAddedDate,
+}
+
+func normalizeHeader(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff") // Excel exports start with a BOM
+	h = strings.ToLower(strings.TrimSpace(h))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+}
+
+func isColumn(c Column) bool {
+	for _, known := range AllColumns {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadColumnMapping reads a JSON object of {"source header": "Column"} pairs
+// and returns DefaultColumnMapping extended with them.
+func LoadColumnMapping(path string) (ColumnMapping, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var extra map[string]Column
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		return nil, fmt.Errorf("parse column mapping %s: %w", path, err)
+	}
+
+	mapping := make(ColumnMapping, len(DefaultColumnMapping)+len(extra))
+	for h, c := range DefaultColumnMapping {
+		mapping[h] = c
+	}
+	for h, c := range extra {
+		if !isColumn(c) {
+			return nil, fmt.Errorf("column mapping %s: %q maps to unknown column %q", path, h, c)
+		}
+		mapping[normalizeHeader(h)] = c
+	}
+	return mapping, nil
+}
+
+// columnIndex holds the record position of every column, -1 when absent.
+type columnIndex map[Column]int
+
+// resolveColumns matches a header row against the mapping. Unknown headers
+// are ignored so exports with extra columns still load.
+func resolveColumns(header []string, mapping ColumnMapping) (columnIndex, error) {
+	idx := make(columnIndex, len(AllColumns))
+	for _, c := range AllColumns {
+		idx[c] = -1
+	}
+	for i, h := range header {
+		c, ok := mapping[normalizeHeader(h)]
+		if !ok {
+			continue
+		}
+		if prev := idx[c]; prev >= 0 {
+			return nil, fmt.Errorf("CSV columns %q and %q both map to %s", header[prev], h, c)
+		}
+		idx[c] = i
+	}
+
+	var missing []string
+	for _, c := range RequiredColumns {
+		if idx[c] < 0 {
+			missing = append(missing, string(c))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header is missing required columns: %s (header: %s)",
+			strings.Join(missing, ", "), strings.Join(header, ","))
+	}
+	return idx, nil
+}
+
+// field returns the raw value of column c, or "" when the file doesn't have it.
+func (idx columnIndex) field(record []string, c Column) string {
+	if i := idx[c]; i >= 0 && i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+func isRequired(c Column) bool {
+	for _, r := range RequiredColumns {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -1,89 +1,284 @@
 package repository
 
 import (
-	"encoding/csv"
-	"io"
+	"fmt"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"Dashlytics/internal/domain"
 )
 
-type DataStore struct {
-	AllTransactions []domain.Transaction
-	ByCountry       map[string][]domain.Transaction
-	ByProduct       map[string][]domain.Transaction
-	ByTransactionID map[string]domain.Transaction
-	ByUserID        map[string][]domain.Transaction
-	ByRegion        map[string][]domain.Transaction
-	ByCategory      map[string][]domain.Transaction
+// ParseMode decides what happens to rows that fail to parse.
+type ParseMode int
+
+const (
+	// Strict stops at the first bad row.
+	Strict ParseMode = iota
+	// Lenient skips bad rows, recording them in the result and the quarantine file.
+	Lenient
+)
+
+// LoadOptions configures how LoadCSVWithOptions reads a file.
+type LoadOptions struct {
+	// Mapping resolves header names to columns, DefaultColumnMapping when nil.
+	Mapping ColumnMapping
+	Mode    ParseMode
+	// QuarantinePath receives the rows skipped in Lenient mode, no file is written when empty.
+	QuarantinePath string
+	// Workers is the number of chunks parsed concurrently, GOMAXPROCS when zero.
+	Workers int
+	// ChunkSize is the target size in bytes of each chunk, picked from the
+	// file size and worker count when zero.
+	ChunkSize int64
+}
+
+// LoadResult holds the parsed transactions together with what was skipped.
+type LoadResult struct {
+	Transactions []domain.Transaction
+	// Skipped counts the rows dropped in Lenient mode.
+	Skipped int
+	// ColumnErrors counts parse failures per column.
+	ColumnErrors map[Column]int
+	Stats        LoadStats
 }
 
-var GlobalDataStore *DataStore
+// LoadStats reports how the file was read.
+type LoadStats struct {
+	Bytes    int64
+	Rows     int
+	Chunks   int
+	Workers  int
+	Duration time.Duration
+}
 
-func InitDataStore(transactions []domain.Transaction) {
-	GlobalDataStore = &DataStore{
-		AllTransactions: transactions,
-		ByCountry:       make(map[string][]domain.Transaction),
-		ByProduct:       make(map[string][]domain.Transaction),
-		ByTransactionID: make(map[string]domain.Transaction),
-		ByUserID:        make(map[string][]domain.Transaction),
-		ByRegion:        make(map[string][]domain.Transaction),
-		ByCategory:      make(map[string][]domain.Transaction),
+// RowsPerSecond is the parse throughput in rows.
+func (s LoadStats) RowsPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
 	}
+	return float64(s.Rows) / s.Duration.Seconds()
+}
 
-	for _, tx := range transactions {
-		GlobalDataStore.ByCountry[tx.Country] = append(GlobalDataStore.ByCountry[tx.Country], tx)
-		GlobalDataStore.ByProduct[tx.ProductID] = append(GlobalDataStore.ByProduct[tx.ProductID], tx)
-		GlobalDataStore.ByTransactionID[tx.ID] = tx
-		GlobalDataStore.ByUserID[tx.UserID] = append(GlobalDataStore.ByUserID[tx.UserID], tx)
-		GlobalDataStore.ByRegion[tx.Region] = append(GlobalDataStore.ByRegion[tx.Region], tx)
-		GlobalDataStore.ByCategory[tx.Category] = append(GlobalDataStore.ByCategory[tx.Category], tx)
+// MBPerSecond is the parse throughput in megabytes.
+func (s LoadStats) MBPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
 	}
+	return float64(s.Bytes) / (1 << 20) / s.Duration.Seconds()
 }
 
-// loadCSV reads a CSV file and returns a slice of Transaction structs.
+func (s LoadStats) String() string {
+	return fmt.Sprintf("%d rows, %.1f MB in %s with %d workers over %d chunks (%.0f rows/s, %.1f MB/s)",
+		s.Rows, float64(s.Bytes)/(1<<20), s.Duration.Round(time.Millisecond), s.Workers, s.Chunks,
+		s.RowsPerSecond(), s.MBPerSecond())
+}
+
+// LoadCSV reads a CSV file in strict mode and returns a slice of Transaction structs.
 func LoadCSV(filePath string) ([]domain.Transaction, error) {
+	res, err := LoadCSVWithOptions(filePath, LoadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Transactions, nil
+}
+
+// LoadCSVWithOptions reads a CSV file, locating each field through the header row.
+// The body is split into byte ranges on line boundaries which are parsed on a
+// worker pool and merged back in file order. When a quoted field holding a
+// newline straddles two ranges, the body is parsed again as one range.
+func LoadCSVWithOptions(filePath string, opts LoadOptions) (*LoadResult, error) {
+	start := time.Now()
+	mapping := opts.Mapping
+	if mapping == nil {
+		mapping = DefaultColumnMapping
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	reader := csv.NewReader(file)
-	_, _ = reader.Read() // Skip header row
-	var transactions []domain.Transaction
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header, bodyStart, err := readHeader(file)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := resolveColumns(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize(info.Size()-bodyStart, workers)
+	}
+	chunks, err := splitChunks(file, bodyStart, info.Size(), chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	results := parseChunks(file, chunks, workers, len(header), cols, opts.Mode)
+	if splitsQuotedField(results) {
+		chunks = []byteRange{{bodyStart, info.Size()}}
+		results = parseChunks(file, chunks, 1, len(header), cols, opts.Mode)
+	}
+
+	res, err := mergeChunks(results, header, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Stats = LoadStats{
+		Bytes:    info.Size(),
+		Rows:     len(res.Transactions) + res.Skipped,
+		Chunks:   len(chunks),
+		Workers:  workers,
+		Duration: time.Since(start),
+	}
+	return res, nil
+}
+
+// mergeChunks concatenates the chunk results in file order, turning chunk
+// relative line numbers into file line numbers and writing the quarantine file.
+func mergeChunks(results []chunkResult, header []string, opts LoadOptions) (*LoadResult, error) {
+	line, total := 1, 0 // the header is line 1
+	for i := range results {
+		for _, rej := range results[i].rejected {
+			rej.err.Line += line
+		}
+		line += results[i].lines
+		total += len(results[i].transactions)
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		if opts.Mode == Strict && len(r.rejected) > 0 {
+			return nil, r.rejected[0].err
 		}
-		if err != nil {
+	}
+
+	var quarantine *quarantineWriter
+	if opts.Mode == Lenient && opts.QuarantinePath != "" {
+		var err error
+		if quarantine, err = newQuarantineWriter(opts.QuarantinePath, header); err != nil {
 			return nil, err
 		}
-		price, _ := strconv.ParseFloat(record[8], 64)
-		quantity, _ := strconv.Atoi(record[9])
-		totalPrice, _ := strconv.ParseFloat(record[10], 64)
-		stock, _ := strconv.Atoi(record[11])
-		txDate, _ := time.Parse("2006-01-02", record[1])
-		addedDate, _ := time.Parse("2006-01-02", record[12])
-
-		t := domain.Transaction{
-			ID:          record[0],
-			Date:        txDate,
-			UserID:      record[2],
-			Country:     record[3],
-			Region:      record[4],
-			ProductID:   record[5],
-			ProductName: record[6],
-			Category:    record[7],
-			Price:       price,
-			Quantity:    quantity,
-			TotalPrice:  totalPrice,
-			Stock:       stock,
-			AddedDate:   addedDate,
+		defer quarantine.Close()
+	}
+
+	res := &LoadResult{
+		Transactions: make([]domain.Transaction, 0, total),
+		ColumnErrors: make(map[Column]int),
+	}
+	for _, r := range results {
+		res.Transactions = append(res.Transactions, r.transactions...)
+		for _, rej := range r.rejected {
+			res.Skipped++
+			res.ColumnErrors[rej.err.Column]++
+			if quarantine != nil {
+				if err := quarantine.Write(rej.err, rej.record); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if quarantine != nil {
+		if err := quarantine.Close(); err != nil {
+			return nil, err
 		}
-		transactions = append(transactions, t)
 	}
-	return transactions, nil
+	return res, nil
+}
+
+// parseRecord converts one CSV record into a Transaction. Required columns
+// must hold a valid value, optional ones may be left empty.
+func parseRecord(record []string, cols columnIndex, line int) (domain.Transaction, *RowError) {
+	p := recordParser{record: record, cols: cols, line: line}
+	t := domain.Transaction{
+		ID:          cols.field(record, ColID),
+		Date:        p.date(ColDate),
+		UserID:      cols.field(record, ColUserID),
+		Country:     cols.field(record, ColCountry),
+		Region:      cols.field(record, ColRegion),
+		ProductID:   cols.field(record, ColProductID),
+		ProductName: cols.field(record, ColProductName),
+		Category:    cols.field(record, ColCategory),
+		Price:       p.float(ColPrice),
+		Quantity:    p.int(ColQuantity),
+		TotalPrice:  p.float(ColTotalPrice),
+		Stock:       p.int(ColStock),
+		AddedDate:   p.date(ColAddedDate),
+	}
+	return t, p.err
+}
+
+// recordParser keeps the first conversion error of a record.
+type recordParser struct {
+	record []string
+	cols   columnIndex
+	line   int
+	err    *RowError
+}
+
+// value returns the raw field and whether it should be parsed.
+func (p *recordParser) value(c Column) (string, bool) {
+	if p.err != nil {
+		return "", false
+	}
+	v := strings.TrimSpace(p.cols.field(p.record, c))
+	if v == "" && !isRequired(c) {
+		return "", false
+	}
+	return v, true
+}
+
+func (p *recordParser) fail(c Column, v string, err error) {
+	p.err = &RowError{Line: p.line, Column: c, Value: v, Err: err}
+}
+
+func (p *recordParser) float(c Column) float64 {
+	v, ok := p.value(c)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		p.fail(c, v, err)
+	}
+	return f
+}
+
+func (p *recordParser) int(c Column) int {
+	v, ok := p.value(c)
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.fail(c, v, err)
+	}
+	return n
+}
+
+func (p *recordParser) date(c Column) time.Time {
+	v, ok := p.value(c)
+	if !ok {
+		return time.Time{}
+	}
+	d, err := domain.ParseDate(v)
+	if err != nil {
+		p.fail(c, v, err)
+	}
+	return d
 }
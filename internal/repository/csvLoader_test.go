@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCSVResolvesColumnsByHeader(t *testing.T) {
+	// reordered columns, an alias for ID and an extra unknown column
+	path := writeCSV(t, "Quantity,txn_id,Notes,Country,Region,Product Name,Total Price,Stock,Date\n"+
+		"3,TX1,gift,Canada,Ontario,Widget,30.5,12,2024-02-03\n")
+
+	txs, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(txs))
+	}
+	tx := txs[0]
+	if tx.ID != "TX1" || tx.Country != "Canada" || tx.ProductName != "Widget" || tx.Quantity != 3 || tx.TotalPrice != 30.5 {
+		t.Errorf("unexpected transaction: %+v", tx)
+	}
+	if tx.Date.Format("2006-01-02") != "2024-02-03" {
+		t.Errorf("expected date 2024-02-03, got %s", tx.Date)
+	}
+}
+
+func TestLoadCSVMissingRequiredColumns(t *testing.T) {
+	path := writeCSV(t, "ID,Date,Country,Region,ProductName,Stock\nTX1,2024-01-01,USA,Texas,Widget,1\n")
+
+	_, err := LoadCSV(path)
+	if err == nil {
+		t.Fatal("expected an error for missing columns")
+	}
+	if !strings.Contains(err.Error(), "Quantity, TotalPrice") {
+		t.Errorf("error should name the missing columns, got %q", err)
+	}
+}
+
+func TestLoadColumnMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "columns.json")
+	if err := os.WriteFile(path, []byte(`{"Order Ref": "ID"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := LoadColumnMapping(path)
+	if err != nil {
+		t.Fatalf("LoadColumnMapping: %v", err)
+	}
+	if mapping["order_ref"] != ColID || mapping["txn_id"] != ColID {
+		t.Errorf("expected custom and default aliases for ID, got %v", mapping)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"Order Ref": "OrderRef"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadColumnMapping(path); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+const badRowsCSV = "ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\n" +
+	"TX1,2024-01-01,USA,Texas,Widget,1,10,5\n" +
+	"TX2,2024-01-02,USA,Texas,Widget,one,10,5\n" +
+	"TX3,2024-13-40,USA,Texas,Widget,1,10,5\n" +
+	"TX4,2024-01-04,USA,Texas,Widget,2,20,5\n"
+
+func TestLoadCSVStrictReportsLineAndColumn(t *testing.T) {
+	_, err := LoadCSVWithOptions(writeCSV(t, badRowsCSV), LoadOptions{Mode: Strict})
+
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("expected a RowError, got %v", err)
+	}
+	if rowErr.Line != 3 || rowErr.Column != ColQuantity || rowErr.Value != "one" {
+		t.Errorf("unexpected row error: %v", rowErr)
+	}
+}
+
+func TestLoadCSVLenientQuarantinesBadRows(t *testing.T) {
+	quarantinePath := filepath.Join(t.TempDir(), "quarantine.csv")
+	res, err := LoadCSVWithOptions(writeCSV(t, badRowsCSV), LoadOptions{Mode: Lenient, QuarantinePath: quarantinePath})
+	if err != nil {
+		t.Fatalf("LoadCSVWithOptions: %v", err)
+	}
+
+	if len(res.Transactions) != 2 || res.Skipped != 2 {
+		t.Fatalf("expected 2 loaded and 2 skipped, got %d and %d", len(res.Transactions), res.Skipped)
+	}
+	if res.ColumnErrors[ColQuantity] != 1 || res.ColumnErrors[ColDate] != 1 {
+		t.Errorf("unexpected column errors: %v", res.ColumnErrors)
+	}
+
+	f, err := os.Open(quarantinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected header and 2 quarantined rows, got %d", len(rows))
+	}
+	if rows[1][0] != "3" || rows[1][1] != "Quantity" || rows[1][3] != "TX2" {
+		t.Errorf("unexpected quarantine row: %v", rows[1])
+	}
+}
+
+func TestLoadCSVChunksKeepFileOrder(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&b, "TX%d,2024-01-01,USA,Texas,Widget,%d,10,5\n", i, i)
+	}
+	b.WriteString("TXBAD,2024-01-01,USA,Texas,Widget,x,10,5\n")
+	path := writeCSV(t, b.String())
+
+	res, err := LoadCSVWithOptions(path, LoadOptions{Mode: Lenient, Workers: 4, ChunkSize: 512})
+	if err != nil {
+		t.Fatalf("LoadCSVWithOptions: %v", err)
+	}
+	if res.Stats.Chunks < 4 {
+		t.Fatalf("expected the file to be split, got %d chunks", res.Stats.Chunks)
+	}
+	if len(res.Transactions) != 500 {
+		t.Fatalf("expected 500 transactions, got %d", len(res.Transactions))
+	}
+	for i, tx := range res.Transactions {
+		if tx.Quantity != i {
+			t.Fatalf("transaction %d out of order: %+v", i, tx)
+		}
+	}
+
+	_, err = LoadCSVWithOptions(path, LoadOptions{Workers: 4, ChunkSize: 512})
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 502 {
+		t.Errorf("expected an error on line 502, got %v", err)
+	}
+}
+
+func TestLoadCSVQuotedNewlinesAcrossChunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "TX%d,2024-01-01,USA,Texas,\"Widget\nsize %d\",%d,10,5\n", i, i, i)
+	}
+	b.WriteString("TXBAD,2024-01-01,USA,Texas,Widget,x,10,5\n")
+	path := writeCSV(t, b.String())
+
+	res, err := LoadCSVWithOptions(path, LoadOptions{Mode: Lenient, Workers: 4, ChunkSize: 512})
+	if err != nil {
+		t.Fatalf("LoadCSVWithOptions: %v", err)
+	}
+	if len(res.Transactions) != 200 || res.Skipped != 1 {
+		t.Fatalf("expected 200 transactions and 1 skipped, got %d and %d", len(res.Transactions), res.Skipped)
+	}
+	for i, tx := range res.Transactions {
+		if tx.Quantity != i || tx.ProductName != fmt.Sprintf("Widget\nsize %d", i) {
+			t.Fatalf("transaction %d misparsed: %+v", i, tx)
+		}
+	}
+
+	_, err = LoadCSVWithOptions(path, LoadOptions{Workers: 4, ChunkSize: 512})
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 402 {
+		t.Errorf("expected an error on line 402, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"sync/atomic"
+
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+// DataStore holds the transactions in a columnar.Table plus per-dimension
+// indexes and the materialized dashboard aggregates. Each index holds, for
+// every dictionary code of its column, the ascending row offsets having
+// that value. It must not be modified once published through a Holder.
+type DataStore struct {
+	Table           *columnar.Table
+	Materialized    *aggregate.Set
+	ByCountry       [][]int32
+	ByProduct       [][]int32
+	ByTransactionID map[string]int32
+	ByUserID        [][]int32
+	ByRegion        [][]int32
+	ByCategory      [][]int32
+}
+
+// Holder publishes DataStore snapshots to concurrent readers. A DataStore
+// is immutable once it has been stored: updates build a new one and swap it in.
+type Holder struct {
+	p atomic.Pointer[DataStore]
+}
+
+// Load returns the latest DataStore, nil before the first Store.
+func (h *Holder) Load() *DataStore {
+	return h.p.Load()
+}
+
+// Current implements Provider.
+func (h *Holder) Current() Repository {
+	if ds := h.p.Load(); ds != nil {
+		return ds
+	}
+	return nil
+}
+
+// Store publishes ds, returning the DataStore it replaced.
+func (h *Holder) Store(ds *DataStore) *DataStore {
+	return h.p.Swap(ds)
+}
+
+// NewDataStore converts transactions to columns and indexes them.
+func NewDataStore(transactions []domain.Transaction) *DataStore {
+	return NewDataStoreFromTable(columnar.FromTransactions(transactions))
+}
+
+// NewDataStoreFromTable indexes and aggregates t, which must not be modified afterwards.
+func NewDataStoreFromTable(t *columnar.Table) *DataStore {
+	ds := &DataStore{
+		Table:           t,
+		Materialized:    aggregate.Build(t),
+		ByTransactionID: make(map[string]int32, t.Len()),
+	}
+	for i, id := range t.ID {
+		ds.ByTransactionID[id] = int32(i)
+	}
+	for _, c := range indexColumns {
+		*ds.postings(c) = buildPostings(ds.dict(c))
+	}
+	return ds
+}
+
+// buildPostings groups the rows of d by dictionary code. Counting first
+// lets every posting list be allocated at its exact size.
+func buildPostings(d *columnar.Dict) [][]int32 {
+	counts := make([]int32, d.Cardinality())
+	for _, code := range d.Codes {
+		counts[code]++
+	}
+	postings := make([][]int32, len(counts))
+	for code, n := range counts {
+		postings[code] = make([]int32, 0, n)
+	}
+	for row, code := range d.Codes {
+		postings[code] = append(postings[code], int32(row))
+	}
+	return postings
+}
+
+// dict returns the dictionary column behind c, nil for the other columns.
+func (ds *DataStore) dict(c Column) *columnar.Dict {
+	switch c {
+	case ColUserID:
+		return ds.Table.UserID
+	case ColCountry:
+		return ds.Table.Country
+	case ColRegion:
+		return ds.Table.Region
+	case ColProductID:
+		return ds.Table.ProductID
+	case ColProductName:
+		return ds.Table.ProductName
+	case ColCategory:
+		return ds.Table.Category
+	}
+	return nil
+}
+
+// postings returns the index field for column c, nil if c isn't indexed.
+func (ds *DataStore) postings(c Column) *[][]int32 {
+	switch c {
+	case ColCountry:
+		return &ds.ByCountry
+	case ColRegion:
+		return &ds.ByRegion
+	case ColCategory:
+		return &ds.ByCategory
+	case ColProductID:
+		return &ds.ByProduct
+	case ColUserID:
+		return &ds.ByUserID
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"Dashlytics/internal/domain"
+)
+
+func generation(n int) []domain.Transaction {
+	txs := make([]domain.Transaction, n)
+	for i := range txs {
+		txs[i] = domain.Transaction{ID: fmt.Sprintf("TX%d", i), Country: "USA", Quantity: n}
+	}
+	return txs
+}
+
+// Run with -race: readers must always see a complete DataStore while it is being replaced.
+func TestHolderConcurrentLoadAndStore(t *testing.T) {
+	var h Holder
+	h.Store(NewDataStore(generation(1)))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				ds := h.Load()
+				n := ds.Len()
+				if len(ds.ByDimension(ColCountry, "USA")) != n || len(ds.ByTransactionID) != n {
+					t.Errorf("inconsistent DataStore: %d rows, %d indexed", n, len(ds.ByDimension(ColCountry, "USA")))
+					return
+				}
+				for _, q := range ds.Table.Quantity {
+					if int(q) != n {
+						t.Errorf("row from another generation: quantity %d in generation %d", q, n)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for gen := 2; gen <= 50; gen++ {
+		old := h.Store(NewDataStore(generation(gen)))
+		if old.Len() != gen-1 {
+			t.Errorf("Store returned generation %d, want %d", old.Len(), gen-1)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestNewDataStoreIndexesRowOffsets(t *testing.T) {
+	ds := NewDataStore(snapshotTransactions())
+
+	if rows := ds.ByDimension(ColCountry, "USA"); len(rows) != 2 || rows[0] != 0 || rows[1] != 2 {
+		t.Errorf("expected USA at rows 0 and 2, got %v", rows)
+	}
+	if rows := ds.ByDimension(ColProductID, "P2"); len(rows) != 1 || ds.Row(rows[0]).ID != "TX2" {
+		t.Errorf("expected P2 to point at TX2, got %v", rows)
+	}
+	if tx, ok := ds.Lookup("TX3"); !ok || tx.Region != "Ohio" {
+		t.Errorf("expected to find TX3, got %+v, %v", tx, ok)
+	}
+	if rows := ds.ByDimension(ColProductName, "Widget"); rows != nil {
+		t.Errorf("ProductName is not indexed, got %v", rows)
+	}
+}
+
+func TestMemoryReport(t *testing.T) {
+	report := NewDataStore(snapshotTransactions()).MemoryReport()
+
+	if report.Rows != 3 || len(report.Columns) != 13 || len(report.Indexes) != 6 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	var names []string
+	for _, c := range report.Columns {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names[len(names)-6:], ","); got != "Date,AddedDate,Quantity,Stock,Price,TotalPrice" {
+		t.Errorf("numeric columns in order %s", got)
+	}
+	for _, idx := range report.Indexes {
+		if idx.Entries != 3 {
+			t.Errorf("%s: expected 3 entries, got %d", idx.Name, idx.Entries)
+		}
+		if idx.Bytes >= idx.BytesAsCopies {
+			t.Errorf("%s: row offsets (%d bytes) should be smaller than copies (%d bytes)", idx.Name, idx.Bytes, idx.BytesAsCopies)
+		}
+	}
+}
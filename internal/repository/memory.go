@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"runtime"
+	"unsafe"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+// Approximate sizes used by the memory report. Map overhead is estimated
+// per entry.
+const (
+	stringHeaderBytes = int64(unsafe.Sizeof(""))
+	sliceHeaderBytes  = int64(unsafe.Sizeof([]int32(nil)))
+	mapEntryOverhead  = 8
+	transactionBytes  = int64(unsafe.Sizeof(domain.Transaction{}))
+	rowOffsetBytes    = int64(unsafe.Sizeof(int32(0)))
+)
+
+// ColumnMemory estimates the footprint of one column.
+type ColumnMemory struct {
+	Name string `json:"name"`
+	// Distinct is the dictionary size of dictionary-encoded columns.
+	Distinct int   `json:"distinct,omitempty"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// IndexMemory estimates the footprint of one index.
+type IndexMemory struct {
+	Name    string `json:"name"`
+	Keys    int    `json:"keys"`
+	Entries int    `json:"entries"`
+	// Bytes is the size with row offsets, BytesAsCopies what the same index
+	// took when it held a copy of every Transaction.
+	Bytes         int64 `json:"bytes"`
+	BytesAsCopies int64 `json:"bytes_as_copies"`
+}
+
+// MemoryReport estimates how much memory a DataStore holds.
+type MemoryReport struct {
+	Rows    int            `json:"rows"`
+	Columns []ColumnMemory `json:"columns"`
+	// ColumnBytes is the size of the columnar table, RowBytes what the same
+	// rows take as a []domain.Transaction (struct only, strings not counted).
+	ColumnBytes        int64         `json:"column_bytes"`
+	RowBytes           int64         `json:"row_bytes"`
+	Indexes            []IndexMemory `json:"indexes"`
+	IndexBytes         int64         `json:"index_bytes"`
+	IndexBytesAsCopies int64         `json:"index_bytes_as_copies"`
+	// HeapAllocBytes is the Go heap of the whole process, for comparison.
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+}
+
+func stringsBytes(values []string) int64 {
+	n := int64(cap(values)) * stringHeaderBytes
+	for _, v := range values {
+		n += int64(len(v))
+	}
+	return n
+}
+
+func dictBytes(d *columnar.Dict) int64 {
+	return stringsBytes(d.Values) + int64(cap(d.Codes))*4 +
+		int64(len(d.Values))*(stringHeaderBytes+4+mapEntryOverhead)
+}
+
+// MemoryReport estimates the bytes held by each column and index.
+func (ds *DataStore) MemoryReport() MemoryReport {
+	t := ds.Table
+	report := MemoryReport{
+		Rows:     t.Len(),
+		RowBytes: int64(t.Len()) * transactionBytes,
+	}
+
+	addColumn := func(m ColumnMemory) {
+		report.Columns = append(report.Columns, m)
+		report.ColumnBytes += m.Bytes
+	}
+	addColumn(ColumnMemory{Name: string(ColID), Bytes: stringsBytes(t.ID)})
+	for _, c := range dictColumns {
+		d := ds.dict(c)
+		addColumn(ColumnMemory{Name: string(c), Distinct: d.Cardinality(), Bytes: dictBytes(d)})
+	}
+	for _, c := range []struct {
+		name Column
+		col  []int32
+	}{{ColDate, t.Date}, {ColAddedDate, t.AddedDate}, {ColQuantity, t.Quantity}, {ColStock, t.Stock}} {
+		addColumn(ColumnMemory{Name: string(c.name), Bytes: int64(cap(c.col)) * 4})
+	}
+	for _, c := range []struct {
+		name Column
+		col  []float64
+	}{{ColPrice, t.Price}, {ColTotalPrice, t.TotalPrice}} {
+		addColumn(ColumnMemory{Name: string(c.name), Bytes: int64(cap(c.col)) * 8})
+	}
+
+	addIndex := func(m IndexMemory) {
+		report.Indexes = append(report.Indexes, m)
+		report.IndexBytes += m.Bytes
+		report.IndexBytesAsCopies += m.BytesAsCopies
+	}
+	for _, c := range indexColumns {
+		m := IndexMemory{Name: "By" + string(c)}
+		perKey := stringHeaderBytes + sliceHeaderBytes + mapEntryOverhead
+		for _, rows := range *ds.postings(c) {
+			m.Keys++
+			m.Entries += len(rows)
+			m.Bytes += sliceHeaderBytes + int64(cap(rows))*rowOffsetBytes
+			m.BytesAsCopies += perKey + int64(len(rows))*transactionBytes
+		}
+		addIndex(m)
+	}
+	n := int64(len(ds.ByTransactionID))
+	addIndex(IndexMemory{
+		Name:          "ByTransactionID",
+		Keys:          len(ds.ByTransactionID),
+		Entries:       len(ds.ByTransactionID),
+		Bytes:         n * (stringHeaderBytes + rowOffsetBytes + mapEntryOverhead),
+		BytesAsCopies: n * (stringHeaderBytes + transactionBytes + mapEntryOverhead),
+	})
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	report.HeapAllocBytes = ms.HeapAlloc
+	return report
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"Dashlytics/internal/domain"
+)
+
+const (
+	minChunkSize = 1 << 20
+	// chunksPerWorker keeps workers busy when some ranges parse slower than others.
+	chunksPerWorker = 4
+)
+
+// byteRange is a half-open [start, end) slice of the file holding whole lines.
+type byteRange struct {
+	start, end int64
+}
+
+type rejectedRow struct {
+	err    *RowError
+	record []string
+}
+
+// chunkResult is what a worker produced for one byteRange. Line numbers in
+// rejected are relative to the start of the chunk until mergeChunks fixes them.
+type chunkResult struct {
+	transactions []domain.Transaction
+	rejected     []rejectedRow
+	lines        int
+	quotes       int // in the whole range, even past a row that stopped the parse
+	err          error
+}
+
+// readHeader parses the first line of the file and returns the offset where the body starts.
+func readHeader(file *os.File) ([]string, int64, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(file, 0, 1<<62)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	header, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("read CSV header: %w", err)
+	}
+	return header, int64(len(line)), nil
+}
+
+func defaultChunkSize(bodySize int64, workers int) int64 {
+	size := bodySize / int64(workers*chunksPerWorker)
+	if size < minChunkSize {
+		size = minChunkSize
+	}
+	return size
+}
+
+// splitChunks cuts [start, end) into ranges of roughly chunkSize bytes,
+// moving every cut forward to just past the next newline.
+func splitChunks(file *os.File, start, end, chunkSize int64) ([]byteRange, error) {
+	var chunks []byteRange
+	buf := make([]byte, 4096)
+	for start < end {
+		cut := start + chunkSize
+		if cut >= end {
+			chunks = append(chunks, byteRange{start, end})
+			break
+		}
+		for {
+			n, err := file.ReadAt(buf, cut)
+			if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+				cut += int64(i) + 1
+				break
+			}
+			cut += int64(n)
+			if err == io.EOF || cut >= end {
+				cut = end
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		chunks = append(chunks, byteRange{start, cut})
+		start = cut
+	}
+	return chunks, nil
+}
+
+// parseChunks runs parseChunk over every range on a pool of workers. In
+// Strict mode, ranges after the first failing one are not parsed since
+// their rows would be thrown away anyway.
+func parseChunks(file *os.File, chunks []byteRange, workers, fields int, cols columnIndex, mode ParseMode) []chunkResult {
+	results := make([]chunkResult, len(chunks))
+	var firstFailed atomic.Int64
+	firstFailed.Store(int64(len(chunks)))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if mode == Strict && int64(i) > firstFailed.Load() {
+					continue
+				}
+				results[i] = parseChunk(file, chunks[i], fields, cols, mode)
+				if results[i].err != nil || (mode == Strict && len(results[i].rejected) > 0) {
+					for {
+						cur := firstFailed.Load()
+						if int64(i) >= cur || firstFailed.CompareAndSwap(cur, int64(i)) {
+							break
+						}
+					}
+				}
+			}
+		}()
+	}
+	for i := range chunks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// splitsQuotedField reports whether a cut between the ranges fell inside a
+// quoted field, which newlines may be part of: an odd number of quotes
+// precede such a cut. Stray quotes in unquoted fields can raise false
+// alarms, which only cost a sequential parse.
+func splitsQuotedField(results []chunkResult) bool {
+	quotes := 0
+	for i := 1; i < len(results); i++ {
+		quotes += results[i-1].quotes
+		if quotes%2 == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// lineCounter counts the newlines and quotes read through it.
+type lineCounter struct {
+	r      io.Reader
+	lines  int
+	quotes int
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.lines += bytes.Count(p[:n], []byte{'\n'})
+	c.quotes += bytes.Count(p[:n], []byte{'"'})
+	return n, err
+}
+
+func parseChunk(file *os.File, chunk byteRange, fields int, cols columnIndex, mode ParseMode) chunkResult {
+	counter := &lineCounter{r: io.NewSectionReader(file, chunk.start, chunk.end-chunk.start)}
+	reader := csv.NewReader(bufio.NewReaderSize(counter, 256<<10))
+	reader.FieldsPerRecord = fields
+	reader.ReuseRecord = true
+
+	var res chunkResult
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if err != nil {
+			// malformed rows (bad quoting, wrong field count) have no single column to blame
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				res.err = err
+				return res
+			}
+			rowErr = &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		} else {
+			var t domain.Transaction
+			line, _ := reader.FieldPos(0)
+			if t, rowErr = parseRecord(record, cols, line); rowErr == nil {
+				res.transactions = append(res.transactions, t)
+				continue
+			}
+		}
+
+		res.rejected = append(res.rejected, rejectedRow{err: rowErr, record: append([]string(nil), record...)})
+		if mode == Strict {
+			break
+		}
+	}
+	// the rest of the range still counts towards splitsQuotedField
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		res.err = err
+	}
+	res.lines, res.quotes = counter.lines, counter.quotes
+	return res
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RowError describes why a CSV row could not be turned into a Transaction.
+type RowError struct {
+	Line   int
+	Column Column // empty when the row itself is malformed
+	Value  string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %s: invalid value %q: %v", e.Line, e.Column, e.Value, e.reason())
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// reason drops the strconv prefix, which only repeats the value.
+func (e *RowError) reason() error {
+	var numErr *strconv.NumError
+	if errors.As(e.Err, &numErr) {
+		return numErr.Err
+	}
+	return e.Err
+}
+
+// ErrorSummary renders ColumnErrors as "Price: 3, Date: 1", largest first.
+func (r *LoadResult) ErrorSummary() string {
+	cols := make([]Column, 0, len(r.ColumnErrors))
+	for c := range r.ColumnErrors {
+		cols = append(cols, c)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		if r.ColumnErrors[cols[i]] != r.ColumnErrors[cols[j]] {
+			return r.ColumnErrors[cols[i]] > r.ColumnErrors[cols[j]]
+		}
+		return cols[i] < cols[j]
+	})
+
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		name := string(c)
+		if name == "" {
+			name = "malformed row"
+		}
+		parts[i] = fmt.Sprintf("%s: %d", name, r.ColumnErrors[c])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quarantineWriter copies rejected rows to a CSV file, prefixed with the
+// source line, the offending column and the reason.
+type quarantineWriter struct {
+	file *os.File
+	w    *csv.Writer
+}
+
+func newQuarantineWriter(path string, header []string) (*quarantineWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create quarantine file: %w", err)
+	}
+	q := &quarantineWriter{file: file, w: csv.NewWriter(file)}
+	if err := q.w.Write(append([]string{"line", "column", "reason"}, header...)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *quarantineWriter) Write(rowErr *RowError, record []string) error {
+	reason := rowErr.Err.Error()
+	if rowErr.Column != "" {
+		reason = fmt.Sprintf("invalid value %q: %v", rowErr.Value, rowErr.reason())
+	}
+	row := append([]string{strconv.Itoa(rowErr.Line), string(rowErr.Column), reason}, record...)
+	return q.w.Write(row)
+}
+
+// Close flushes the file, it is safe to call more than once.
+func (q *quarantineWriter) Close() error {
+	if q.file == nil {
+		return nil
+	}
+	q.w.Flush()
+	err := q.w.Error()
+	if cerr := q.file.Close(); err == nil {
+		err = cerr
+	}
+	q.file = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+// Repository is the read access the HTTP layer needs to a set of transactions.
+// Slices and pointers handed out by a Repository are shared and must be treated as read-only.
+type Repository interface {
+	// Len is the number of transactions.
+	Len() int
+	// Columns exposes the transactions column by column, aggregations should
+	// read the columns they need from it rather than materializing rows.
+	Columns() *columnar.Table
+	// Aggregates returns the dashboard aggregates, computed once per dataset.
+	Aggregates() *aggregate.Set
+	// Scan calls fn for every transaction in load order until fn returns false.
+	// tx is only valid during the call.
+	Scan(fn func(tx *domain.Transaction) bool)
+	// Lookup finds a transaction by ID.
+	Lookup(id string) (domain.Transaction, bool)
+	// ByDimension returns the ascending row offsets of the transactions whose
+	// column c equals value. Country, Region, Category, ProductID and UserID are indexed.
+	ByDimension(c Column, value string) []int32
+	// Row returns the transaction at a row offset.
+	Row(i int32) domain.Transaction
+}
+
+// Provider hands out the Repository to use for one request. Callers fetch it
+// once per request so a concurrent reload never changes data under them.
+type Provider interface {
+	Current() Repository
+}
+
+// Static is a Provider that always returns the same Repository.
+func Static(repo Repository) Provider {
+	return staticProvider{repo}
+}
+
+type staticProvider struct {
+	repo Repository
+}
+
+func (p staticProvider) Current() Repository { return p.repo }
+
+func (ds *DataStore) Len() int {
+	return ds.Table.Len()
+}
+
+func (ds *DataStore) Columns() *columnar.Table {
+	return ds.Table
+}
+
+func (ds *DataStore) Aggregates() *aggregate.Set {
+	return ds.Materialized
+}
+
+func (ds *DataStore) Scan(fn func(tx *domain.Transaction) bool) {
+	var tx domain.Transaction
+	for i := 0; i < ds.Table.Len(); i++ {
+		ds.Table.Fill(i, &tx)
+		if !fn(&tx) {
+			return
+		}
+	}
+}
+
+func (ds *DataStore) Lookup(id string) (domain.Transaction, bool) {
+	row, ok := ds.ByTransactionID[id]
+	if !ok {
+		return domain.Transaction{}, false
+	}
+	return ds.Table.Row(int(row)), true
+}
+
+func (ds *DataStore) ByDimension(c Column, value string) []int32 {
+	postings, d := ds.postings(c), ds.dict(c)
+	if postings == nil {
+		return nil
+	}
+	code, ok := d.Code(value)
+	if !ok {
+		return nil
+	}
+	return (*postings)[code]
+}
+
+func (ds *DataStore) Row(i int32) domain.Transaction {
+	return ds.Table.Row(int(i))
+}
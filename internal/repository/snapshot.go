@@ -0,0 +1,422 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Dashlytics/internal/aggregate"
+	"Dashlytics/internal/columnar"
+)
+
+// Snapshot file layout, all integers little endian or varint encoded:
+//
+//	magic "DLYSNAP\x00" | version uint32 | origin | payload | crc32c(origin, payload) uint32
+//
+// The origin is the SnapshotOrigin the snapshot was built from. The payload mirrors columnar.Table: the row count, the IDs, every
+// dictionary column as its values followed by one code per row, then the
+// date (days since the Unix epoch) and numeric columns one after the other.
+// It ends with each dimension index as delta-encoded row offsets per
+// dictionary code.
+const (
+	snapshotMagic   = "DLYSNAP\x00"
+	SnapshotVersion = 3
+)
+
+// maxSnapshotRows is the most rows the int32 indexes can address.
+const maxSnapshotRows = math.MaxInt32
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrSnapshotChecksum is returned when a snapshot's payload doesn't match its checksum.
+var ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+
+// dictColumns are stored as a dictionary plus one code per row, in this order.
+var dictColumns = []Column{ColUserID, ColCountry, ColRegion, ColProductID, ColProductName, ColCategory}
+
+// indexColumns are the DataStore dimension indexes written to the snapshot.
+var indexColumns = []Column{ColCountry, ColProductID, ColUserID, ColRegion, ColCategory}
+
+// SnapshotPath is where the snapshot of csvPath lives by default.
+func SnapshotPath(csvPath string) string {
+	return strings.TrimSuffix(csvPath, ".csv") + ".snapshot"
+}
+
+// ErrSnapshotStale is returned when a snapshot was built from another
+// version of the CSV file or with other load options.
+var ErrSnapshotStale = errors.New("snapshot is stale")
+
+// SnapshotOrigin identifies what a snapshot was built from: the CSV file
+// and the load options that decide which rows it holds.
+type SnapshotOrigin struct {
+	Path    string // absolute
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+	Options string
+}
+
+// mismatch names the first field of o differing from want, empty when they
+// match. The file is not compared when want has no path, e.g. because the
+// CSV is gone and the snapshot is all there is.
+func (o SnapshotOrigin) mismatch(want SnapshotOrigin) string {
+	switch {
+	case want.Path != "" && o.Path != want.Path:
+		return fmt.Sprintf("built from %s, not %s", o.Path, want.Path)
+	case want.Path != "" && (o.Size != want.Size || o.ModTime != want.ModTime):
+		return "the CSV file changed since"
+	case o.Options != want.Options:
+		return fmt.Sprintf("built with options %q, not %q", o.Options, want.Options)
+	}
+	return ""
+}
+
+// optionsKey encodes the load options that change the rows: the parse mode
+// and the column mapping.
+func optionsKey(opts LoadOptions) string {
+	mapping := opts.Mapping
+	if mapping == nil {
+		mapping = DefaultColumnMapping
+	}
+	pairs := make([]string, 0, len(mapping))
+	for name, c := range mapping {
+		pairs = append(pairs, name+"="+string(c))
+	}
+	sort.Strings(pairs)
+	mode := "strict"
+	if opts.Mode == Lenient {
+		mode = "lenient"
+	}
+	return mode + ";" + strings.Join(pairs, ",")
+}
+
+// WriteSnapshot stores ds in path, recording origin. The file is written next to path and
+// renamed into place so a crash never leaves a truncated snapshot behind.
+func WriteSnapshot(path string, ds *DataStore, origin SnapshotOrigin) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeSnapshot(tmp, ds, origin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeSnapshot(w io.Writer, ds *DataStore, origin SnapshotOrigin) error {
+	bw := bufio.NewWriterSize(w, 1<<20)
+	bw.WriteString(snapshotMagic)
+	binary.Write(bw, binary.LittleEndian, uint32(SnapshotVersion))
+
+	sw := &snapshotWriter{w: bw, crc: crc32.New(crcTable)}
+	sw.string(origin.Path)
+	sw.varint(origin.Size)
+	sw.varint(origin.ModTime)
+	sw.string(origin.Options)
+
+	t := ds.Table
+	sw.uvarint(uint64(t.Len()))
+
+	for _, id := range t.ID {
+		sw.string(id)
+	}
+	for _, c := range dictColumns {
+		d := ds.dict(c)
+		sw.uvarint(uint64(len(d.Values)))
+		for _, v := range d.Values {
+			sw.string(v)
+		}
+		for _, code := range d.Codes {
+			sw.uvarint(uint64(code))
+		}
+	}
+	for _, col := range [][]int32{t.Date, t.AddedDate, t.Quantity, t.Stock} {
+		for _, v := range col {
+			sw.varint(int64(v))
+		}
+	}
+	for _, col := range [][]float64{t.Price, t.TotalPrice} {
+		for _, v := range col {
+			sw.float(v)
+		}
+	}
+
+	sw.uvarint(uint64(len(indexColumns)))
+	for _, c := range indexColumns {
+		sw.string(string(c))
+		for _, rows := range *ds.postings(c) {
+			sw.uvarint(uint64(len(rows)))
+			prev := int32(0)
+			for _, row := range rows {
+				sw.uvarint(uint64(row - prev))
+				prev = row
+			}
+		}
+	}
+
+	if sw.err != nil {
+		return sw.err
+	}
+	binary.Write(bw, binary.LittleEndian, sw.crc.Sum32())
+	return bw.Flush()
+}
+
+// ReadSnapshot loads a DataStore written by WriteSnapshot, failing with
+// ErrSnapshotStale unless it was built from want. The checksum is verified
+// before decoding so a damaged file is always reported as such.
+func ReadSnapshot(path string, want SnapshotOrigin) (*DataStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(file, 1<<20)
+	var head [len(snapshotMagic) + 4]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return nil, fmt.Errorf("read snapshot header: %w", err)
+	}
+	if string(head[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("not a Dashlytics snapshot")
+	}
+	if v := binary.LittleEndian.Uint32(head[len(snapshotMagic):]); v != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (want %d)", v, SnapshotVersion)
+	}
+	payloadSize := info.Size() - int64(len(head)) - 4
+	if payloadSize < 0 {
+		return nil, ErrSnapshotChecksum
+	}
+
+	// a stale snapshot is rejected without reading the rest
+	sr := &snapshotReader{r: br}
+	origin := SnapshotOrigin{Path: sr.string(), Size: sr.varint(), ModTime: sr.varint(), Options: sr.string()}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	if diff := origin.mismatch(want); diff != "" {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotStale, diff)
+	}
+
+	crc := crc32.New(crcTable)
+	if _, err := io.Copy(crc, io.NewSectionReader(file, int64(len(head)), payloadSize)); err != nil {
+		return nil, err
+	}
+	var trailer [4]byte
+	if _, err := file.ReadAt(trailer[:], int64(len(head))+payloadSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(trailer[:]) != crc.Sum32() {
+		return nil, ErrSnapshotChecksum
+	}
+	return readPayload(sr)
+}
+
+func readPayload(sr *snapshotReader) (*DataStore, error) {
+	n := sr.uvarint()
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	if n > maxSnapshotRows {
+		return nil, fmt.Errorf("snapshot claims %d rows", n)
+	}
+
+	t := &columnar.Table{ID: make([]string, n)}
+	for i := range t.ID {
+		t.ID[i] = sr.string()
+	}
+	dicts := map[Column]**columnar.Dict{
+		ColUserID: &t.UserID, ColCountry: &t.Country, ColRegion: &t.Region,
+		ColProductID: &t.ProductID, ColProductName: &t.ProductName, ColCategory: &t.Category,
+	}
+	for _, c := range dictColumns {
+		size := sr.uvarint()
+		if size > n+1 {
+			return nil, fmt.Errorf("snapshot column %s: dictionary of %d entries for %d rows", c, size, n)
+		}
+		values := make([]string, size)
+		for i := range values {
+			values[i] = sr.string()
+		}
+		codes := make([]uint32, n)
+		for i := range codes {
+			codes[i] = uint32(sr.uvarint())
+			if codes[i] >= uint32(size) {
+				sr.fail(fmt.Errorf("snapshot column %s: code %d out of range", c, codes[i]))
+				break
+			}
+		}
+		if sr.err != nil {
+			return nil, sr.err
+		}
+		*dicts[c] = columnar.NewDict(values, codes)
+	}
+	for _, col := range []*[]int32{&t.Date, &t.AddedDate, &t.Quantity, &t.Stock} {
+		*col = make([]int32, n)
+		for i := range *col {
+			(*col)[i] = int32(sr.varint())
+		}
+	}
+	for _, col := range []*[]float64{&t.Price, &t.TotalPrice} {
+		*col = make([]float64, n)
+		for i := range *col {
+			(*col)[i] = sr.float()
+		}
+	}
+
+	ds := &DataStore{Table: t, Materialized: aggregate.Build(t), ByTransactionID: make(map[string]int32, n)}
+	for i, id := range t.ID {
+		ds.ByTransactionID[id] = int32(i)
+	}
+	for k := sr.uvarint(); k > 0 && sr.err == nil; k-- {
+		c := Column(sr.string())
+		postings := ds.postings(c)
+		if postings == nil {
+			return nil, fmt.Errorf("snapshot has unknown index %q", c)
+		}
+		*postings = make([][]int32, ds.dict(c).Cardinality())
+		for code := range *postings {
+			count := sr.uvarint()
+			if count > n {
+				return nil, fmt.Errorf("snapshot index %s: %d rows for code %d", c, count, code)
+			}
+			rows := make([]int32, count)
+			row := uint64(0)
+			for i := range rows {
+				row += sr.uvarint()
+				if row >= n {
+					return nil, fmt.Errorf("snapshot index %s: row %d out of range", c, row)
+				}
+				rows[i] = int32(row)
+			}
+			(*postings)[code] = rows
+		}
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	for _, c := range indexColumns {
+		if *ds.postings(c) == nil {
+			*ds.postings(c) = buildPostings(ds.dict(c))
+		}
+	}
+	return ds, nil
+}
+
+// snapshotWriter encodes values into the payload, keeping the first error.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (s *snapshotWriter) write(p []byte) {
+	if s.err != nil {
+		return
+	}
+	s.crc.Write(p)
+	_, s.err = s.w.Write(p)
+}
+
+func (s *snapshotWriter) uvarint(v uint64) { s.write(s.buf[:binary.PutUvarint(s.buf[:], v)]) }
+func (s *snapshotWriter) varint(v int64)   { s.write(s.buf[:binary.PutVarint(s.buf[:], v)]) }
+
+func (s *snapshotWriter) float(f float64) {
+	binary.LittleEndian.PutUint64(s.buf[:8], math.Float64bits(f))
+	s.write(s.buf[:8])
+}
+
+func (s *snapshotWriter) string(v string) {
+	s.uvarint(uint64(len(v)))
+	s.write([]byte(v))
+}
+
+// snapshotReader decodes the payload, keeping the first error and returning zero values after it.
+type snapshotReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+	err error
+}
+
+func (s *snapshotReader) fail(err error) {
+	if s.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		s.err = fmt.Errorf("read snapshot: %w", err)
+	}
+}
+
+func (s *snapshotReader) uvarint() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		s.fail(err)
+	}
+	return v
+}
+
+func (s *snapshotReader) varint() int64 {
+	if s.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(s.r)
+	if err != nil {
+		s.fail(err)
+	}
+	return v
+}
+
+func (s *snapshotReader) read(p []byte) {
+	if s.err != nil {
+		return
+	}
+	if _, err := io.ReadFull(s.r, p); err != nil {
+		s.fail(err)
+	}
+}
+
+func (s *snapshotReader) float() float64 {
+	s.read(s.buf[:8])
+	if s.err != nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(s.buf[:8]))
+}
+
+func (s *snapshotReader) string() string {
+	n := s.uvarint()
+	if s.err != nil {
+		return ""
+	}
+	if n > 1<<20 {
+		s.fail(fmt.Errorf("string length %d too large", n))
+		return ""
+	}
+	p := make([]byte, n)
+	s.read(p)
+	return string(p)
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/domain"
+)
+
+func snapshotTransactions() []domain.Transaction {
+	date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	return []domain.Transaction{
+		{ID: "TX1", Date: date, UserID: "U1", Country: "USA", Region: "Texas", ProductID: "P1", ProductName: "Widget", Category: "Tools", Price: 9.99, Quantity: 2, TotalPrice: 19.98, Stock: 40, AddedDate: date.AddDate(-1, 0, 0)},
+		{ID: "TX2", Date: date.AddDate(0, 1, 0), UserID: "U2", Country: "Canada", Region: "Ontario", ProductID: "P2", ProductName: "Gadget", Category: "Toys", Price: 5, Quantity: 1, TotalPrice: 5, Stock: 3},
+		{ID: "TX3", Date: date, UserID: "U1", Country: "USA", Region: "Ohio", ProductID: "P1", ProductName: "Widget", Category: "Tools", Price: 9.99, Quantity: 1, TotalPrice: 9.99, Stock: 39},
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	want := NewDataStore(snapshotTransactions())
+	path := filepath.Join(t.TempDir(), "data.snapshot")
+	if err := WriteSnapshot(path, want, SnapshotOrigin{}); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+
+	got, err := ReadSnapshot(path, SnapshotOrigin{})
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot round trip mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSnapshotDetectsCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.snapshot")
+	if err := WriteSnapshot(path, NewDataStore(snapshotTransactions()), SnapshotOrigin{}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-20] ^= 0xff
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSnapshot(path, SnapshotOrigin{}); !errors.Is(err, ErrSnapshotChecksum) {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+}
+
+func TestSourcePrefersFreshSnapshot(t *testing.T) {
+	csvPath := writeCSV(t, "ID,Date,Country,Region,ProductName,Quantity,TotalPrice,Stock\nTX1,2024-01-01,USA,Texas,Widget,1,10,5\n")
+	src := Source{CSVPath: csvPath, SnapshotPath: SnapshotPath(csvPath)}
+
+	if _, report, err := src.Load(); err != nil || report.FromSnapshot || report.SnapshotErr != nil {
+		t.Fatalf("expected a CSV load without a snapshot, got %v, %v", report, err)
+	}
+	origin, err := src.Origin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSnapshot(src.SnapshotPath, NewDataStore(snapshotTransactions()), origin); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, report, err := src.Load()
+	if err != nil || !report.FromSnapshot || ds.Len() != 3 {
+		t.Errorf("expected the snapshot to be used, got %v, %v", report, err)
+	}
+
+	// other options pick other rows
+	lenient := src
+	lenient.Options.Mode = Lenient
+	if _, report, err := lenient.Load(); err != nil || report.FromSnapshot || !errors.Is(report.SnapshotErr, ErrSnapshotStale) {
+		t.Errorf("expected the snapshot to be rejected in lenient mode, got %v, %v", report, err)
+	}
+
+	// even an older modification time means another file
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(csvPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	ds, report, err = src.Load()
+	if err != nil || report.FromSnapshot || ds.Len() != 1 || !errors.Is(report.SnapshotErr, ErrSnapshotStale) {
+		t.Errorf("expected the CSV to be parsed once it changed, got %v, %v", report, err)
+	}
+
+	// without the CSV the snapshot is all there is
+	if err := os.Remove(csvPath); err != nil {
+		t.Fatal(err)
+	}
+	if ds, report, err := src.Load(); err != nil || !report.FromSnapshot || ds.Len() != 3 {
+		t.Errorf("expected the snapshot to be used without the CSV, got %v, %v", report, err)
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Source describes where a DataStore is loaded from: the snapshot when it
+// was built from the current CSV file with the same options, the CSV file
+// otherwise.
+type Source struct {
+	CSVPath string
+	// SnapshotPath is checked before parsing the CSV, snapshots are not used when empty.
+	SnapshotPath string
+	Options      LoadOptions
+}
+
+// LoadReport says how a DataStore was produced.
+type LoadReport struct {
+	FromSnapshot bool
+	// CSV is the loader result, nil when the snapshot was used.
+	CSV      *LoadResult
+	Rows     int
+	Duration time.Duration
+	// SnapshotErr is set when a fresh snapshot could not be read and the CSV was parsed instead.
+	SnapshotErr error
+}
+
+func (r LoadReport) String() string {
+	if r.FromSnapshot {
+		return fmt.Sprintf("%d transactions from snapshot in %s", r.Rows, r.Duration.Round(time.Millisecond))
+	}
+	s := fmt.Sprintf("%d transactions from CSV in %s (%s)", r.Rows, r.Duration.Round(time.Millisecond), r.CSV.Stats)
+	if r.CSV.Skipped > 0 {
+		s += fmt.Sprintf(", skipped %d invalid rows (%s)", r.CSV.Skipped, r.CSV.ErrorSummary())
+	}
+	return s
+}
+
+// Load builds a DataStore from the source.
+func (s Source) Load() (*DataStore, LoadReport, error) {
+	start := time.Now()
+	var report LoadReport
+	if s.SnapshotPath != "" {
+		ds, err := s.readSnapshot()
+		if err == nil {
+			report = LoadReport{FromSnapshot: true, Rows: ds.Len(), Duration: time.Since(start)}
+			return ds, report, nil
+		}
+		// a missing snapshot is not worth reporting
+		if !errors.Is(err, fs.ErrNotExist) {
+			report.SnapshotErr = fmt.Errorf("snapshot %s: %w", s.SnapshotPath, err)
+		}
+	}
+
+	result, err := LoadCSVWithOptions(s.CSVPath, s.Options)
+	if err != nil {
+		return nil, report, fmt.Errorf("load %s: %w", s.CSVPath, err)
+	}
+	if len(result.Transactions) > math.MaxInt32 {
+		return nil, report, fmt.Errorf("load %s: %d rows is more than the indexes can address", s.CSVPath, len(result.Transactions))
+	}
+	ds := NewDataStore(result.Transactions)
+	report.CSV = result
+	report.Rows = len(result.Transactions)
+	report.Duration = time.Since(start)
+	return ds, report, nil
+}
+
+// Origin describes the CSV file as it is now with the source's options, for
+// recording in a snapshot.
+func (s Source) Origin() (SnapshotOrigin, error) {
+	path, err := filepath.Abs(s.CSVPath)
+	if err != nil {
+		return SnapshotOrigin{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return SnapshotOrigin{}, err
+	}
+	return SnapshotOrigin{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano(), Options: optionsKey(s.Options)}, nil
+}
+
+// readSnapshot reads the snapshot if it was built from the CSV file as it
+// is now. Without the CSV file, the snapshot is all there is and only the
+// options are checked.
+func (s Source) readSnapshot() (*DataStore, error) {
+	want, err := s.Origin()
+	if errors.Is(err, fs.ErrNotExist) {
+		want, err = SnapshotOrigin{Options: optionsKey(s.Options)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ReadSnapshot(s.SnapshotPath, want)
+}
@@ -0,0 +1,232 @@
+// Package rfm scores users by the Recency, Frequency and Monetary value of
+// their purchases and sorts them into named segments.
+package rfm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"Dashlytics/internal/columnar"
+)
+
+// Segment names a group of customers.
+type Segment string
+
+// The segments, from the best customers to the ones gone. They split the
+// plane of recency score against the average of frequency and monetary
+// score, both on a scale of 1 to 5.
+const (
+	Champions          Segment = "champions"
+	LoyalCustomers     Segment = "loyal_customers"
+	PotentialLoyalists Segment = "potential_loyalists"
+	NewCustomers       Segment = "new_customers"
+	Promising          Segment = "promising"
+	NeedAttention      Segment = "need_attention"
+	AboutToSleep       Segment = "about_to_sleep"
+	CantLoseThem       Segment = "cant_lose_them"
+	AtRisk             Segment = "at_risk"
+	Hibernating        Segment = "hibernating"
+	Lost               Segment = "lost"
+)
+
+// Segments lists every Segment in the order above.
+var Segments = []Segment{
+	Champions, LoyalCustomers, PotentialLoyalists, NewCustomers, Promising,
+	NeedAttention, AboutToSleep, CantLoseThem, AtRisk, Hibernating, Lost,
+}
+
+var names = map[Segment]string{
+	Champions:          "Champions",
+	LoyalCustomers:     "Loyal Customers",
+	PotentialLoyalists: "Potential Loyalists",
+	NewCustomers:       "New Customers",
+	Promising:          "Promising",
+	NeedAttention:      "Need Attention",
+	AboutToSleep:       "About to Sleep",
+	CantLoseThem:       "Can't Lose Them",
+	AtRisk:             "At Risk",
+	Hibernating:        "Hibernating",
+	Lost:               "Lost",
+}
+
+// Name is the display name of s, such as "At Risk".
+func (s Segment) Name() string {
+	return names[s]
+}
+
+// ParseSegment validates a segment key such as at_risk.
+func ParseSegment(s string) (Segment, error) {
+	if _, ok := names[Segment(s)]; !ok {
+		return "", fmt.Errorf("unknown segment %q", s)
+	}
+	return Segment(s), nil
+}
+
+// segmentOf places recency score r and frequency-monetary score fm, both
+// from 1 to 5.
+func segmentOf(r, fm int) Segment {
+	switch {
+	case r == 5 && fm >= 4:
+		return Champions
+	case r >= 3 && fm >= 4:
+		return LoyalCustomers
+	case r >= 4 && fm >= 2:
+		return PotentialLoyalists
+	case r == 5:
+		return NewCustomers
+	case r == 4:
+		return Promising
+	case r == 3 && fm == 3:
+		return NeedAttention
+	case r == 3:
+		return AboutToSleep
+	case fm == 5:
+		return CantLoseThem
+	case fm >= 3:
+		return AtRisk
+	case r == 2:
+		return Hibernating
+	}
+	return Lost
+}
+
+// Customer is the RFM profile of one user.
+type Customer struct {
+	User      uint32 // code in the table's UserID dictionary
+	Recency   int32  // days from the last purchase to the reference date
+	Frequency int    // transactions
+	Monetary  float64
+	// quantile scores from 1 to Options.Quantiles, higher is better: a
+	// recent purchase scores a high R
+	R, F, M int
+	Segment Segment
+}
+
+// Options configure the scoring.
+type Options struct {
+	// Reference is the day recency is measured to; later purchases are ignored.
+	Reference int32
+	// Quantiles is the number of score levels, 5 when zero.
+	Quantiles int
+}
+
+// MaxQuantiles bounds Options.Quantiles.
+const MaxQuantiles = 10
+
+// Score profiles the users of the given rows of t, all of them when rows is
+// nil, in the order of their user codes.
+func Score(t *columnar.Table, rows []int32, opts Options) ([]Customer, error) {
+	q := opts.Quantiles
+	if q == 0 {
+		q = 5
+	}
+	if q < 2 || q > MaxQuantiles {
+		return nil, fmt.Errorf("quantiles must be between 2 and %d", MaxQuantiles)
+	}
+
+	users := t.UserID.Cardinality()
+	last := make([]int32, users)
+	frequency := make([]int, users)
+	monetary := make([]float64, users)
+	add := func(i int) {
+		d := t.Date[i]
+		if d > opts.Reference {
+			return
+		}
+		u := t.UserID.Codes[i]
+		if frequency[u] == 0 || d > last[u] {
+			last[u] = d
+		}
+		frequency[u]++
+		monetary[u] += t.TotalPrice[i]
+	}
+	if rows == nil {
+		for i := 0; i < t.Len(); i++ {
+			add(i)
+		}
+	} else {
+		for _, i := range rows {
+			add(int(i))
+		}
+	}
+
+	var customers []Customer
+	for u := range frequency {
+		if frequency[u] > 0 {
+			customers = append(customers, Customer{
+				User:      uint32(u),
+				Recency:   opts.Reference - last[u],
+				Frequency: frequency[u],
+				Monetary:  monetary[u],
+			})
+		}
+	}
+	quantiles(customers, q, func(c *Customer) float64 { return -float64(c.Recency) }, func(c *Customer, s int) { c.R = s })
+	quantiles(customers, q, func(c *Customer) float64 { return float64(c.Frequency) }, func(c *Customer, s int) { c.F = s })
+	quantiles(customers, q, func(c *Customer) float64 { return c.Monetary }, func(c *Customer, s int) { c.M = s })
+	for i := range customers {
+		c := &customers[i]
+		c.Segment = segmentOf(fivePoint(c.R, q), fivePoint(int(math.Round(float64(c.F+c.M)/2)), q))
+	}
+	return customers, nil
+}
+
+// quantiles scores customers from 1 to q by rank of value, low values
+// scoring low. Equal values share the score of the first of them, so a
+// long run of one-time buyers all score 1.
+func quantiles(customers []Customer, q int, value func(*Customer) float64, set func(*Customer, int)) {
+	order := make([]int, len(customers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return value(&customers[order[a]]) < value(&customers[order[b]])
+	})
+	n, rank := len(order), 0
+	for i, o := range order {
+		if i > 0 && value(&customers[o]) != value(&customers[order[i-1]]) {
+			rank = i
+		}
+		set(&customers[o], 1+rank*q/n)
+	}
+}
+
+// fivePoint rescales a score from 1..q to 1..5, keeping both ends.
+func fivePoint(score, q int) int {
+	return 1 + int(math.Round(float64(4*(score-1))/float64(q-1)))
+}
+
+// Summary describes one segment.
+type Summary struct {
+	Segment Segment
+	Users   int
+	Revenue float64
+	// averages over the segment's users
+	Recency, Frequency, Monetary float64
+}
+
+// Summarize sums customers up per segment, in the order of Segments.
+func Summarize(customers []Customer) []Summary {
+	index := make(map[Segment]int, len(Segments))
+	out := make([]Summary, len(Segments))
+	for i, s := range Segments {
+		index[s] = i
+		out[i].Segment = s
+	}
+	for _, c := range customers {
+		s := &out[index[c.Segment]]
+		s.Users++
+		s.Revenue += c.Monetary
+		s.Recency += float64(c.Recency)
+		s.Frequency += float64(c.Frequency)
+	}
+	for i := range out {
+		if s := &out[i]; s.Users > 0 {
+			s.Recency /= float64(s.Users)
+			s.Frequency /= float64(s.Users)
+			s.Monetary = s.Revenue / float64(s.Users)
+		}
+	}
+	return out
+}
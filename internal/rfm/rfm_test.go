@@ -0,0 +1,88 @@
+package rfm
+
+import (
+	"testing"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+)
+
+func TestQuantilesShareTies(t *testing.T) {
+	customers := make([]Customer, 6)
+	for i, f := range []int{1, 1, 1, 1, 2, 9} {
+		customers[i].Frequency = f
+	}
+	quantiles(customers, 3, func(c *Customer) float64 { return float64(c.Frequency) }, func(c *Customer, s int) { c.F = s })
+	want := []int{1, 1, 1, 1, 3, 3}
+	for i, c := range customers {
+		if c.F != want[i] {
+			t.Errorf("customer %d (frequency %d) scored %d, want %d", i, c.Frequency, c.F, want[i])
+		}
+	}
+}
+
+func TestSegmentOf(t *testing.T) {
+	tests := []struct {
+		r, fm int
+		want  Segment
+	}{
+		{5, 5, Champions},
+		{3, 4, LoyalCustomers},
+		{4, 2, PotentialLoyalists},
+		{5, 1, NewCustomers},
+		{4, 1, Promising},
+		{3, 3, NeedAttention},
+		{3, 1, AboutToSleep},
+		{1, 5, CantLoseThem},
+		{2, 3, AtRisk},
+		{2, 2, Hibernating},
+		{1, 1, Lost},
+	}
+	for _, tt := range tests {
+		if got := segmentOf(tt.r, tt.fm); got != tt.want {
+			t.Errorf("segmentOf(%d, %d) = %s, want %s", tt.r, tt.fm, got, tt.want)
+		}
+	}
+	if fivePoint(3, 3) != 5 || fivePoint(1, 10) != 1 || fivePoint(6, 10) != 3 {
+		t.Error("fivePoint should spread scores over 1..5")
+	}
+}
+
+func TestScore(t *testing.T) {
+	var txs []domain.Transaction
+	add := func(user, date string, total float64) {
+		d, _ := domain.ParseDate(date)
+		txs = append(txs, domain.Transaction{UserID: user, Date: d, TotalPrice: total})
+	}
+	// a buys often and recently, b once long ago, c after the reference date
+	for _, d := range []string{"2024-01-01", "2024-02-01", "2024-03-01"} {
+		add("a", d, 100)
+	}
+	add("b", "2023-01-01", 10)
+	add("c", "2024-06-01", 50)
+	tbl := columnar.FromTransactions(txs)
+	ref, _ := domain.ParseDate("2024-03-31")
+
+	customers, err := Score(tbl, nil, Options{Reference: columnar.DayOf(ref), Quantiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(customers) != 2 {
+		t.Fatalf("got %d customers, want a and b", len(customers))
+	}
+	a, b := customers[0], customers[1]
+	if a.Recency != 30 || a.Frequency != 3 || a.Monetary != 300 || a.Segment != Champions {
+		t.Errorf("a = %+v", a)
+	}
+	if b.R != 1 || b.F != 1 || b.M != 1 || b.Segment != Lost {
+		t.Errorf("b = %+v", b)
+	}
+
+	sums := Summarize(customers)
+	if sums[0].Segment != Champions || sums[0].Users != 1 || sums[0].Monetary != 300 {
+		t.Errorf("champions = %+v", sums[0])
+	}
+	if _, err := Score(tbl, nil, Options{Quantiles: 11}); err == nil {
+		t.Error("11 quantiles should fail")
+	}
+}
@@ -0,0 +1,238 @@
+package sqlquery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Statement is a parsed SELECT over the transactions table.
+type Statement struct {
+	Explain  bool
+	Distinct bool
+	Items    []SelectItem
+	Where    Expr
+	GroupBy  []Expr
+	Having   Expr
+	OrderBy  []OrderItem
+	Limit    int // -1 without a LIMIT clause
+	Offset   int
+}
+
+// SelectItem is one entry of the select list; Star stands for every column.
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+	Star  bool
+	Pos   int
+}
+
+// OrderItem is one ORDER BY key.
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// Expr is an expression node. String renders it canonically, so two
+// spellings of the same expression, such as "SUM(TotalPrice)" and
+// "sum(total_price)", render the same.
+type Expr interface {
+	String() string
+	pos() int
+}
+
+// canon memoizes the String of a compound node, which is compared against
+// the GROUP BY keys at every node: rebuilding it from the whole subtree
+// each time made long chains cubic to compile.
+type canon struct{ text string }
+
+func (c *canon) memo(render func() string) string {
+	if c.text == "" {
+		c.text = render()
+	}
+	return c.text
+}
+
+type (
+	ColumnRef struct {
+		Name string
+		Pos  int
+	}
+	NumberLit struct {
+		Value float64
+		Pos   int
+	}
+	StringLit struct {
+		Value string
+		Pos   int
+	}
+	BoolLit struct {
+		Value bool
+		Pos   int
+	}
+	// Unary is "-x" or "NOT x".
+	Unary struct {
+		Op  string
+		X   Expr
+		Pos int
+		canon
+	}
+	// Binary is an arithmetic, comparison, logical, LIKE or || operation.
+	// Ops are upper case and "<>" is normalized to "!=".
+	Binary struct {
+		Op   string
+		L, R Expr
+		Pos  int
+		canon
+	}
+	In struct {
+		X    Expr
+		List []Expr
+		Not  bool
+		Pos  int
+		canon
+	}
+	Between struct {
+		X, Lo, Hi Expr
+		Not       bool
+		Pos       int
+		canon
+	}
+	IsNull struct {
+		X   Expr
+		Not bool
+		Pos int
+		canon
+	}
+	// Call is a function call; Star is count(*).
+	Call struct {
+		Name     string // lower case
+		Args     []Expr
+		Star     bool
+		Distinct bool
+		Pos      int
+		canon
+	}
+)
+
+func (e *ColumnRef) pos() int { return e.Pos }
+func (e *NumberLit) pos() int { return e.Pos }
+func (e *StringLit) pos() int { return e.Pos }
+func (e *BoolLit) pos() int   { return e.Pos }
+func (e *Unary) pos() int     { return e.Pos }
+func (e *Binary) pos() int    { return e.Pos }
+func (e *In) pos() int        { return e.Pos }
+func (e *Between) pos() int   { return e.Pos }
+func (e *IsNull) pos() int    { return e.Pos }
+func (e *Call) pos() int      { return e.Pos }
+
+func (e *ColumnRef) String() string {
+	if c, ok := lookupColumn(e.Name); ok {
+		return c.name
+	}
+	return e.Name
+}
+
+func (e *NumberLit) String() string { return strconv.FormatFloat(e.Value, 'g', -1, 64) }
+func (e *StringLit) String() string { return quoteString(e.Value) }
+
+func (e *BoolLit) String() string {
+	if e.Value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (e *Unary) String() string {
+	return e.memo(func() string {
+		if e.Op == "NOT" {
+			return "NOT " + operand(e.X)
+		}
+		return e.Op + operand(e.X)
+	})
+}
+
+func (e *Binary) String() string {
+	return e.memo(func() string { return operand(e.L) + " " + e.Op + " " + operand(e.R) })
+}
+
+func (e *In) String() string {
+	return e.memo(func() string {
+		items := make([]string, len(e.List))
+		for i, x := range e.List {
+			items[i] = x.String()
+		}
+		return operand(e.X) + not(e.Not) + " IN (" + strings.Join(items, ", ") + ")"
+	})
+}
+
+func (e *Between) String() string {
+	return e.memo(func() string {
+		return operand(e.X) + not(e.Not) + " BETWEEN " + operand(e.Lo) + " AND " + operand(e.Hi)
+	})
+}
+
+func (e *IsNull) String() string {
+	return e.memo(func() string { return operand(e.X) + " IS" + not(e.Not) + " NULL" })
+}
+
+func (e *Call) String() string {
+	return e.memo(func() string {
+		if e.Star {
+			return e.Name + "(*)"
+		}
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = a.String()
+		}
+		distinct := ""
+		if e.Distinct {
+			distinct = "DISTINCT "
+		}
+		return e.Name + "(" + distinct + strings.Join(args, ", ") + ")"
+	})
+}
+
+// operand parenthesizes compound sub-expressions so String is unambiguous.
+func operand(e Expr) string {
+	switch e.(type) {
+	case *Binary, *Unary, *In, *Between, *IsNull:
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func not(b bool) string {
+	if b {
+		return " NOT"
+	}
+	return ""
+}
+
+// walk calls fn on e and its sub-expressions until fn returns false.
+func walk(e Expr, fn func(Expr) bool) {
+	if e == nil || !fn(e) {
+		return
+	}
+	switch e := e.(type) {
+	case *Unary:
+		walk(e.X, fn)
+	case *Binary:
+		walk(e.L, fn)
+		walk(e.R, fn)
+	case *In:
+		walk(e.X, fn)
+		for _, x := range e.List {
+			walk(x, fn)
+		}
+	case *Between:
+		walk(e.X, fn)
+		walk(e.Lo, fn)
+		walk(e.Hi, fn)
+	case *IsNull:
+		walk(e.X, fn)
+	case *Call:
+		for _, a := range e.Args {
+			walk(a, fn)
+		}
+	}
+}
@@ -0,0 +1,736 @@
+package sqlquery
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/query"
+)
+
+// kind is the static type of an expression.
+type kind uint8
+
+const (
+	kNull kind = iota
+	kNumber
+	kString
+	kDate
+	kBool
+)
+
+func (k kind) String() string {
+	return [...]string{"null", "number", "string", "date", "boolean"}[k]
+}
+
+// value is a dynamically typed value. Numbers and dates (as day numbers)
+// are held in n, booleans as 0 or 1. It is comparable, so it can key the
+// sets of count(DISTINCT ...).
+type value struct {
+	k kind
+	n float64
+	s string
+}
+
+var null = value{}
+
+func number(n float64) value { return value{k: kNumber, n: n} }
+func str(s string) value     { return value{k: kString, s: s} }
+func date(d int32) value     { return value{k: kDate, n: float64(d)} }
+
+func boolean(b bool) value {
+	if b {
+		return value{k: kBool, n: 1}
+	}
+	return value{k: kBool}
+}
+
+// truthy treats NULL as false, as WHERE and HAVING do.
+func (v value) truthy() bool { return v.k == kBool && v.n != 0 }
+
+// compareValues orders values of the same kind; NULL sorts first.
+func compareValues(a, b value) int {
+	if a.k == kNull || b.k == kNull {
+		return int(a.k) - int(b.k)
+	}
+	if a.k == kString {
+		return strings.Compare(a.s, b.s)
+	}
+	switch {
+	case a.n < b.n:
+		return -1
+	case a.n > b.n:
+		return 1
+	}
+	return 0
+}
+
+// ctx is what compiled expressions read: the current row, and the current
+// group once rows are aggregated.
+type ctx struct {
+	row int
+	g   *group
+}
+
+type evalFn func(c *ctx) value
+
+// compiled is a type-checked expression.
+type compiled struct {
+	k    kind
+	eval evalFn
+}
+
+func constant(v value) compiled {
+	return compiled{v.k, func(*ctx) value { return v }}
+}
+
+// column is a column of the virtual transactions table.
+type column struct {
+	name string
+	k    kind
+	read func(t *columnar.Table) func(i int) value
+}
+
+func dictColumn(name string, d func(t *columnar.Table) *columnar.Dict) column {
+	return column{name, kString, func(t *columnar.Table) func(int) value {
+		dict := d(t)
+		return func(i int) value { return str(dict.At(i)) }
+	}}
+}
+
+// schema lists the columns of the transactions table in SELECT * order.
+var schema = []column{
+	{"id", kString, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return str(t.ID[i]) }
+	}},
+	{"date", kDate, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return date(t.Date[i]) }
+	}},
+	dictColumn("user_id", func(t *columnar.Table) *columnar.Dict { return t.UserID }),
+	dictColumn("country", func(t *columnar.Table) *columnar.Dict { return t.Country }),
+	dictColumn("region", func(t *columnar.Table) *columnar.Dict { return t.Region }),
+	dictColumn("product_id", func(t *columnar.Table) *columnar.Dict { return t.ProductID }),
+	dictColumn("product_name", func(t *columnar.Table) *columnar.Dict { return t.ProductName }),
+	dictColumn("category", func(t *columnar.Table) *columnar.Dict { return t.Category }),
+	{"price", kNumber, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return number(t.Price[i]) }
+	}},
+	{"quantity", kNumber, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return number(float64(t.Quantity[i])) }
+	}},
+	{"total_price", kNumber, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return number(t.TotalPrice[i]) }
+	}},
+	{"stock", kNumber, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return number(float64(t.Stock[i])) }
+	}},
+	{"added_date", kDate, func(t *columnar.Table) func(int) value {
+		return func(i int) value { return date(t.AddedDate[i]) }
+	}},
+}
+
+// lookupColumn matches column names case-insensitively and ignoring
+// underscores, so the Transaction field names work too.
+func lookupColumn(name string) (column, bool) {
+	key := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	if key == "transactionid" {
+		key = "id"
+	}
+	for _, c := range schema {
+		if strings.ReplaceAll(c.name, "_", "") == key {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+func columnNames() string {
+	names := make([]string, len(schema))
+	for i, c := range schema {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+var aggregateFuncs = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+func isAggregate(e Expr) bool {
+	found := false
+	walk(e, func(e Expr) bool {
+		if c, ok := e.(*Call); ok && aggregateFuncs[c.Name] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// scope compiles expressions of one clause. Row expressions read columns;
+// once rows are grouped (post), expressions read the group keys and
+// aggregates, and columns may only appear inside aggregate functions.
+type scope struct {
+	t      *columnar.Table
+	clause string
+	post   bool
+	inAgg  bool
+	keys   []string // canonical GROUP BY expressions
+	kinds  []kind
+	aggs   *[]*aggregate
+}
+
+func (s *scope) compile(e Expr) (compiled, error) {
+	if s.post && !s.inAgg && len(s.keys) > 0 {
+		text := e.String()
+		for k, key := range s.keys {
+			if key == text {
+				return compiled{s.kinds[k], func(c *ctx) value { return c.g.keys[k] }}, nil
+			}
+		}
+	}
+
+	switch e := e.(type) {
+	case *ColumnRef:
+		col, ok := lookupColumn(e.Name)
+		if !ok {
+			return compiled{}, errorAt(e.Pos, "unknown column %q (columns: %s)", e.Name, columnNames())
+		}
+		if s.post && !s.inAgg {
+			return compiled{}, errorAt(e.Pos, "column %s must appear in GROUP BY or be used in an aggregate function", col.name)
+		}
+		read := col.read(s.t)
+		return compiled{col.k, func(c *ctx) value { return read(c.row) }}, nil
+	case *NumberLit:
+		return constant(number(e.Value)), nil
+	case *StringLit:
+		return constant(str(e.Value)), nil
+	case *BoolLit:
+		return constant(boolean(e.Value)), nil
+	case *Unary:
+		return s.unary(e)
+	case *Binary:
+		return s.binary(e)
+	case *In:
+		return s.in(e)
+	case *Between:
+		// x BETWEEN lo AND hi is x >= lo AND x <= hi
+		var b Expr = &Binary{Op: "AND", Pos: e.Pos,
+			L: &Binary{Op: ">=", L: e.X, R: e.Lo, Pos: e.Pos},
+			R: &Binary{Op: "<=", L: e.X, R: e.Hi, Pos: e.Pos},
+		}
+		if e.Not {
+			b = &Unary{Op: "NOT", X: b, Pos: e.Pos}
+		}
+		return s.compile(b)
+	case *IsNull:
+		x, err := s.compile(e.X)
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kBool, func(c *ctx) value { return boolean((x.eval(c).k == kNull) != e.Not) }}, nil
+	case *Call:
+		if aggregateFuncs[e.Name] {
+			return s.aggregate(e)
+		}
+		return s.scalar(e)
+	}
+	return compiled{}, errorAt(e.pos(), "unsupported expression %s", e)
+}
+
+func (s *scope) compileAs(e Expr, want kind, what string) (compiled, error) {
+	c, err := s.compile(e)
+	if err == nil && c.k != want && c.k != kNull {
+		err = errorAt(e.pos(), "%s expects a %s, got %s of type %s", what, want, e, c.k)
+	}
+	return c, err
+}
+
+func (s *scope) unary(e *Unary) (compiled, error) {
+	if e.Op == "NOT" {
+		x, err := s.compileAs(e.X, kBool, "NOT")
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kBool, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return boolean(!v.truthy())
+		}}, nil
+	}
+	x, err := s.compileAs(e.X, kNumber, "unary -")
+	if err != nil {
+		return compiled{}, err
+	}
+	return compiled{kNumber, func(c *ctx) value {
+		v := x.eval(c)
+		if v.k == kNull {
+			return null
+		}
+		return number(-v.n)
+	}}, nil
+}
+
+func (s *scope) binary(e *Binary) (compiled, error) {
+	switch e.Op {
+	case "AND", "OR":
+		l, err := s.compileAs(e.L, kBool, e.Op)
+		if err != nil {
+			return compiled{}, err
+		}
+		r, err := s.compileAs(e.R, kBool, e.Op)
+		if err != nil {
+			return compiled{}, err
+		}
+		if e.Op == "AND" {
+			return compiled{kBool, func(c *ctx) value { return boolean(l.eval(c).truthy() && r.eval(c).truthy()) }}, nil
+		}
+		return compiled{kBool, func(c *ctx) value { return boolean(l.eval(c).truthy() || r.eval(c).truthy()) }}, nil
+
+	case "+", "-", "*", "/", "%":
+		l, err := s.compileAs(e.L, kNumber, e.Op)
+		if err != nil {
+			return compiled{}, err
+		}
+		r, err := s.compileAs(e.R, kNumber, e.Op)
+		if err != nil {
+			return compiled{}, err
+		}
+		op := arithmetic[e.Op]
+		return compiled{kNumber, func(c *ctx) value {
+			a, b := l.eval(c), r.eval(c)
+			if a.k == kNull || b.k == kNull {
+				return null
+			}
+			return op(a.n, b.n)
+		}}, nil
+
+	case "||":
+		l, err := s.compileAs(e.L, kString, "||")
+		if err != nil {
+			return compiled{}, err
+		}
+		r, err := s.compileAs(e.R, kString, "||")
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kString, func(c *ctx) value {
+			a, b := l.eval(c), r.eval(c)
+			if a.k == kNull || b.k == kNull {
+				return null
+			}
+			return str(a.s + b.s)
+		}}, nil
+
+	case "LIKE":
+		l, err := s.compileAs(e.L, kString, "LIKE")
+		if err != nil {
+			return compiled{}, err
+		}
+		pattern, ok := e.R.(*StringLit)
+		if !ok {
+			return compiled{}, errorAt(e.R.pos(), "LIKE expects a string literal pattern")
+		}
+		re := likePattern(pattern.Value)
+		return compiled{kBool, func(c *ctx) value {
+			v := l.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return boolean(re.MatchString(v.s))
+		}}, nil
+	}
+
+	l, r, err := s.comparable(e.L, e.R, e.Op)
+	if err != nil {
+		return compiled{}, err
+	}
+	test := comparisons[e.Op]
+	return compiled{kBool, func(c *ctx) value {
+		a, b := l.eval(c), r.eval(c)
+		if a.k == kNull || b.k == kNull {
+			return null
+		}
+		return boolean(test(compareValues(a, b)))
+	}}, nil
+}
+
+var arithmetic = map[string]func(a, b float64) value{
+	"+": func(a, b float64) value { return number(a + b) },
+	"-": func(a, b float64) value { return number(a - b) },
+	"*": func(a, b float64) value { return number(a * b) },
+	"/": func(a, b float64) value {
+		if b == 0 {
+			return null
+		}
+		return number(a / b)
+	},
+	"%": func(a, b float64) value {
+		if b == 0 {
+			return null
+		}
+		return number(math.Mod(a, b))
+	},
+}
+
+var comparisons = map[string]func(c int) bool{
+	"=":  func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+// comparable compiles two operands that must have the same type. A string
+// literal compared with a date is read as a date.
+func (s *scope) comparable(le, re Expr, op string) (compiled, compiled, error) {
+	l, err := s.compile(le)
+	if err != nil {
+		return l, l, err
+	}
+	r, err := s.compile(re)
+	if err != nil {
+		return l, r, err
+	}
+	if l.k == kDate && r.k == kString {
+		r, err = dateLiteral(re)
+	} else if l.k == kString && r.k == kDate {
+		l, err = dateLiteral(le)
+	}
+	if err != nil {
+		return l, r, err
+	}
+	if l.k != r.k && l.k != kNull && r.k != kNull {
+		return l, r, errorAt(le.pos(), "cannot compare %s (%s) %s %s (%s)", le, l.k, op, re, r.k)
+	}
+	return l, r, nil
+}
+
+func dateLiteral(e Expr) (compiled, error) {
+	lit, ok := e.(*StringLit)
+	if !ok {
+		return compiled{}, errorAt(e.pos(), "cannot compare a date with %s, use date(...) to convert it", e)
+	}
+	t, err := domain.ParseDate(lit.Value)
+	if err != nil {
+		return compiled{}, errorAt(e.pos(), "invalid date %s: want 'YYYY-MM-DD'", lit)
+	}
+	return constant(date(columnar.DayOf(t))), nil
+}
+
+func (s *scope) in(e *In) (compiled, error) {
+	x, err := s.compile(e.X)
+	if err != nil {
+		return compiled{}, err
+	}
+	list := make([]compiled, len(e.List))
+	for i, item := range e.List {
+		if _, list[i], err = s.comparable(e.X, item, "IN"); err != nil {
+			return compiled{}, err
+		}
+	}
+	return compiled{kBool, func(c *ctx) value {
+		v := x.eval(c)
+		if v.k == kNull {
+			return null
+		}
+		found := false
+		for _, item := range list {
+			if compareValues(v, item.eval(c)) == 0 {
+				found = true
+				break
+			}
+		}
+		return boolean(found != e.Not)
+	}}, nil
+}
+
+// likePattern translates a LIKE pattern, where % matches any run of
+// characters and _ any single one.
+func likePattern(p string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range p {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// scalarFuncs lists the non-aggregate functions for error messages.
+const scalarFuncs = "date, date_trunc, year, month, day, lower, upper, length, abs, round"
+
+func (s *scope) scalar(e *Call) (compiled, error) {
+	if e.Star || e.Distinct {
+		return compiled{}, errorAt(e.Pos, "%s is not an aggregate function", e.Name)
+	}
+	arity := func(n int) error {
+		if len(e.Args) != n {
+			return errorAt(e.Pos, "%s expects %d argument(s), got %d", e.Name, n, len(e.Args))
+		}
+		return nil
+	}
+
+	switch e.Name {
+	case "date":
+		if err := arity(1); err != nil {
+			return compiled{}, err
+		}
+		if lit, ok := e.Args[0].(*StringLit); ok {
+			return dateLiteral(lit)
+		}
+		x, err := s.compileAs(e.Args[0], kString, "date")
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kDate, func(c *ctx) value {
+			t, err := domain.ParseDate(x.eval(c).s)
+			if err != nil {
+				return null
+			}
+			return date(columnar.DayOf(t))
+		}}, nil
+
+	case "date_trunc":
+		if err := arity(2); err != nil {
+			return compiled{}, err
+		}
+		unit, ok := e.Args[0].(*StringLit)
+		if !ok {
+			return compiled{}, errorAt(e.Args[0].pos(), "date_trunc expects the unit as a string literal, such as 'month'")
+		}
+		b, err := query.ParseBucket(unit.Value)
+		if err != nil {
+			return compiled{}, errorAt(unit.Pos, "%v", err)
+		}
+		x, err := s.compileAs(e.Args[1], kDate, "date_trunc")
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kDate, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return date(b.Start(int32(v.n)))
+		}}, nil
+
+	case "year", "month", "day":
+		if err := arity(1); err != nil {
+			return compiled{}, err
+		}
+		x, err := s.compileAs(e.Args[0], kDate, e.Name)
+		if err != nil {
+			return compiled{}, err
+		}
+		part := e.Name
+		return compiled{kNumber, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			y, m, d := columnar.Civil(int32(v.n))
+			switch part {
+			case "year":
+				return number(float64(y))
+			case "month":
+				return number(float64(m))
+			}
+			return number(float64(d))
+		}}, nil
+
+	case "lower", "upper", "length":
+		if err := arity(1); err != nil {
+			return compiled{}, err
+		}
+		x, err := s.compileAs(e.Args[0], kString, e.Name)
+		if err != nil {
+			return compiled{}, err
+		}
+		if e.Name == "length" {
+			return compiled{kNumber, func(c *ctx) value {
+				v := x.eval(c)
+				if v.k == kNull {
+					return null
+				}
+				return number(float64(len([]rune(v.s))))
+			}}, nil
+		}
+		fn := strings.ToLower
+		if e.Name == "upper" {
+			fn = strings.ToUpper
+		}
+		return compiled{kString, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return str(fn(v.s))
+		}}, nil
+
+	case "abs":
+		if err := arity(1); err != nil {
+			return compiled{}, err
+		}
+		x, err := s.compileAs(e.Args[0], kNumber, "abs")
+		if err != nil {
+			return compiled{}, err
+		}
+		return compiled{kNumber, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return number(math.Abs(v.n))
+		}}, nil
+
+	case "round":
+		if len(e.Args) != 1 && len(e.Args) != 2 {
+			return compiled{}, errorAt(e.Pos, "round expects 1 or 2 arguments, got %d", len(e.Args))
+		}
+		x, err := s.compileAs(e.Args[0], kNumber, "round")
+		if err != nil {
+			return compiled{}, err
+		}
+		scale := 1.0
+		if len(e.Args) == 2 {
+			digits, ok := e.Args[1].(*NumberLit)
+			if !ok || digits.Value != math.Trunc(digits.Value) {
+				return compiled{}, errorAt(e.Args[1].pos(), "round expects the number of digits as an integer literal")
+			}
+			scale = math.Pow(10, digits.Value)
+		}
+		return compiled{kNumber, func(c *ctx) value {
+			v := x.eval(c)
+			if v.k == kNull {
+				return null
+			}
+			return number(math.Round(v.n*scale) / scale)
+		}}, nil
+	}
+	return compiled{}, errorAt(e.Pos, "unknown function %s (aggregates: count, sum, avg, min, max; functions: %s)", e.Name, scalarFuncs)
+}
+
+// aggregate is an aggregate function call, evaluated per group.
+type aggregate struct {
+	fn       string
+	distinct bool
+	star     bool
+	arg      evalFn // row expression; nil for count(*)
+	k        kind   // result type
+	text     string
+}
+
+// aggState is the running state of one aggregate in one group.
+type aggState struct {
+	n        int
+	sum      float64
+	min, max value
+	seen     map[value]struct{}
+}
+
+func (a *aggregate) add(st *aggState, c *ctx) {
+	if a.star {
+		st.n++
+		return
+	}
+	v := a.arg(c)
+	if v.k == kNull {
+		return
+	}
+	if a.distinct {
+		if st.seen == nil {
+			st.seen = make(map[value]struct{})
+		}
+		st.seen[v] = struct{}{}
+		return
+	}
+	if st.n == 0 || compareValues(v, st.min) < 0 {
+		st.min = v
+	}
+	if st.n == 0 || compareValues(v, st.max) > 0 {
+		st.max = v
+	}
+	st.sum += v.n
+	st.n++
+}
+
+func (a *aggregate) result(st *aggState) value {
+	if a.fn == "count" {
+		if a.distinct {
+			return number(float64(len(st.seen)))
+		}
+		return number(float64(st.n))
+	}
+	if st.n == 0 {
+		return null
+	}
+	switch a.fn {
+	case "sum":
+		return number(st.sum)
+	case "avg":
+		return number(st.sum / float64(st.n))
+	case "min":
+		return st.min
+	}
+	return st.max
+}
+
+func (s *scope) aggregate(e *Call) (compiled, error) {
+	if !s.post {
+		return compiled{}, errorAt(e.Pos, "aggregate function %s is not allowed in %s", e.Name, s.clause)
+	}
+	if s.inAgg {
+		return compiled{}, errorAt(e.Pos, "aggregate functions cannot be nested")
+	}
+	if e.Star && e.Name != "count" {
+		return compiled{}, errorAt(e.Pos, "%s(*) is not supported, only count(*)", e.Name)
+	}
+	if e.Distinct && e.Name != "count" {
+		return compiled{}, errorAt(e.Pos, "DISTINCT is only supported in count(DISTINCT ...)")
+	}
+
+	text := e.String()
+	for k, a := range *s.aggs {
+		if a.text == text {
+			return s.aggregateRef(k), nil
+		}
+	}
+
+	a := &aggregate{fn: e.Name, distinct: e.Distinct, star: e.Star, k: kNumber, text: text}
+	if !e.Star {
+		if len(e.Args) != 1 {
+			return compiled{}, errorAt(e.Pos, "%s expects 1 argument, got %d", e.Name, len(e.Args))
+		}
+		inner := *s
+		inner.inAgg = true
+		arg, err := inner.compile(e.Args[0])
+		if err != nil {
+			return compiled{}, err
+		}
+		if (e.Name == "sum" || e.Name == "avg") && arg.k != kNumber {
+			return compiled{}, errorAt(e.Args[0].pos(), "%s expects a number, got %s of type %s", e.Name, e.Args[0], arg.k)
+		}
+		a.arg = arg.eval
+		if e.Name == "min" || e.Name == "max" {
+			a.k = arg.k
+		}
+	}
+	*s.aggs = append(*s.aggs, a)
+	return s.aggregateRef(len(*s.aggs) - 1), nil
+}
+
+func (s *scope) aggregateRef(k int) compiled {
+	a := (*s.aggs)[k]
+	return compiled{a.k, func(c *ctx) value { return a.result(&c.g.states[k]) }}
+}
@@ -0,0 +1,576 @@
+// Package sqlquery runs a read-only SQL dialect over a virtual
+// "transactions" table backed by a repository.Repository:
+//
+//	SELECT country, date_trunc('month', date) AS month, sum(total_price) AS revenue
+//	FROM transactions
+//	WHERE category = 'Electronics' AND date >= '2024-01-01'
+//	GROUP BY country, month
+//	HAVING count(DISTINCT user_id) > 10
+//	ORDER BY revenue DESC
+//	LIMIT 20
+//
+// Equality and IN predicates on indexed columns are answered from the
+// dimension indexes; EXPLAIN shows how a query will run.
+package sqlquery
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"Dashlytics/internal/filter"
+	"Dashlytics/internal/query"
+	"Dashlytics/internal/repository"
+)
+
+// Options tune Run.
+type Options struct {
+	// MaxRows cuts results to this many rows, reporting Truncated; zero
+	// keeps every row.
+	MaxRows int
+	// Explain returns the plan without running the query, as EXPLAIN does.
+	Explain bool
+}
+
+// Column describes a result column. Type is number, string, date or boolean.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Result is the answer to a query. Cells are float64, string (dates as
+// YYYY-MM-DD), bool or nil; NaN and infinities are nil. Explained queries
+// carry the Plan and no rows.
+type Result struct {
+	Columns   []Column `json:"columns,omitempty"`
+	Rows      [][]any  `json:"rows"`
+	Truncated bool     `json:"truncated,omitempty"`
+	Plan      []string `json:"plan,omitempty"`
+}
+
+// Run parses, plans and runs src against repo. Syntax and planning errors
+// are returned as *Error.
+func Run(repo repository.Repository, src string, opts Options) (*Result, error) {
+	stmt, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := newPlan(repo, stmt)
+	if err != nil {
+		return nil, locate(err, src)
+	}
+	if stmt.Explain || opts.Explain {
+		return &Result{Rows: [][]any{}, Plan: pl.explain(repo)}, nil
+	}
+	return pl.execute(repo, opts), nil
+}
+
+// indexedColumns are the columns answered by repository.Repository.ByDimension.
+var indexedColumns = map[string]func(f *filter.Filter) *[]string{
+	"country":    func(f *filter.Filter) *[]string { return &f.Countries },
+	"region":     func(f *filter.Filter) *[]string { return &f.Regions },
+	"category":   func(f *filter.Filter) *[]string { return &f.Categories },
+	"product_id": func(f *filter.Filter) *[]string { return &f.ProductIDs },
+	"user_id":    func(f *filter.Filter) *[]string { return &f.UserIDs },
+}
+
+type output struct {
+	name string
+	compiled
+}
+
+type sortKey struct {
+	eval evalFn
+	desc bool
+	text string
+}
+
+type plan struct {
+	index     filter.Filter // WHERE conjuncts answered by the indexes
+	indexText []string
+	where     evalFn // the remaining conjuncts, nil when there are none
+	whereText []string
+
+	grouped    bool
+	keys       []compiled
+	keyText    []string
+	aggs       []*aggregate
+	having     evalFn
+	havingText string
+
+	outputs []output
+	sort    []sortKey
+	limit   int // -1 for no limit
+	offset  int
+}
+
+func newPlan(repo repository.Repository, stmt *Statement) (*plan, error) {
+	t := repo.Columns()
+	pl := &plan{limit: stmt.Limit, offset: stmt.Offset}
+
+	// expand * and check where aggregates appear
+	var items []SelectItem
+	hasAgg := stmt.Having != nil
+	for _, item := range stmt.Items {
+		if !item.Star {
+			items = append(items, item)
+			hasAgg = hasAgg || isAggregate(item.Expr)
+			continue
+		}
+		for _, c := range schema {
+			items = append(items, SelectItem{Expr: &ColumnRef{Name: c.name, Pos: item.Pos}, Pos: item.Pos})
+		}
+	}
+	for _, o := range stmt.OrderBy {
+		hasAgg = hasAgg || isAggregate(o.Expr)
+	}
+	groupBy := stmt.GroupBy
+	if stmt.Distinct {
+		if len(groupBy) > 0 || hasAgg {
+			return nil, errorAt(stmt.Items[0].Pos, "SELECT DISTINCT cannot be combined with GROUP BY or aggregate functions")
+		}
+		for _, item := range items {
+			groupBy = append(groupBy, item.Expr)
+		}
+	}
+	pl.grouped = len(groupBy) > 0 || hasAgg
+	if pl.grouped {
+		for _, item := range stmt.Items {
+			if item.Star {
+				return nil, errorAt(item.Pos, "SELECT * cannot be used with GROUP BY or aggregate functions")
+			}
+		}
+	}
+
+	// WHERE: indexable conjuncts go to the indexes, the rest is evaluated per row
+	rowScope := &scope{t: t, clause: "WHERE", aggs: &pl.aggs}
+	var residual []Expr
+	for _, c := range conjuncts(stmt.Where) {
+		if col, values, ok := indexable(c); ok && len(*indexedColumns[col](&pl.index)) == 0 {
+			*indexedColumns[col](&pl.index) = values
+			pl.indexText = append(pl.indexText, c.String())
+			continue
+		}
+		residual = append(residual, c)
+	}
+	for _, c := range residual {
+		if _, err := rowScope.compileAs(c, kBool, "WHERE"); err != nil {
+			return nil, err
+		}
+		pl.whereText = append(pl.whereText, c.String())
+	}
+	if len(residual) > 0 {
+		where := residual[0]
+		for _, c := range residual[1:] {
+			where = &Binary{Op: "AND", L: where, R: c, Pos: c.pos()}
+		}
+		compiledWhere, _ := rowScope.compile(where)
+		pl.where = compiledWhere.eval
+	}
+
+	// GROUP BY keys are row expressions; GROUP BY 1 and aliases refer to the select list
+	post := &scope{t: t, post: pl.grouped, aggs: &pl.aggs}
+	rowScope.clause = "GROUP BY"
+	for _, g := range groupBy {
+		g, err := resolve(g, items, true)
+		if err != nil {
+			return nil, err
+		}
+		k, err := rowScope.compile(g)
+		if err != nil {
+			return nil, err
+		}
+		pl.keys = append(pl.keys, k)
+		pl.keyText = append(pl.keyText, g.String())
+		post.keys = append(post.keys, g.String())
+		post.kinds = append(post.kinds, k.k)
+	}
+
+	if stmt.Having != nil {
+		post.clause = "HAVING"
+		h, err := post.compileAs(stmt.Having, kBool, "HAVING")
+		if err != nil {
+			return nil, err
+		}
+		pl.having, pl.havingText = h.eval, stmt.Having.String()
+	}
+
+	post.clause = "SELECT"
+	for _, item := range items {
+		c, err := post.compile(item.Expr)
+		if err != nil {
+			return nil, err
+		}
+		name := item.Alias
+		if name == "" {
+			name = item.Expr.String()
+		}
+		pl.outputs = append(pl.outputs, output{name, c})
+	}
+
+	post.clause = "ORDER BY"
+	for _, o := range stmt.OrderBy {
+		e, err := resolve(o.Expr, items, false)
+		if err != nil {
+			return nil, err
+		}
+		c, err := post.compile(e)
+		if err != nil {
+			return nil, err
+		}
+		pl.sort = append(pl.sort, sortKey{c.eval, o.Desc, e.String()})
+	}
+	return pl, nil
+}
+
+// resolve replaces an ordinal ("ORDER BY 2") or a select alias with the
+// select item it names. In GROUP BY a real column wins over an alias.
+func resolve(e Expr, items []SelectItem, preferColumns bool) (Expr, error) {
+	switch e := e.(type) {
+	case *NumberLit:
+		n := int(e.Value)
+		if float64(n) != e.Value || n < 1 || n > len(items) {
+			return nil, errorAt(e.Pos, "position %s is not in the select list (1 to %d)", e, len(items))
+		}
+		return items[n-1].Expr, nil
+	case *ColumnRef:
+		if _, isColumn := lookupColumn(e.Name); isColumn && preferColumns {
+			return e, nil
+		}
+		for _, item := range items {
+			if item.Alias != "" && strings.EqualFold(item.Alias, e.Name) {
+				return item.Expr, nil
+			}
+		}
+	}
+	return e, nil
+}
+
+// conjuncts splits a WHERE clause on its top-level ANDs.
+func conjuncts(e Expr) []Expr {
+	if b, ok := e.(*Binary); ok && b.Op == "AND" {
+		return append(conjuncts(b.L), conjuncts(b.R)...)
+	}
+	if e == nil {
+		return nil
+	}
+	return []Expr{e}
+}
+
+// indexable recognizes "col = 'v'" and "col IN ('v', ...)" on an indexed column.
+func indexable(e Expr) (string, []string, bool) {
+	column := func(e Expr) (string, bool) {
+		ref, ok := e.(*ColumnRef)
+		if !ok {
+			return "", false
+		}
+		c, ok := lookupColumn(ref.Name)
+		if !ok || indexedColumns[c.name] == nil {
+			return "", false
+		}
+		return c.name, true
+	}
+	switch e := e.(type) {
+	case *Binary:
+		if e.Op != "=" {
+			return "", nil, false
+		}
+		l, r := e.L, e.R
+		if _, ok := l.(*StringLit); ok {
+			l, r = r, l
+		}
+		col, ok := column(l)
+		lit, isLit := r.(*StringLit)
+		if !ok || !isLit {
+			return "", nil, false
+		}
+		return col, []string{lit.Value}, true
+	case *In:
+		col, ok := column(e.X)
+		if !ok || e.Not {
+			return "", nil, false
+		}
+		values := make([]string, len(e.List))
+		for i, item := range e.List {
+			lit, ok := item.(*StringLit)
+			if !ok {
+				return "", nil, false
+			}
+			values[i] = lit.Value
+		}
+		return col, values, true
+	}
+	return "", nil, false
+}
+
+// group is one GROUP BY group: its key values and aggregate states.
+type group struct {
+	keys   []value
+	states []aggState
+}
+
+// entry is a result row before projection, with its sort key values.
+type entry struct {
+	row  int
+	g    *group
+	sort []value
+}
+
+func (pl *plan) execute(repo repository.Repository, opts Options) *Result {
+	t := repo.Columns()
+	c := &ctx{}
+
+	scan := func(fn func(i int) bool) {
+		visit := func(i int) bool {
+			c.row = i
+			if pl.where != nil && !pl.where(c).truthy() {
+				return true
+			}
+			return fn(i)
+		}
+		if pl.index.IsEmpty() {
+			for i := 0; i < t.Len(); i++ {
+				if !visit(i) {
+					return
+				}
+			}
+			return
+		}
+		for _, i := range pl.index.Select(repo) {
+			if !visit(int(i)) {
+				return
+			}
+		}
+	}
+	sortValues := func() []value {
+		if len(pl.sort) == 0 {
+			return nil
+		}
+		vals := make([]value, len(pl.sort))
+		for k, s := range pl.sort {
+			vals[k] = s.eval(c)
+		}
+		return vals
+	}
+
+	var entries []entry
+	if pl.grouped {
+		groups := make(map[string]*group)
+		var order []*group
+		keys := make([]value, len(pl.keys))
+		var buf []byte
+		scan(func(i int) bool {
+			buf = buf[:0]
+			for k, key := range pl.keys {
+				keys[k] = key.eval(c)
+				buf = appendKey(buf, keys[k])
+			}
+			g, ok := groups[string(buf)]
+			if !ok {
+				g = &group{keys: append([]value(nil), keys...), states: make([]aggState, len(pl.aggs))}
+				groups[string(buf)] = g
+				order = append(order, g)
+			}
+			for k, a := range pl.aggs {
+				a.add(&g.states[k], c)
+			}
+			return true
+		})
+		// aggregates without GROUP BY make one group, even over no rows
+		if len(pl.keys) == 0 && len(order) == 0 {
+			order = append(order, &group{states: make([]aggState, len(pl.aggs))})
+		}
+		for _, g := range order {
+			c.g = g
+			if pl.having != nil && !pl.having(c).truthy() {
+				continue
+			}
+			entries = append(entries, entry{g: g, sort: sortValues()})
+		}
+	} else {
+		// the rows up to the limit, plus one telling whether MaxRows cut
+		// the result, or -1 for every row
+		bound := -1
+		if pl.limit >= 0 {
+			bound = pl.offset + pl.limit
+		}
+		if opts.MaxRows > 0 && (bound < 0 || bound > pl.offset+opts.MaxRows+1) {
+			bound = pl.offset + opts.MaxRows + 1
+		}
+		switch {
+		case len(pl.sort) == 0:
+			// without ORDER BY, stop scanning once the result is complete
+			scan(func(i int) bool {
+				if len(entries) == bound {
+					return false
+				}
+				entries = append(entries, entry{row: i})
+				return true
+			})
+		case bound < 0:
+			scan(func(i int) bool {
+				entries = append(entries, entry{row: i, sort: sortValues()})
+				return true
+			})
+		default:
+			// keep the first rows in order in a max-heap
+			h := &entryHeap{less: pl.less}
+			next := entry{sort: make([]value, len(pl.sort))}
+			scan(func(i int) bool {
+				if bound == 0 {
+					return false
+				}
+				next.row = i
+				for k, s := range pl.sort {
+					next.sort[k] = s.eval(c)
+				}
+				if h.Len() < bound {
+					heap.Push(h, next)
+					next = entry{sort: make([]value, len(pl.sort))}
+				} else if pl.less(&next, &h.entries[0]) {
+					// reuse the evicted row's sort values
+					h.entries[0], next = next, h.entries[0]
+					heap.Fix(h, 0)
+				}
+				return true
+			})
+			entries = h.entries
+		}
+	}
+
+	if len(pl.sort) > 0 {
+		sort.SliceStable(entries, func(i, j int) bool { return pl.less(&entries[i], &entries[j]) })
+	}
+	entries = entries[min(pl.offset, len(entries)):]
+	if pl.limit >= 0 && len(entries) > pl.limit {
+		entries = entries[:pl.limit]
+	}
+
+	res := &Result{Columns: make([]Column, len(pl.outputs)), Rows: make([][]any, 0, len(entries))}
+	for i, o := range pl.outputs {
+		res.Columns[i] = Column{Name: o.name, Type: o.k.String()}
+	}
+	if opts.MaxRows > 0 && len(entries) > opts.MaxRows {
+		entries, res.Truncated = entries[:opts.MaxRows], true
+	}
+	for _, e := range entries {
+		c.row, c.g = e.row, e.g
+		row := make([]any, len(pl.outputs))
+		for i, o := range pl.outputs {
+			row[i] = o.eval(c).export()
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res
+}
+
+// less orders entries by the ORDER BY keys, then rows by their offset.
+func (pl *plan) less(a, b *entry) bool {
+	for k, s := range pl.sort {
+		if c := compareValues(a.sort[k], b.sort[k]); c != 0 {
+			return (c < 0) != s.desc
+		}
+	}
+	return a.g == nil && a.row < b.row
+}
+
+// entryHeap is a max-heap of entries, the last of them in order on top.
+type entryHeap struct {
+	entries []entry
+	less    func(a, b *entry) bool
+}
+
+func (h *entryHeap) Len() int           { return len(h.entries) }
+func (h *entryHeap) Less(i, j int) bool { return h.less(&h.entries[j], &h.entries[i]) }
+func (h *entryHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *entryHeap) Push(x any)         { h.entries = append(h.entries, x.(entry)) }
+func (h *entryHeap) Pop() any {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return e
+}
+
+// appendKey encodes v so that distinct values have distinct encodings.
+func appendKey(buf []byte, v value) []byte {
+	buf = append(buf, byte(v.k))
+	if v.k == kString {
+		buf = binary.AppendUvarint(buf, uint64(len(v.s)))
+		return append(buf, v.s...)
+	}
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.n))
+}
+
+// export converts v for the JSON result.
+func (v value) export() any {
+	switch v.k {
+	case kNumber:
+		// JSON has no NaN or infinities
+		if math.IsNaN(v.n) || math.IsInf(v.n, 0) {
+			return nil
+		}
+		return v.n
+	case kString:
+		return v.s
+	case kDate:
+		return query.Day.Format(int32(v.n))
+	case kBool:
+		return v.n != 0
+	}
+	return nil
+}
+
+func (pl *plan) explain(repo repository.Repository) []string {
+	var lines []string
+	if pl.index.IsEmpty() {
+		lines = append(lines, fmt.Sprintf("Scan: full scan of %s (%d rows)", TableName, repo.Len()))
+	} else {
+		lines = append(lines, fmt.Sprintf("Scan: index lookup %s (%d of %d rows)",
+			strings.Join(pl.indexText, " AND "), len(pl.index.Select(repo)), repo.Len()))
+	}
+	if len(pl.whereText) > 0 {
+		lines = append(lines, "Filter: "+strings.Join(pl.whereText, " AND "))
+	}
+	if pl.grouped {
+		var aggs []string
+		for _, a := range pl.aggs {
+			aggs = append(aggs, a.text)
+		}
+		by := "a single group"
+		if len(pl.keyText) > 0 {
+			by = "group by " + strings.Join(pl.keyText, ", ")
+		}
+		line := "Aggregate: " + by
+		if len(aggs) > 0 {
+			line += " computing " + strings.Join(aggs, ", ")
+		}
+		lines = append(lines, line)
+	}
+	if pl.having != nil {
+		lines = append(lines, "Having: "+pl.havingText)
+	}
+	if len(pl.sort) > 0 {
+		keys := make([]string, len(pl.sort))
+		for i, s := range pl.sort {
+			keys[i] = s.text
+			if s.desc {
+				keys[i] += " DESC"
+			}
+		}
+		lines = append(lines, "Sort: "+strings.Join(keys, ", "))
+	}
+	if pl.limit >= 0 || pl.offset > 0 {
+		limit := "all"
+		if pl.limit >= 0 {
+			limit = fmt.Sprint(pl.limit)
+		}
+		lines = append(lines, fmt.Sprintf("Limit: %s offset %d", limit, pl.offset))
+	}
+	names := make([]string, len(pl.outputs))
+	for i, o := range pl.outputs {
+		names[i] = o.name
+	}
+	return append(lines, "Project: "+strings.Join(names, ", "))
+}
@@ -0,0 +1,182 @@
+package sqlquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a syntax or planning error at a position of the query text.
+type Error struct {
+	Pos    int // byte offset in the query
+	Line   int // 1-based
+	Column int // 1-based, in characters
+	Msg    string
+
+	line string // text of the offending line
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Context returns the offending line with a caret under the error position.
+func (e *Error) Context() string {
+	if e.Line == 0 {
+		return ""
+	}
+	return e.line + "\n" + strings.Repeat(" ", e.Column-1) + "^"
+}
+
+func errorAt(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// locate fills in the line and column of err from the query text.
+func locate(err error, src string) error {
+	e, ok := err.(*Error)
+	if !ok || e.Pos < 0 || e.Pos > len(src) {
+		return err
+	}
+	start := strings.LastIndexByte(src[:e.Pos], '\n') + 1
+	end := strings.IndexByte(src[e.Pos:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += e.Pos
+	}
+	e.Line = strings.Count(src[:e.Pos], "\n") + 1
+	e.Column = utf8.RuneCountInString(src[start:e.Pos]) + 1
+	e.line = src[start:end]
+	return e
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string // string literals and quoted identifiers are unescaped
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return "string " + quoteString(t.text)
+	case tokQuotedIdent:
+		return `"` + t.text + `"`
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			toks = append(toks, token{tokIdent, src[start:i], start})
+		case isDigit(c) || c == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			toks = append(toks, token{tokNumber, src[start:i], start})
+		case c == '\'' || c == '"':
+			text, end, ok := unquote(src, i)
+			if !ok {
+				if c == '"' {
+					return nil, errorAt(i, "unterminated quoted identifier")
+				}
+				return nil, errorAt(i, "unterminated string literal")
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuotedIdent
+			}
+			toks = append(toks, token{kind, text, i})
+			i = end
+		default:
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "<=", ">=", "<>", "!=", "||":
+					toks = append(toks, token{tokSymbol, two, i})
+					i += 2
+					continue
+				}
+			}
+			if strings.IndexByte("=<>+-*/%(),;", c) < 0 {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, errorAt(i, "unexpected character %q", r)
+			}
+			toks = append(toks, token{tokSymbol, string(c), i})
+			i++
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+// unquote reads the quoted text starting at src[start], where a doubled
+// quote stands for the quote itself. It returns the offset after the
+// closing quote.
+func unquote(src string, start int) (string, int, bool) {
+	q := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		if src[i] != q {
+			b.WriteByte(src[i])
+			continue
+		}
+		if i+1 < len(src) && src[i+1] == q {
+			b.WriteByte(q)
+			i++
+			continue
+		}
+		return b.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -0,0 +1,502 @@
+package sqlquery
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TableName is the only table queries can read.
+const TableName = "transactions"
+
+// maxDepth is how deeply expressions can nest, in parentheses, function
+// arguments or chains of NOT and unary minus, before parsing gives up rather
+// than exhausting the stack.
+const maxDepth = 200
+
+// maxTokens bounds the size of the tree, as every node takes at least one
+// token. Chains such as a+b+c+... nest without limit otherwise.
+const maxTokens = 5000
+
+// reserved words cannot be used as bare column aliases.
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true, "HAVING": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "BETWEEN": true, "LIKE": true, "IS": true, "NULL": true, "AS": true,
+	"ASC": true, "DESC": true, "DISTINCT": true, "EXPLAIN": true, "TRUE": true, "FALSE": true,
+	"JOIN": true, "ON": true, "UNION": true,
+}
+
+// Parse parses one SELECT statement, optionally prefixed with EXPLAIN.
+func Parse(src string) (*Statement, error) {
+	stmt, err := parse(src)
+	if err != nil {
+		return nil, locate(err, src)
+	}
+	return stmt, nil
+}
+
+func parse(src string) (*Statement, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) > maxTokens {
+		return nil, errorAt(toks[maxTokens].pos, "query is too long: at most %d tokens are supported", maxTokens)
+	}
+	p := &parser{toks: toks}
+	stmt := &Statement{Limit: -1}
+	stmt.Explain = p.acceptKeyword("EXPLAIN")
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt.Distinct = p.acceptKeyword("DISTINCT")
+	if stmt.Items, err = p.selectList(); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table := p.next()
+	if table.kind != tokIdent && table.kind != tokQuotedIdent || reserved[strings.ToUpper(table.text)] && table.kind == tokIdent {
+		return nil, errorAt(table.pos, "expected a table name, found %s", table)
+	}
+	if !strings.EqualFold(table.text, TableName) {
+		return nil, errorAt(table.pos, "unknown table %q: queries read from %s", table.text, TableName)
+	}
+	if p.isKeyword("JOIN") {
+		return nil, errorAt(p.peek().pos, "joins are not supported, %s is the only table", TableName)
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.GroupBy, err = p.exprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("HAVING") {
+		if stmt.Having, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.OrderBy, err = p.orderList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if stmt.Limit, err = p.count("LIMIT"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if stmt.Offset, err = p.count("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+	p.acceptSymbol(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "expected end of query, found %s", t)
+	}
+	return stmt, nil
+}
+
+type parser struct {
+	toks  []token
+	i     int
+	depth int // of the expression being parsed
+}
+
+// enter descends into a nested expression, failing past maxDepth; leave
+// returns from it.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return errorAt(p.peek().pos, "expression nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+// peekAt looks n tokens ahead.
+func (p *parser) peekAt(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.unexpected("expected " + kw)
+	}
+	return nil
+}
+
+func (p *parser) isSymbol(s string) bool {
+	t := p.peek()
+	return t.kind == tokSymbol && t.text == s
+}
+
+func (p *parser) acceptSymbol(s string) bool {
+	if p.isSymbol(s) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(s string) error {
+	if !p.acceptSymbol(s) {
+		return p.unexpected("expected " + strconv.Quote(s))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	t := p.peek()
+	return errorAt(t.pos, "%s, found %s", want, t)
+}
+
+func (p *parser) selectList() ([]SelectItem, error) {
+	var items []SelectItem
+	for {
+		pos := p.peek().pos
+		if p.acceptSymbol("*") {
+			items = append(items, SelectItem{Star: true, Pos: pos})
+		} else {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item := SelectItem{Expr: e, Pos: pos}
+			if p.acceptKeyword("AS") {
+				t := p.next()
+				if t.kind != tokIdent && t.kind != tokQuotedIdent {
+					return nil, errorAt(t.pos, "expected an alias after AS, found %s", t)
+				}
+				item.Alias = t.text
+			} else if t := p.peek(); t.kind == tokQuotedIdent || t.kind == tokIdent && !reserved[strings.ToUpper(t.text)] {
+				item.Alias = p.next().text
+			}
+			items = append(items, item)
+		}
+		if !p.acceptSymbol(",") {
+			return items, nil
+		}
+	}
+}
+
+func (p *parser) exprList() ([]Expr, error) {
+	var list []Expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.acceptSymbol(",") {
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) orderList() ([]OrderItem, error) {
+	var list []OrderItem
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		item := OrderItem{Expr: e}
+		if p.acceptKeyword("DESC") {
+			item.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		list = append(list, item)
+		if !p.acceptSymbol(",") {
+			return list, nil
+		}
+	}
+}
+
+// count reads the non-negative integer of a LIMIT or OFFSET clause.
+func (p *parser) count(clause string) (int, error) {
+	t := p.next()
+	n, err := strconv.Atoi(t.text)
+	if t.kind != tokNumber || err != nil || n < 0 {
+		return 0, errorAt(t.pos, "%s expects a non-negative integer, found %s", clause, t)
+	}
+	return n, nil
+}
+
+func (p *parser) expr() (Expr, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	return p.or()
+}
+
+func (p *parser) or() (Expr, error) {
+	l, err := p.and()
+	for err == nil && p.isKeyword("OR") {
+		pos := p.next().pos
+		var r Expr
+		if r, err = p.and(); err == nil {
+			l = &Binary{Op: "OR", L: l, R: r, Pos: pos}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) and() (Expr, error) {
+	l, err := p.not()
+	for err == nil && p.isKeyword("AND") {
+		pos := p.next().pos
+		var r Expr
+		if r, err = p.not(); err == nil {
+			l = &Binary{Op: "AND", L: l, R: r, Pos: pos}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.isKeyword("NOT") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		pos := p.next().pos
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "NOT", X: x, Pos: pos}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Expr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			r, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "<>" {
+				op = "!="
+			}
+			return &Binary{Op: op, L: l, R: r, Pos: t.pos}, nil
+		}
+	}
+
+	negated := false
+	if p.isKeyword("NOT") {
+		if next := p.peekAt(1); next.kind == tokIdent {
+			switch strings.ToUpper(next.text) {
+			case "IN", "BETWEEN", "LIKE":
+				p.next()
+				negated = true
+			}
+		}
+	}
+	pos := p.peek().pos
+	switch {
+	case p.acceptKeyword("IN"):
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		list, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return &In{X: l, List: list, Not: negated, Pos: pos}, nil
+	case p.acceptKeyword("BETWEEN"):
+		lo, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		hi, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &Between{X: l, Lo: lo, Hi: hi, Not: negated, Pos: pos}, nil
+	case p.acceptKeyword("LIKE"):
+		r, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		var e Expr = &Binary{Op: "LIKE", L: l, R: r, Pos: pos}
+		if negated {
+			e = &Unary{Op: "NOT", X: e, Pos: pos}
+		}
+		return e, nil
+	case p.acceptKeyword("IS"):
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNull{X: l, Not: not, Pos: pos}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) additive() (Expr, error) {
+	l, err := p.multiplicative()
+	for err == nil && (p.isSymbol("+") || p.isSymbol("-") || p.isSymbol("||")) {
+		t := p.next()
+		var r Expr
+		if r, err = p.multiplicative(); err == nil {
+			l = &Binary{Op: t.text, L: l, R: r, Pos: t.pos}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) multiplicative() (Expr, error) {
+	l, err := p.unary()
+	for err == nil && (p.isSymbol("*") || p.isSymbol("/") || p.isSymbol("%")) {
+		t := p.next()
+		var r Expr
+		if r, err = p.unary(); err == nil {
+			l = &Binary{Op: t.text, L: l, R: r, Pos: t.pos}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.isSymbol("-") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		pos := p.next().pos
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := x.(*NumberLit); ok {
+			return &NumberLit{Value: -n.Value, Pos: pos}, nil
+		}
+		return &Unary{Op: "-", X: x, Pos: pos}, nil
+	}
+	p.acceptSymbol("+")
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil || math.IsInf(v, 0) {
+			return nil, errorAt(t.pos, "invalid number %s", t)
+		}
+		return &NumberLit{Value: v, Pos: t.pos}, nil
+	case tokString:
+		p.next()
+		return &StringLit{Value: t.text, Pos: t.pos}, nil
+	case tokQuotedIdent:
+		p.next()
+		return &ColumnRef{Name: t.text, Pos: t.pos}, nil
+	case tokSymbol:
+		if p.acceptSymbol("(") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectSymbol(")")
+		}
+	case tokIdent:
+		switch upper := strings.ToUpper(t.text); {
+		case upper == "TRUE" || upper == "FALSE":
+			p.next()
+			return &BoolLit{Value: upper == "TRUE", Pos: t.pos}, nil
+		case upper == "DATE" && p.peekAt(1).kind == tokString:
+			// DATE '2024-01-31'
+			p.next()
+			lit := p.next()
+			return &Call{Name: "date", Args: []Expr{&StringLit{Value: lit.text, Pos: lit.pos}}, Pos: t.pos}, nil
+		case reserved[upper]:
+			return nil, p.unexpected("expected an expression")
+		}
+		p.next()
+		if p.isSymbol("(") {
+			return p.call(t)
+		}
+		return &ColumnRef{Name: t.text, Pos: t.pos}, nil
+	}
+	return nil, p.unexpected("expected an expression")
+}
+
+func (p *parser) call(name token) (Expr, error) {
+	p.next() // (
+	c := &Call{Name: strings.ToLower(name.text), Pos: name.pos}
+	if p.acceptSymbol("*") {
+		c.Star = true
+		return c, p.expectSymbol(")")
+	}
+	c.Distinct = p.acceptKeyword("DISTINCT")
+	if !p.isSymbol(")") {
+		args, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		c.Args = args
+	}
+	return c, p.expectSymbol(")")
+}
@@ -0,0 +1,221 @@
+package sqlquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/repository"
+)
+
+func day(s string) time.Time {
+	t, _ := domain.ParseDate(s)
+	return t
+}
+
+func testStore() *repository.DataStore {
+	return repository.NewDataStore([]domain.Transaction{
+		{ID: "1", Date: day("2024-01-05"), UserID: "u1", Country: "USA", Category: "Toys", ProductName: "Kite", Price: 10, Quantity: 2, TotalPrice: 20},
+		{ID: "2", Date: day("2024-01-20"), UserID: "u2", Country: "Canada", Category: "Toys", ProductName: "Ball", Price: 5, Quantity: 3, TotalPrice: 15},
+		{ID: "3", Date: day("2024-02-02"), UserID: "u1", Country: "USA", Category: "Books", ProductName: "Atlas", Price: 30, Quantity: 1, TotalPrice: 30},
+		{ID: "4", Date: day("2024-02-14"), UserID: "u3", Country: "USA", Category: "Toys", ProductName: "Ball", Price: 5, Quantity: 4, TotalPrice: 20},
+		{ID: "5", Date: day("2024-03-01"), UserID: "u2", Country: "Mexico", Category: "Books", ProductName: "Atlas", Price: 30, Quantity: 2, TotalPrice: 60},
+	})
+}
+
+func run(t *testing.T, src string) *Result {
+	t.Helper()
+	res, err := Run(testStore(), src, Options{})
+	if err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	return res
+}
+
+func TestGroupByHavingOrderLimit(t *testing.T) {
+	res := run(t, `
+		SELECT country, sum(total_price) AS revenue, count(*) AS n, count(DISTINCT user_id) users
+		FROM transactions
+		WHERE category IN ('Toys', 'Books') AND date >= '2024-01-10'
+		GROUP BY country
+		HAVING sum(total_price) > 20
+		ORDER BY revenue DESC
+		LIMIT 5`)
+	wantCols := []Column{{"country", "string"}, {"revenue", "number"}, {"n", "number"}, {"users", "number"}}
+	if !reflect.DeepEqual(res.Columns, wantCols) {
+		t.Errorf("columns = %v", res.Columns)
+	}
+	want := [][]any{{"Mexico", 60.0, 1.0, 1.0}, {"USA", 50.0, 2.0, 2.0}}
+	if !reflect.DeepEqual(res.Rows, want) {
+		t.Errorf("rows = %v, want %v", res.Rows, want)
+	}
+}
+
+func TestDateTruncAndOrdinals(t *testing.T) {
+	res := run(t, `select date_trunc('month', Date) as month, round(avg(Price), 1), max(product_name)
+		from transactions group by 1 order by 1 desc`)
+	want := [][]any{
+		{"2024-03-01", 30.0, "Atlas"},
+		{"2024-02-01", 17.5, "Ball"},
+		{"2024-01-01", 7.5, "Kite"},
+	}
+	if !reflect.DeepEqual(res.Rows, want) {
+		t.Errorf("rows = %v, want %v", res.Rows, want)
+	}
+	if res.Columns[0].Type != "date" || res.Columns[1].Name != "round(avg(price), 1)" {
+		t.Errorf("columns = %v", res.Columns)
+	}
+}
+
+func TestPlainSelect(t *testing.T) {
+	res := run(t, `SELECT id, quantity * price AS value FROM transactions
+		WHERE product_name LIKE 'B%' OR price BETWEEN 25 AND 35 ORDER BY value DESC, id LIMIT 2 OFFSET 1`)
+	// matching values are 60, 30, 20 and 15; the offset skips the 60
+	if want := [][]any{{"3", 30.0}, {"4", 20.0}}; !reflect.DeepEqual(res.Rows, want) {
+		t.Errorf("rows = %v, want %v", res.Rows, want)
+	}
+
+	res = run(t, `SELECT * FROM transactions LIMIT 1`)
+	if len(res.Columns) != len(schema) || res.Rows[0][0] != "1" || res.Rows[0][1] != "2024-01-05" {
+		t.Errorf("SELECT * = %v %v", res.Columns, res.Rows)
+	}
+
+	res = run(t, `SELECT DISTINCT category FROM transactions ORDER BY category`)
+	if !reflect.DeepEqual(res.Rows, [][]any{{"Books"}, {"Toys"}}) {
+		t.Errorf("DISTINCT = %v", res.Rows)
+	}
+
+	res, _ = Run(testStore(), `SELECT id FROM transactions`, Options{MaxRows: 3})
+	if len(res.Rows) != 3 || !res.Truncated {
+		t.Errorf("MaxRows: %d rows, truncated %v", len(res.Rows), res.Truncated)
+	}
+}
+
+func TestAggregateOverNoRows(t *testing.T) {
+	res := run(t, `SELECT count(*), sum(price) FROM transactions WHERE country = 'Peru'`)
+	if !reflect.DeepEqual(res.Rows, [][]any{{0.0, nil}}) {
+		t.Errorf("rows = %v", res.Rows)
+	}
+}
+
+func TestExplainUsesIndexes(t *testing.T) {
+	res := run(t, `EXPLAIN SELECT region, count(*) FROM transactions
+		WHERE country = 'USA' AND category IN ('Toys') AND price > 5 GROUP BY region ORDER BY 2 DESC LIMIT 3`)
+	want := []string{
+		"Scan: index lookup country = 'USA' AND category IN ('Toys') (2 of 5 rows)",
+		"Filter: price > 5",
+		"Aggregate: group by region computing count(*)",
+		"Sort: count(*) DESC",
+		"Limit: 3 offset 0",
+		"Project: region, count(*)",
+	}
+	if !reflect.DeepEqual(res.Plan, want) || len(res.Rows) != 0 {
+		t.Errorf("plan =\n%s", strings.Join(res.Plan, "\n"))
+	}
+
+	res, _ = Run(testStore(), `SELECT id FROM transactions WHERE lower(country) = 'usa'`, Options{Explain: true})
+	if res.Plan[0] != "Scan: full scan of transactions (5 rows)" {
+		t.Errorf("plan = %v", res.Plan)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		src, msg  string
+		line, col int
+	}{
+		{"SELECT country FROM transactions WHERE", "expected an expression, found end of query", 1, 39},
+		{"SELECT country\nFROM sales", `unknown table "sales"`, 2, 6},
+		{"SELECT colour FROM transactions", `unknown column "colour"`, 1, 8},
+		{"SELECT country, sum(price) FROM transactions", "column country must appear in GROUP BY", 1, 8},
+		{"SELECT id FROM transactions WHERE sum(price) > 1", "not allowed in WHERE", 1, 35},
+		{"SELECT id FROM transactions WHERE price = 'cheap'", "cannot compare price (number) = 'cheap' (string)", 1, 35},
+		{"SELECT id FROM transactions WHERE date > '2024-13-01'", "invalid date", 1, 42},
+		{"SELECT id FROM transactions WHERE name = 'x", "unterminated string literal", 1, 42},
+		{"SELECT id FROM transactions LIMIT -1", "LIMIT expects a non-negative integer", 1, 35},
+		{"SELECT median(price) FROM transactions", "unknown function median", 1, 8},
+		{"SELECT sum(country) FROM transactions", "sum expects a number", 1, 12},
+		{"DELETE FROM transactions", "expected SELECT", 1, 1},
+		{"SELECT id FROM transactions ORDER BY 3", "position 3 is not in the select list", 1, 38},
+	}
+	for _, c := range cases {
+		_, err := Run(testStore(), c.src, Options{})
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%q: got %v, want an *Error", c.src, err)
+			continue
+		}
+		if !strings.Contains(e.Msg, c.msg) || e.Line != c.line || e.Column != c.col {
+			t.Errorf("%q: got %v, want %q at %d:%d", c.src, e, c.msg, c.line, c.col)
+		}
+	}
+
+	_, err := Parse("SELECT " + strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1) + " FROM transactions")
+	if e, ok := err.(*Error); !ok || !strings.Contains(e.Msg, "nested too deeply") {
+		t.Errorf("deep nesting: got %v", err)
+	}
+	_, err = Parse("SELECT 1" + strings.Repeat("+1", maxTokens) + " FROM transactions")
+	if e, ok := err.(*Error); !ok || !strings.Contains(e.Msg, "too long") {
+		t.Errorf("long chain: got %v", err)
+	}
+	if _, err := Parse("SELECT " + strings.Repeat("NOT -(", maxDepth/3) + "1" + strings.Repeat(")", maxDepth/3) + " FROM transactions"); err != nil {
+		t.Errorf("nesting within the limit: %v", err)
+	}
+
+	_, err = Parse("SELECT id\nFROM transactions WHERE id = = 1")
+	if want := "FROM transactions WHERE id = = 1\n                             ^"; err.(*Error).Context() != want {
+		t.Errorf("context =\n%s", err.(*Error).Context())
+	}
+}
+
+func TestOrderByLimitKeepsTopRows(t *testing.T) {
+	// ties on price stay in table order, as without a limit
+	all := run(t, `SELECT id, price FROM transactions ORDER BY price DESC`)
+	for limit := 0; limit <= 5; limit++ {
+		for offset := 0; offset <= 2; offset++ {
+			src := fmt.Sprintf(`SELECT id, price FROM transactions ORDER BY price DESC LIMIT %d OFFSET %d`, limit, offset)
+			want := all.Rows[min(offset, 5):min(offset+limit, 5)]
+			if res := run(t, src); !reflect.DeepEqual(res.Rows, want) {
+				t.Errorf("%s: rows = %v, want %v", src, res.Rows, want)
+			}
+		}
+	}
+
+	res, err := Run(testStore(), `SELECT id FROM transactions ORDER BY total_price`, Options{MaxRows: 2})
+	if err != nil || !res.Truncated || !reflect.DeepEqual(res.Rows, [][]any{{"2"}, {"1"}}) {
+		t.Errorf("MaxRows: got %v %v", res, err)
+	}
+}
+
+func TestExportsEmptyAndNonFiniteResults(t *testing.T) {
+	res := run(t, `SELECT id, 1e308 * 10 AS huge FROM transactions WHERE country = 'Peru'`)
+	raw, _ := json.Marshal(res)
+	if !strings.Contains(string(raw), `"rows":[]`) {
+		t.Errorf("empty result = %s", raw)
+	}
+
+	res = run(t, `SELECT 1e308 * 10, -1e308 * 10 FROM transactions LIMIT 1`)
+	raw, err := json.Marshal(res)
+	if err != nil || !strings.Contains(string(raw), `"rows":[[null,null]]`) {
+		t.Errorf("non-finite result = %s, %v", raw, err)
+	}
+}
+
+func TestLongChainCompilesQuickly(t *testing.T) {
+	// every grouped node is matched against the GROUP BY keys by its text,
+	// which took minutes when rebuilt per node
+	src := "SELECT country FROM transactions GROUP BY country HAVING sum(price)" + strings.Repeat("+1", maxTokens/2-20) + " > 0"
+	start := time.Now()
+	res := run(t, src)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+	if len(res.Rows) != 3 {
+		t.Errorf("rows = %v", res.Rows)
+	}
+}
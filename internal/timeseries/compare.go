@@ -0,0 +1,90 @@
+package timeseries
+
+import (
+	"time"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+)
+
+// Change compares a period with an earlier one.
+type Change struct {
+	Value   float64  // the earlier period's value
+	Delta   float64  // current minus earlier
+	Percent *float64 // Delta relative to Value, nil when Value is zero
+}
+
+// Comparison is the period-over-period view of one point.
+type Comparison struct {
+	Previous   *Change // nil for the first period of the series
+	LastYear   *Change // nil when the series doesn't reach a year back
+	Cumulative float64 // running total from the first compared point
+}
+
+// Find returns the position of the period holding stored day d, or -1.
+func (s *Series) Find(d int32) int {
+	if len(s.Points) == 0 {
+		return -1
+	}
+	b := s.Bucket
+	i := int(b.Ordinal(d) - b.Ordinal(s.Points[0].Start))
+	if i < 0 || i >= len(s.Points) {
+		return -1
+	}
+	return i
+}
+
+// Compare compares each point from index first on with the period before
+// it and the same period a year earlier, and adds the values up from
+// first on. Earlier points are only compared against, so a series built
+// from a year before the range of interest has every comparison filled.
+func (s *Series) Compare(first int, value func(Point) float64) []Comparison {
+	b := s.Bucket
+	out := make([]Comparison, 0, len(s.Points)-first)
+	total := 0.0
+	for i := first; i < len(s.Points); i++ {
+		v := value(s.Points[i])
+		total += v
+		c := Comparison{Cumulative: total}
+		if i > 0 {
+			c.Previous = change(v, value(s.Points[i-1]))
+		}
+		if ly, ok := sameStartLastYear(b, s.Points[i].Start); ok {
+			if j := i - int(b.Ordinal(s.Points[i].Start)-b.Ordinal(ly)); j >= 0 {
+				c.LastYear = change(v, value(s.Points[j]))
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func change(current, earlier float64) *Change {
+	c := &Change{Value: earlier, Delta: current - earlier}
+	if earlier != 0 {
+		pct := c.Delta / earlier * 100
+		c.Percent = &pct
+	}
+	return c
+}
+
+// sameStartLastYear returns the start of the period a year before the one
+// starting on day start: the same ISO week number for weeks, and the same
+// calendar date otherwise, February 29th mapping to the 28th. A week 53
+// has no counterpart in a year of 52 weeks.
+func sameStartLastYear(b query.Bucket, start int32) (int32, bool) {
+	if b == query.Week {
+		year, week := columnar.DayTime(start).ISOWeek()
+		// January 4th is always in week 1
+		monday := query.Week.Of(columnar.DayOf(time.Date(year-1, time.January, 4, 0, 0, 0, 0, time.UTC))) + int32(7*(week-1))
+		if _, w := columnar.DayTime(monday).ISOWeek(); w != week {
+			return 0, false
+		}
+		return monday, true
+	}
+	y, m, d := columnar.Civil(start)
+	if m == time.February && d == 29 {
+		d = 28
+	}
+	return columnar.DayOf(time.Date(y-1, m, d, 0, 0, 0, 0, time.UTC)), true
+}
@@ -0,0 +1,75 @@
+package timeseries
+
+import (
+	"testing"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/query"
+)
+
+func TestCompare(t *testing.T) {
+	tbl := columnar.FromTransactions([]domain.Transaction{
+		{Date: day("2023-02-10"), TotalPrice: 50},
+		{Date: day("2024-01-10"), TotalPrice: 100},
+		{Date: day("2024-02-10"), TotalPrice: 150},
+	})
+	s, err := Build(tbl, nil, Options{Bucket: query.Month})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := s.Find(*dayNumber("2024-01-01"))
+	if first != 11 {
+		t.Fatalf("Find = %d, want 11", first)
+	}
+	c := s.Compare(first, func(p Point) float64 { return p.Revenue })
+	if len(c) != 2 {
+		t.Fatalf("got %d comparisons", len(c))
+	}
+	if c[0].Previous == nil || c[0].Previous.Value != 0 || c[0].Previous.Percent != nil {
+		t.Errorf("January vs December = %+v", c[0].Previous)
+	}
+	feb := c[1]
+	if feb.Previous.Delta != 50 || *feb.Previous.Percent != 50 {
+		t.Errorf("February vs January = %+v", feb.Previous)
+	}
+	if feb.LastYear == nil || feb.LastYear.Value != 50 || *feb.LastYear.Percent != 200 {
+		t.Errorf("February vs last year = %+v", feb.LastYear)
+	}
+	if feb.Cumulative != 250 {
+		t.Errorf("Cumulative = %v, want 250", feb.Cumulative)
+	}
+
+	if s.Find(*dayNumber("2025-01-01")) != -1 {
+		t.Error("Find past the series should be -1")
+	}
+	if c := s.Compare(0, func(p Point) float64 { return p.Revenue }); c[0].Previous != nil || c[0].LastYear != nil {
+		t.Errorf("the first point has nothing to compare with: %+v", c[0])
+	}
+}
+
+func TestSameStartLastYear(t *testing.T) {
+	tests := []struct {
+		b     query.Bucket
+		start string
+		want  string // empty when there is no counterpart
+	}{
+		{query.Month, "2024-03-01", "2023-03-01"},
+		{query.Day, "2024-02-29", "2023-02-28"},
+		{query.Week, "2024-01-01", "2023-01-02"}, // W01
+		{query.Week, "2021-01-04", "2019-12-30"}, // 2020-W01 starts in December
+		{query.Week, "2020-12-28", ""},           // 2020-W53, 2019 has 52 weeks
+	}
+	for _, tt := range tests {
+		got, ok := sameStartLastYear(tt.b, *dayNumber(tt.start))
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%s %s: expected no counterpart, got %s", tt.b, tt.start, columnar.DayTime(got).Format("2006-01-02"))
+			}
+			continue
+		}
+		if !ok || got != *dayNumber(tt.want) {
+			t.Errorf("%s %s = %s, want %s", tt.b, tt.start, columnar.DayTime(got).Format("2006-01-02"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package timeseries buckets sales into consecutive calendar periods.
+// Every period between the first and the last one is present,
+// with zero sales when nothing was sold, so charts don't interpolate over
+// gaps.
+package timeseries
+
+import (
+	"fmt"
+	"time"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/query"
+)
+
+// MaxPoints bounds the length of a series, e.g. about 27 years of days.
+const MaxPoints = 10000
+
+// Point is the sales of one period.
+type Point struct {
+	Start        int32 // first local day of the period, as a columnar day number
+	Quantity     int
+	Revenue      float64
+	Transactions int
+}
+
+// Series is a gap-free sequence of periods.
+type Series struct {
+	Bucket   query.Bucket
+	Location *time.Location
+	Points   []Point
+}
+
+// Label names period i, such as 2024-W07 or 2024-Q1.
+func (s *Series) Label(i int) string {
+	return s.Bucket.Format(s.Points[i].Start)
+}
+
+// StartTime is the instant period i begins in the series' time zone.
+func (s *Series) StartTime(i int) time.Time {
+	y, m, d := columnar.Civil(s.Points[i].Start)
+	return time.Date(y, m, d, 0, 0, 0, 0, s.Location)
+}
+
+// Revenues returns the revenue of each period.
+func (s *Series) Revenues() []float64 {
+	out := make([]float64, len(s.Points))
+	for i, p := range s.Points {
+		out[i] = p.Revenue
+	}
+	return out
+}
+
+// Quantities returns the quantity sold in each period.
+func (s *Series) Quantities() []float64 {
+	out := make([]float64, len(s.Points))
+	for i, p := range s.Points {
+		out[i] = float64(p.Quantity)
+	}
+	return out
+}
+
+// Extend appends n empty periods, e.g. to label forecasts.
+func (s *Series) Extend(n int) {
+	for i := 0; i < n && len(s.Points) > 0; i++ {
+		last := s.Points[len(s.Points)-1].Start
+		s.Points = append(s.Points, Point{Start: s.Bucket.Next(last)})
+	}
+}
+
+// Options select the periods of a series.
+type Options struct {
+	Bucket query.Bucket
+	// Location sets the UTC offset of the period start times, which are
+	// local midnights of stored days; nil is UTC.
+	Location *time.Location
+	// From and To extend or cut the series to the periods holding these
+	// days. Without them it spans the first to the last sale.
+	From, To *int32
+}
+
+// Build buckets the given rows of t, all of them when rows is nil.
+//
+// Transaction dates are stored as calendar days and taken to be local
+// days already: the location only sets the offset periods start at.
+func Build(t *columnar.Table, rows []int32, opts Options) (*Series, error) {
+	s := &Series{Bucket: opts.Bucket, Location: opts.Location}
+	if s.Bucket == "" {
+		s.Bucket = query.Month
+	}
+	if s.Location == nil {
+		s.Location = time.UTC
+	}
+
+	forEach := func(fn func(i int)) {
+		if rows == nil {
+			for i := 0; i < t.Len(); i++ {
+				fn(i)
+			}
+			return
+		}
+		for _, i := range rows {
+			fn(int(i))
+		}
+	}
+
+	var minDay, maxDay int32
+	n := 0
+	forEach(func(i int) {
+		d := t.Date[i]
+		if n == 0 || d < minDay {
+			minDay = d
+		}
+		if n == 0 || d > maxDay {
+			maxDay = d
+		}
+		n++
+	})
+	if n == 0 && (opts.From == nil || opts.To == nil) {
+		return s, nil
+	}
+
+	first, last := minDay, maxDay
+	if opts.From != nil {
+		first = *opts.From
+	}
+	if opts.To != nil {
+		last = *opts.To
+	}
+	if first > last {
+		return s, nil
+	}
+
+	b := s.Bucket
+	base := b.Ordinal(first)
+	count := int(b.Ordinal(last)-base) + 1
+	if count > MaxPoints {
+		return nil, fmt.Errorf("%d %s periods requested, at most %d are supported: use a coarser granularity or a shorter date range", count, b, MaxPoints)
+	}
+	s.Points = make([]Point, count)
+	for i, start := 0, b.Start(first); i < count; i, start = i+1, b.Next(start) {
+		s.Points[i].Start = start
+	}
+
+	forEach(func(i int) {
+		p := int(b.Ordinal(t.Date[i]) - base)
+		if p < 0 || p >= count {
+			return // outside From..To
+		}
+		s.Points[p].Quantity += int(t.Quantity[i])
+		s.Points[p].Revenue += t.TotalPrice[i]
+		s.Points[p].Transactions++
+	})
+	return s, nil
+}
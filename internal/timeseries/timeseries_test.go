@@ -0,0 +1,98 @@
+package timeseries
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"Dashlytics/internal/columnar"
+	"Dashlytics/internal/domain"
+	"Dashlytics/internal/query"
+)
+
+func day(s string) time.Time {
+	t, _ := domain.ParseDate(s)
+	return t
+}
+
+func dayNumber(s string) *int32 {
+	d := columnar.DayOf(day(s))
+	return &d
+}
+
+func testTable() *columnar.Table {
+	return columnar.FromTransactions([]domain.Transaction{
+		{Date: day("2024-01-01"), Quantity: 1, TotalPrice: 10},
+		{Date: day("2024-01-31"), Quantity: 2, TotalPrice: 20},
+		{Date: day("2024-04-02"), Quantity: 3, TotalPrice: 30},
+	})
+}
+
+func labels(s *Series) []string {
+	var out []string
+	for i := range s.Points {
+		out = append(out, s.Label(i))
+	}
+	return out
+}
+
+func TestBuildFillsGaps(t *testing.T) {
+	s, err := Build(testTable(), nil, Options{Bucket: query.Month})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-01", "2024-02", "2024-03", "2024-04"}
+	if got := labels(s); len(got) != len(want) || got[1] != want[1] || got[3] != want[3] {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	if p := s.Points[0]; p.Quantity != 3 || p.Revenue != 30 || p.Transactions != 2 {
+		t.Errorf("January = %+v", p)
+	}
+	if p := s.Points[1]; p.Quantity != 0 || p.Transactions != 0 {
+		t.Errorf("February should be empty, got %+v", p)
+	}
+
+	s, _ = Build(testTable(), nil, Options{Bucket: query.Week})
+	if got := labels(s); len(got) != 14 || got[0] != "2024-W01" || got[13] != "2024-W14" {
+		t.Errorf("weeks = %v", got)
+	}
+}
+
+func TestBuildInTimeZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	// stored days are local days: the zone only moves the start instants
+	s, err := Build(testTable(), nil, Options{Bucket: query.Month, Location: ny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc, _ := Build(testTable(), nil, Options{Bucket: query.Month})
+	if !reflect.DeepEqual(s.Points, utc.Points) {
+		t.Fatalf("points = %+v, want %+v", s.Points, utc.Points)
+	}
+	if got := s.StartTime(0).Format(time.RFC3339); got != "2024-01-01T00:00:00-05:00" {
+		t.Errorf("StartTime = %s", got)
+	}
+}
+
+func TestBuildRange(t *testing.T) {
+	tbl := testTable()
+	s, err := Build(tbl, []int32{}, Options{Bucket: query.Quarter, From: dayNumber("2023-05-01"), To: dayNumber("2023-12-31")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(s); len(got) != 3 || got[0] != "2023-Q2" || got[2] != "2023-Q4" {
+		t.Errorf("labels = %v", got)
+	}
+
+	s, _ = Build(tbl, []int32{}, Options{Bucket: query.Day})
+	if len(s.Points) != 0 {
+		t.Errorf("no rows and no range should give no points, got %d", len(s.Points))
+	}
+
+	if _, err := Build(tbl, nil, Options{Bucket: query.Day, From: dayNumber("1900-01-01")}); err == nil {
+		t.Error("a series longer than MaxPoints should fail")
+	}
+}
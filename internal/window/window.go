@@ -0,0 +1,141 @@
+// Package window computes trailing window functions, such as moving sums
+// and averages, over evenly spaced values like the periods of a time
+// series.
+package window
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Func is a window function.
+type Func string
+
+const (
+	Sum  Func = "sum"  // moving sum of the last Size values
+	Avg  Func = "avg"  // moving average of the last Size values
+	EWMA Func = "ewma" // exponentially weighted moving average with a span of Size
+)
+
+// Funcs lists every Func.
+var Funcs = []Func{Sum, Avg, EWMA}
+
+// MaxSize bounds window sizes, a year of days.
+const MaxSize = 366
+
+// MaxSpecs bounds how many windows one request may ask for.
+const MaxSpecs = 8
+
+// Spec is a window function with its size, written "avg:7".
+type Spec struct {
+	Func Func
+	Size int
+}
+
+func (s Spec) String() string {
+	return fmt.Sprintf("%s:%d", s.Func, s.Size)
+}
+
+// Parse reads a spec such as "sum:30" or "ewma:7".
+func Parse(s string) (Spec, error) {
+	name, size, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Spec{}, fmt.Errorf("invalid window %q: want function:size, e.g. avg:7", s)
+	}
+	var spec Spec
+	for _, f := range Funcs {
+		if strings.EqualFold(name, string(f)) {
+			spec.Func = f
+		}
+	}
+	if spec.Func == "" {
+		return Spec{}, fmt.Errorf("invalid window %q: unknown function %q (want sum, avg or ewma)", s, name)
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 1 || n > MaxSize {
+		return Spec{}, fmt.Errorf("invalid window %q: size must be between 1 and %d", s, MaxSize)
+	}
+	spec.Size = n
+	return spec, nil
+}
+
+// ParseList reads comma-separated specs, dropping duplicates.
+func ParseList(s string) ([]Spec, error) {
+	var specs []Spec
+	seen := make(map[Spec]bool)
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		spec, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[spec] {
+			seen[spec] = true
+			specs = append(specs, spec)
+		}
+	}
+	if len(specs) > MaxSpecs {
+		return nil, fmt.Errorf("%d windows requested, at most %d are supported", len(specs), MaxSpecs)
+	}
+	return specs, nil
+}
+
+// Lookback is how many values before the first one of interest the window
+// reads. An EWMA reads all of them, but after three spans the weight left
+// on earlier values is below 0.25%.
+func (s Spec) Lookback() int {
+	if s.Func == EWMA {
+		return 3 * s.Size
+	}
+	return s.Size - 1
+}
+
+// Apply computes the window ending at each value. Sums and averages are
+// NaN until Size values are available; an EWMA starts at the first value.
+func (s Spec) Apply(values []float64) []float64 {
+	switch s.Func {
+	case EWMA:
+		return ewma(values, 2/float64(s.Size+1))
+	case Avg:
+		out := sums(values, s.Size)
+		for i := range out {
+			out[i] /= float64(s.Size)
+		}
+		return out
+	}
+	return sums(values, s.Size)
+}
+
+// sums adds each window up from scratch rather than keeping a running
+// total, so a window of zeros after large values is exactly zero.
+func sums(values []float64, size int) []float64 {
+	out := make([]float64, len(values))
+	for i := range values {
+		if i < size-1 {
+			out[i] = math.NaN()
+			continue
+		}
+		total := 0.0
+		for _, v := range values[i-size+1 : i+1] {
+			total += v
+		}
+		out[i] = total
+	}
+	return out
+}
+
+func ewma(values []float64, alpha float64) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		if i == 0 {
+			out[i] = v
+			continue
+		}
+		out[i] = alpha*v + (1-alpha)*out[i-1]
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package window
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	specs, err := ParseList("avg:7, EWMA:30,avg:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 2 || specs[0] != (Spec{Avg, 7}) || specs[1].String() != "ewma:30" {
+		t.Errorf("specs = %v", specs)
+	}
+	for _, s := range []string{"avg", "median:3", "sum:0", "sum:x", "avg:1000"} {
+		if _, err := ParseList(s); err == nil {
+			t.Errorf("%q should fail", s)
+		}
+	}
+	if specs, err := ParseList(""); err != nil || len(specs) != 0 {
+		t.Errorf("empty list = %v, %v", specs, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 0, 0}
+	sum := Spec{Sum, 3}.Apply(values)
+	if !math.IsNaN(sum[1]) || sum[2] != 6 || sum[3] != 9 || sum[5] != 4 {
+		t.Errorf("sum:3 = %v", sum)
+	}
+	avg := Spec{Avg, 2}.Apply(values)
+	if !math.IsNaN(avg[0]) || avg[1] != 1.5 || avg[5] != 0 {
+		t.Errorf("avg:2 = %v", avg)
+	}
+	// span 3 is alpha 0.5
+	ewma := Spec{EWMA, 3}.Apply(values)
+	if ewma[0] != 1 || ewma[1] != 1.5 || ewma[2] != 2.25 {
+		t.Errorf("ewma:3 = %v", ewma)
+	}
+	if got := (Spec{Sum, 3}).Apply(nil); len(got) != 0 {
+		t.Errorf("empty input gave %v", got)
+	}
+}